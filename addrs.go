@@ -0,0 +1,137 @@
+package goaway2
+
+import (
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+/***Variables***/
+
+//localAddrStore : current map of local ip-addresses to their interface, swapped atomically
+var localAddrStore atomic.Value
+
+//AddrSource : source of the local ip-addresses and notifications when they change
+type AddrSource interface {
+	// Addrs : return local ipv4-addresses mapped to the interface they are assigned to
+	Addrs() (map[string]string, error)
+	// Subscribe : return channel signalled on address changes until done is closed
+	Subscribe(done <-chan struct{}) (<-chan struct{}, error)
+}
+
+//AddrTracker : keeps the local ip-addresses up to date while interfaces change
+type AddrTracker struct {
+	Source       AddrSource
+	PollInterval time.Duration // interval used when the source can not notify changes
+	Logger       *log.Logger
+}
+
+//systemAddrs : address source reading the addresses of the systems interfaces
+type systemAddrs struct{}
+
+//SystemAddrs : address source of the running system (changes are reported via rtnetlink)
+var SystemAddrs AddrSource = systemAddrs{}
+
+/***Init***/
+
+func init() {
+	addrs, _ := SystemAddrs.Addrs()
+	setLocalAddrs(addrs)
+}
+
+/***Functions***/
+
+//localAddrs : return the current local ip-addresses (must not be modified)
+func localAddrs() map[string]string {
+	return localAddrStore.Load().(map[string]string)
+}
+
+//setLocalAddrs : atomically replace the local ip-addresses
+func setLocalAddrs(addrs map[string]string) {
+	if addrs == nil {
+		addrs = make(map[string]string)
+	}
+	localAddrStore.Store(addrs)
+}
+
+//LocalAddrs : return a copy of the current local ip-addresses mapped to their interface
+func LocalAddrs() map[string]string {
+	addrs := make(map[string]string)
+	for ip, iface := range localAddrs() {
+		addrs[ip] = iface
+	}
+	return addrs
+}
+
+/***Methods***/
+
+//(systemAddrs).Addrs : collect ipv4-addresses from all interfaces
+func (systemAddrs) Addrs() (map[string]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[string]string)
+	for _, i := range ifaces {
+		ifaddrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaddrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				addrs[ipnet.IP.String()] = i.Name
+			}
+		}
+	}
+	return addrs, nil
+}
+
+//(*AddrTracker).getPollInterval : return variable with exception
+func (t *AddrTracker) getPollInterval() time.Duration {
+	if t.PollInterval <= 0 {
+		return 30 * time.Second
+	}
+	return t.PollInterval
+}
+
+//(*AddrTracker).refresh : collect addresses from the source and swap them in
+func (t *AddrTracker) refresh() {
+	addrs, err := t.Source.Addrs()
+	if err != nil {
+		t.Logger.Printf("Unable to collect local addresses: %s\n", err.Error())
+		return
+	}
+	setLocalAddrs(addrs)
+}
+
+//(*AddrTracker).Run : track local addresses until done is closed
+// changes are collected from the source notifications and by polling when
+// the source is unable to notify (or stops doing so)
+func (t *AddrTracker) Run(done <-chan struct{}) {
+	t.refresh()
+	events, err := t.Source.Subscribe(done)
+	if err != nil {
+		t.Logger.Printf("Unable to watch local addresses, polling every %s: %s\n", t.getPollInterval(), err.Error())
+	}
+	ticker := time.NewTicker(t.getPollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case _, ok := <-events:
+			if !ok {
+				t.Logger.Printf("Local address notifications stopped, polling every %s\n", t.getPollInterval())
+				events = nil
+				continue
+			}
+			t.refresh()
+		case <-ticker.C:
+			// notifications make polling unnecessary
+			if events == nil {
+				t.refresh()
+			}
+		}
+	}
+}
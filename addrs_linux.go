@@ -0,0 +1,70 @@
+package goaway2
+
+import (
+	"syscall"
+	"time"
+)
+
+/***Variables***/
+
+//rtnetlink multicast groups of link and ipv4-address changes
+const rtnlGroups = 1<<(syscall.RTNLGRP_LINK-1) | 1<<(syscall.RTNLGRP_IPV4_IFADDR-1)
+
+//rtnlPollTime : longest a read of the rtnetlink socket blocks before done is checked again
+const rtnlPollTime = time.Second
+
+/***Methods***/
+
+//(systemAddrs).Subscribe : signal address changes reported by rtnetlink
+func (systemAddrs) Subscribe(done <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtnlGroups}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// reads time out periodically so the reader notices done and closes the socket itself
+	// (closing it from another goroutine neither unblocks the read nor keeps the fd from being reused)
+	timeout := syscall.NsecToTimeval(rtnlPollTime.Nanoseconds())
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		defer syscall.Close(fd)
+		buf := make([]byte, syscall.Getpagesize())
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			switch {
+			case err == syscall.EAGAIN || err == syscall.EINTR:
+				continue
+			case err != nil:
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				switch m.Header.Type {
+				case syscall.RTM_NEWADDR, syscall.RTM_DELADDR, syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+					// coalesce bursts of changes into a single refresh
+					select {
+					case events <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,103 @@
+package goaway2
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+/***Variables***/
+
+//fakeAddrs : address source returning configurable addresses
+type fakeAddrs struct {
+	lock   sync.Mutex
+	addrs  map[string]string
+	events chan struct{}
+	err    error // returned by subscribe
+}
+
+/***Methods***/
+
+func (f *fakeAddrs) Addrs() (map[string]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	addrs := make(map[string]string)
+	for ip, iface := range f.addrs {
+		addrs[ip] = iface
+	}
+	return addrs, nil
+}
+
+func (f *fakeAddrs) Subscribe(done <-chan struct{}) (<-chan struct{}, error) {
+	return f.events, f.err
+}
+
+func (f *fakeAddrs) set(ip, iface string) {
+	f.lock.Lock()
+	f.addrs[ip] = iface
+	f.lock.Unlock()
+}
+
+/***Functions***/
+
+//restoreAddrs : restore the local addresses collected before the test
+func restoreAddrs(t *testing.T) func() {
+	saved := LocalAddrs()
+	return func() { setLocalAddrs(saved) }
+}
+
+//waitInbound : wait until packets from the given ip-address are considered inbound/outbound
+func waitInbound(t *testing.T, ip string, inbound bool) {
+	pkt := &PacketData{SrcIP: ip}
+	for i := 0; i < 200; i++ {
+		if pkt.IsInbound() == inbound {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Packet from %s not detected as inbound=%t\n", ip, inbound)
+}
+
+/***Unit-Tests***/
+
+func TestAddrTrackerEvents(t *testing.T) {
+	defer restoreAddrs(t)()
+	src := &fakeAddrs{addrs: map[string]string{"10.1.0.1": "eth0"}, events: make(chan struct{})}
+	tracker := &AddrTracker{Source: src, PollInterval: time.Hour, Logger: log.New(ioutil.Discard, "", 0)}
+	done := make(chan struct{})
+	defer close(done)
+	go tracker.Run(done)
+	waitInbound(t, "10.1.0.1", false)
+	// check new addresses (e.g. vpn tunnels) are picked up on notification
+	src.set("10.8.0.2", "tun0")
+	waitInbound(t, "10.8.0.2", true)
+	src.events <- struct{}{}
+	waitInbound(t, "10.8.0.2", false)
+	pkt := &PacketData{SrcIP: "192.0.2.1", DstIP: "10.8.0.2"}
+	pkt.ResolveInterfaces()
+	if pkt.InIface != "tun0" {
+		t.Fatalf("Unexpected interface: %q\n", pkt.InIface)
+	}
+}
+
+func TestAddrTrackerPolling(t *testing.T) {
+	defer restoreAddrs(t)()
+	src := &fakeAddrs{addrs: map[string]string{"10.1.0.1": "eth0"}, err: errors.New("no rtnetlink")}
+	tracker := &AddrTracker{Source: src, PollInterval: 10 * time.Millisecond, Logger: log.New(ioutil.Discard, "", 0)}
+	done := make(chan struct{})
+	defer close(done)
+	go tracker.Run(done)
+	waitInbound(t, "10.1.0.1", false)
+	// check dhcp renewals are picked up by polling
+	src.lock.Lock()
+	src.addrs = map[string]string{"10.1.0.7": "eth0"}
+	src.lock.Unlock()
+	waitInbound(t, "10.1.0.7", false)
+	waitInbound(t, "10.1.0.1", true)
+	if pkt := (&PacketData{SrcIP: "10.1.0.7"}); !zone("outbound").Validate(pkt.Direction()) {
+		t.Fatalf("Renewed address not matched by outbound zone\n")
+	}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"goaway2"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Functions***/
+
+//addressesDisplay : display the local ip-addresses the running daemon uses to detect direction
+func addressesDisplay(c *cli.Context) {
+	var addrs map[string]string
+	if err := goaway2.ControlCall(goaway2.ControlSocket, "addresses", nil, &addrs); err != nil {
+		cliError(c, fmt.Sprintf("CONTROL-ERROR: %s", err.Error()))
+	}
+	ips := make([]string, 0, len(addrs))
+	for ip := range addrs {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("   IP-Address    |     Interface      ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, ip := range ips {
+		fmt.Printf(" %-15s | %s \n", ip, addrs[ip])
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var auditDisplayArgs = []cli.Flag{
+	cli.IntFlag{
+		Name:  "limit, l",
+		Value: 50,
+		Usage: "maximum number of recent entries to display",
+	},
+}
+
+var auditPruneArgs = []cli.Flag{
+	cli.DurationFlag{
+		Name:  "age, a",
+		Value: 30 * 24 * time.Hour,
+		Usage: "remove entries recorded longer ago than the given age",
+	},
+}
+
+/***Functions***/
+
+//auditDisplay : display the most recent packets that would have been dropped
+func auditDisplay(c *cli.Context) {
+	entries, err := st.AuditLog(c.Int("limit"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("      EntryDate      | Proto |         Source        |      Destination      |     Reason    | Rule ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, rec := range entries {
+		fmt.Printf(
+			" %-19s | %-5s | %-21s | %-21s | %-13s | %-4d \n",
+			rec.EntryDate, rec.Protocol,
+			fmt.Sprintf("%s:%d", rec.FromIP, rec.FromPort),
+			fmt.Sprintf("%s:%d", rec.ToIP, rec.ToPort),
+			rec.Reason, rec.RuleNum,
+		)
+	}
+}
+
+//auditFlush : remove all entries from the audit-log
+func auditFlush(c *cli.Context) {
+	if err := st.FlushAuditLog(); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("Audit-Log Flushed...")
+}
+
+//auditPrune : remove entries older than the given age from the audit-log
+func auditPrune(c *cli.Context) {
+	age := c.Duration("age")
+	if age < 0 {
+		cliError(c, "Flag: \"age\" value is INVALID! (0 or more)")
+	}
+	n, err := st.PruneAuditLog(time.Now().Add(-age))
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Printf("Audit-Log Pruned... (%d entries removed)\n", n)
+}
@@ -3,44 +3,98 @@ package cli
 import (
 	"fmt"
 	"net"
+	"os"
+	"strings"
+
+	"goaway2"
+	"goaway2/geoip"
+	"goaway2/store"
 
 	cli "gopkg.in/urfave/cli.v1"
 )
 
 /***Variables***/
 
-type blacklistRecord struct {
-	IPAddress string
-	LastSeen  string
-	EntryDate string
+var blacklistAppendArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "ipaddress, ip",
+		Usage: "the ip-address or autonomous system (e.g. AS64512) you want to blacklist",
+	},
+	listAppendRules[1],
+	cli.StringFlag{
+		Name:  "country",
+		Usage: "the country (iso code e.g. RU) whose addresses you want to blacklist instead of an ip-address",
+	},
+}
+var blacklistRemoveArgs = []cli.Flag{
+	blacklistAppendArgs[0],
+	blacklistAppendArgs[2],
 }
 
 /***Functions***/
 
-//blacklistAppend : append given ip-address to blackist
+//blacklistGetGeoEntry : collect optional country or autonomous system (AS00 as ip-address) and return its blacklist entry
+func blacklistGetGeoEntry(c *cli.Context) string {
+	code, ip := strings.ToUpper(c.String("country")), c.String("ipaddress")
+	switch {
+	case code != "" && ip != "":
+		cliError(c, "Flags: \"ipaddress\" and \"country\" must not be used at once!")
+	case code != "":
+		if err := geoip.CheckCountry(code); err != nil {
+			cliError(c, fmt.Sprintf("Flag: \"country\" value is INVALID! (%s)", err.Error()))
+		}
+		return goaway2.CountryEntry(code)
+	case geoip.IsASN(ip):
+		number, _ := geoip.ParseASN(ip)
+		return number
+	}
+	return ""
+}
+
+//blacklistAppend : append given ip-address, country or autonomous system to blackist
 func blacklistAppend(c *cli.Context) {
 	// get variables
-	ip := getIPWithDuplicate(c, "blacklist")
-	// ensure ip is not a range
-	if _, _, err := net.ParseCIDR(ip); err == nil {
-		cliError(c, "Flag: \"ipaddress\" must not be an IP-Range!")
+	ip := blacklistGetGeoEntry(c)
+	if ip == "" {
+		ip = getIPWithDuplicate(c, store.Blacklist)
+		// ensure ip is not a range
+		if _, _, err := net.ParseCIDR(ip); err == nil {
+			cliError(c, "Flag: \"ipaddress\" must not be an IP-Range!")
+		}
+	} else {
+		exists, err := st.HasEntry(store.Blacklist, ip)
+		if err != nil {
+			cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+		}
+		if exists {
+			fmt.Printf("Entry: %q is already within table: %q", ip, store.Blacklist)
+			os.Exit(0)
+		}
+		if geoip.IsASN(ip) {
+			warnDatabase("asn", geoip.ASNFiles)
+		} else {
+			warnDatabase("country", geoip.CountryFiles)
+		}
 	}
 	reason := c.String("reason")
 	if reason == "" {
 		cliError(c, "Flag: \"reason\" must not be blank!")
 	}
 	// run append
-	if _, err := db.Exec("INSERT INTO blacklist VALUES(?,datetime('now'),datetime('now'),?,0);", ip, reason); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.AddEntry(store.Blacklist, store.Entry{IPAddress: ip, Reason: reason})
+	})
 	fmt.Println("Entry added to blacklist")
 }
 
-//blacklistRemove : remove given ip-address from blacklist
+//blacklistRemove : remove given ip-address, country or autonomous system from blacklist
 func blacklistRemove(c *cli.Context) {
-	ip := getIP(c, "ipaddress")
+	ip := blacklistGetGeoEntry(c)
+	if ip == "" {
+		ip = getIP(c, "ipaddress")
+	}
 	// run delete
-	if _, err := db.Exec("DELETE FROM blacklist WHERE IPAddress=?;", ip); err != nil {
+	if err := st.RemoveEntry(store.Blacklist, ip); err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
 	fmt.Println("Entry removed from blacklist")
@@ -48,20 +102,14 @@ func blacklistRemove(c *cli.Context) {
 
 //blacklistDisplay: display all ip-addresses in blacklist
 func blacklistDisplay(c *cli.Context) {
-	rows, err := db.Query("SELECT IPAddress,LastSeen,EntryDate FROM blacklist WHERE LogicalDelete=0")
+	entries, err := st.Entries(store.Blacklist)
 	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
-	var counter int
-	var rec *blacklistRecord
 	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
 	fmt.Println("   #   |   IP-Address    |      LastSeen       |      EntryDate      ")
 	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
-	for rows.Next() {
-		rec = new(blacklistRecord)
-		rows.Scan(&rec.IPAddress, &rec.LastSeen, &rec.EntryDate)
+	for counter, rec := range entries {
 		fmt.Printf(" %-5d | %-15s | %-15s | %s \n", counter, rec.IPAddress, rec.LastSeen, rec.EntryDate)
-		counter++
 	}
-	rows.Close()
 }
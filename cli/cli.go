@@ -2,9 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"os"
 
+	"goaway2/store"
+
 	cli "gopkg.in/urfave/cli.v1"
 )
 
@@ -26,6 +29,14 @@ var listRemoveRules = []cli.Flag{
 	listAppendRules[0],
 }
 
+//globalArgs : flags accepted before any command
+var globalArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "db",
+		Usage: "firewall database (default: $" + store.PathEnv + " or " + store.DefaultPath + ")",
+	},
+}
+
 var commands = cli.Commands{
 	//rule commands
 	{
@@ -58,6 +69,13 @@ var commands = cli.Commands{
 				Action:  rulesDelete,
 				Flags:   rulesRemoveArgs,
 			},
+			// place rule in or out of audit mode
+			{
+				Name:   "audit",
+				Usage:  "record packets an existing rule would drop instead of dropping them",
+				Action: rulesAudit,
+				Flags:  rulesAuditArgs,
+			},
 			// flush all rules from chain
 			{
 				Name:   "flush",
@@ -76,18 +94,37 @@ var commands = cli.Commands{
 		Subcommands: cli.Commands{
 			{
 				Name:    "allow",
-				Usage:   "set inbound/outbound's default to allow packets",
+				Usage:   "set inbound/outbound/forward's default to allow packets",
 				Aliases: []string{"a"},
 				Action:  ruleoptsAllow,
 				Flags:   ruleoptsAllowArgs,
 			},
 			{
 				Name:    "deny",
-				Usage:   "set inbound/outbound's default to deny packets",
+				Usage:   "set inbound/outbound/forward's default to deny packets",
 				Aliases: []string{"d"},
 				Action:  ruleoptsDeny,
 				Flags:   ruleoptsDenyArgs,
 			},
+			{
+				Name:   "ask",
+				Usage:  "set outbound's default to ask about packets no rule decided",
+				Action: ruleoptsAsk,
+				Flags:  ruleoptsAskArgs,
+			},
+			{
+				Name:    "blacklist",
+				Usage:   "set the verdict of blacklisted sources (drop/tarpit)",
+				Aliases: []string{"b"},
+				Action:  ruleoptsBlacklist,
+				Flags:   ruleoptsBlacklistArgs,
+			},
+			{
+				Name:   "audit",
+				Usage:  "place the whole firewall in or out of audit mode",
+				Action: ruleoptsAudit,
+				Flags:  ruleoptsAuditArgs,
+			},
 		},
 	},
 	// whitelist commands
@@ -122,46 +159,337 @@ var commands = cli.Commands{
 		Subcommands: cli.Commands{
 			{
 				Name:    "append",
-				Usage:   "append an ip-address to the blacklist",
+				Usage:   "append an ip-address or country to the blacklist",
 				Aliases: []string{"app"},
 				Action:  blacklistAppend,
-				Flags:   listAppendRules,
+				Flags:   blacklistAppendArgs,
 			},
 			{
 				Name:    "remove",
-				Usage:   "remove an ip-address from the blacklist",
+				Usage:   "remove an ip-address or country from the blacklist",
 				Aliases: []string{"rem"},
 				Action:  blacklistRemove,
-				Flags:   listRemoveRules,
+				Flags:   blacklistRemoveArgs,
+			},
+		},
+	},
+	// feed commands
+	{
+		Name:   "feeds",
+		Usage:  "modify blocklist feeds loaded into a separate blacklist layer",
+		Action: feedsDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "add",
+				Usage:  "register a blocklist feed of a file, directory or http mirror",
+				Action: feedsAdd,
+				Flags:  feedsAddArgs,
+			},
+			{
+				Name:    "remove",
+				Usage:   "remove a blocklist feed",
+				Aliases: []string{"rem"},
+				Action:  feedsRemove,
+				Flags:   feedsRemoveArgs,
+			},
+			{
+				Name:   "refresh",
+				Usage:  "fetch blocklist feeds now and record their entry counts",
+				Action: feedsRefresh,
+				Flags:  feedsRefreshArgs,
+			},
+		},
+	},
+	// knock commands
+	{
+		Name:   "knocks",
+		Usage:  "modify knock profiles that open guarded ports on demand",
+		Action: knocksDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "add",
+				Usage:  "guard a port until its source knocks a sequence of ports",
+				Action: knocksAdd,
+				Flags:  knocksAddArgs,
+			},
+			{
+				Name:    "remove",
+				Usage:   "remove a knock profile",
+				Aliases: []string{"rem"},
+				Action:  knocksRemove,
+				Flags:   knocksRemoveArgs,
+			},
+		},
+	},
+	// single packet authorization commands
+	{
+		Name:   "spa",
+		Usage:  "modify spa profiles that open guarded ports for hmac signed udp packets",
+		Action: spaDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "add",
+				Usage:  "guard ports until their source sends a signed spa packet",
+				Action: spaAdd,
+				Flags:  spaAddArgs,
+			},
+			{
+				Name:    "remove",
+				Usage:   "remove a spa profile",
+				Aliases: []string{"rem"},
+				Action:  spaRemove,
+				Flags:   spaRemoveArgs,
+			},
+		},
+	},
+	{
+		Name:   "knock",
+		Usage:  "send a spa packet asking a remote firewall to open a port",
+		Action: knockSend,
+		Flags:  knockArgs,
+	},
+	// honeypot commands
+	{
+		Name:   "honeypot",
+		Usage:  "modify honeypot ports that blacklist every source touching them",
+		Action: honeypotDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "add",
+				Usage:  "turn an unused port into a honeypot",
+				Action: honeypotAdd,
+				Flags:  honeypotAddArgs,
+			},
+			{
+				Name:    "remove",
+				Usage:   "remove a honeypot",
+				Aliases: []string{"rem"},
+				Action:  honeypotRemove,
+				Flags:   honeypotPortArgs,
+			},
+			{
+				Name:    "list",
+				Usage:   "display all honeypots",
+				Aliases: []string{"ls"},
+				Action:  honeypotDisplay,
 			},
 		},
 	},
+	// zone commands
+	{
+		Name:    "zones",
+		Aliases: []string{"z"},
+		Usage:   "modify zones that network interfaces are bound to",
+		Action:  zonesDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "add",
+				Usage:  "create a zone with its own defaults",
+				Action: zonesAdd,
+				Flags:  zonesAddArgs,
+			},
+			{
+				Name:    "remove",
+				Usage:   "remove a zone without rules",
+				Aliases: []string{"rem"},
+				Action:  zonesRemove,
+				Flags:   zonesRemoveArgs,
+			},
+			{
+				Name:   "set",
+				Usage:  "set the inbound/outbound defaults of a zone",
+				Action: zonesSet,
+				Flags:  zonesSetArgs,
+			},
+			{
+				Name:   "bind",
+				Usage:  "bind a network interface to a zone",
+				Action: zonesBind,
+				Flags:  zonesBindArgs,
+			},
+			{
+				Name:   "unbind",
+				Usage:  "remove the zone binding of a network interface",
+				Action: zonesUnbind,
+				Flags:  zonesUnbindArgs,
+			},
+		},
+	},
+	// nat commands
+	{
+		Name:   "nat",
+		Usage:  "modify port forwards and masquerading of a gateway",
+		Action: natDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:   "forward",
+				Usage:  "forward an external port to an internal host",
+				Action: natForward,
+				Flags:  natForwardArgs,
+			},
+			{
+				Name:   "unforward",
+				Usage:  "remove the forward of an external port",
+				Action: natUnforward,
+				Flags:  natUnforwardArgs,
+			},
+			{
+				Name:   "masquerade",
+				Usage:  "masquerade packets leaving an interface",
+				Action: natMasquerade,
+				Flags:  natMasqueradeArgs,
+			},
+			{
+				Name:   "unmasquerade",
+				Usage:  "stop masquerading packets leaving an interface",
+				Action: natUnmasquerade,
+				Flags:  natUnmasqueradeArgs,
+			},
+			{
+				Name:   "apply",
+				Usage:  "install the nat configuration into the kernel again (e.g. after a reboot)",
+				Action: natApply,
+			},
+		},
+	},
+	// daemon state commands
+	{
+		Name:   "addresses",
+		Usage:  "display the local addresses the running daemon detects direction with",
+		Action: addressesDisplay,
+	},
+	{
+		Name:   "prompt",
+		Usage:  "answer prompts of the running daemon about unknown outbound connections",
+		Action: promptRun,
+		Flags:  promptArgs,
+		Subcommands: cli.Commands{
+			{
+				Name:   "answers",
+				Usage:  "display the answers of prompts answered forever",
+				Action: promptAnswers,
+			},
+			{
+				Name:   "forget",
+				Usage:  "forget a prompt answered forever, its connections are prompted again",
+				Action: promptForget,
+				Flags:  promptForgetArgs,
+			},
+		},
+	},
+	// profile commands
+	{
+		Name:    "profiles",
+		Aliases: []string{"p"},
+		Usage:   "display application profiles rules may refer to",
+		Action:  profilesDisplay,
+		Subcommands: cli.Commands{
+			{
+				Name:      "info",
+				Usage:     "display the ports of an application profile or service",
+				ArgsUsage: "[name]",
+				Action:    profilesInfo,
+			},
+		},
+	},
+	// audit-log commands
+	{
+		Name:    "audit",
+		Aliases: []string{"a"},
+		Usage:   "display packets that would have been dropped",
+		Action:  auditDisplay,
+		Flags:   auditDisplayArgs,
+		Subcommands: cli.Commands{
+			{
+				Name:   "flush",
+				Usage:  "remove all entries from the audit-log",
+				Action: auditFlush,
+			},
+			{
+				Name:   "prune",
+				Usage:  "remove entries older than the given age from the audit-log",
+				Action: auditPrune,
+				Flags:  auditPruneArgs,
+			},
+		},
+	},
+	// policy import/export commands
+	{
+		Name:   "export",
+		Usage:  "export the complete firewall policy as a versioned file",
+		Action: policyExport,
+		Flags:  exportArgs,
+	},
+	{
+		Name:   "import",
+		Usage:  "import a versioned policy file by merging or replacing the current policy",
+		Action: policyImport,
+		Flags:  importArgs,
+	},
+	// change history commands
+	{
+		Name:   "history",
+		Usage:  "display recent changes made to the firewall policy",
+		Action: historyDisplay,
+		Flags:  historyDisplayArgs,
+	},
+	{
+		Name:      "undo",
+		Usage:     "revert the last n changes made to the firewall policy",
+		ArgsUsage: "[n]",
+		Action:    historyUndo,
+	},
+	// anti-lockout commands
+	{
+		Name:   "confirm",
+		Usage:  "keep provisional changes that would otherwise be rolled back",
+		Action: confirmChanges,
+	},
+	{
+		Name:   "rollback",
+		Usage:  "roll back provisional changes once their deadline passed",
+		Action: rollbackWatch,
+		Hidden: true,
+	},
+	// verdict simulation commands
+	{
+		Name:   "test",
+		Usage:  "simulate the firewall verdict for a hypothetical packet",
+		Action: testPacket,
+		Flags:  testArgs,
+	},
 }
 
 /***Functions***/
 
+//checkIP : verify validity of value as a ip-range/ip-address/any
+func checkIP(ip string) bool {
+	if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil && ip != "any" {
+		return false
+	}
+	return true
+}
+
 //getIP : collect given flag argument from context after verifying validity as a ip-range/ip-address/any
 func getIP(c *cli.Context, flag string) string {
 	var ip = c.String(flag)
-	if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil && ip != "any" {
+	if !checkIP(ip) {
 		cliError(c, fmt.Sprintf("Flag: \"%s\" value is INVALID! (any/ip/[a network class])", flag))
 	}
 	return ip
 }
 
-//getIPWithDuplicate : collect ip and vefity that the ip is not already contained within a table
-func getIPWithDuplicate(c *cli.Context, table string) string {
+//getIPWithDuplicate : collect ip and vefity that the ip is not already contained within a list
+func getIPWithDuplicate(c *cli.Context, list string) string {
 	// get variables
 	ip := getIP(c, "ipaddress")
-	var exists int
 	// check if ip already exists
-	if err := db.QueryRow(
-		"SELECT IFNULL((SELECT 1 FROM "+table+" WHERE IPAddress=?), 0)", ip,
-	).Scan(&exists); err != nil {
+	exists, err := st.HasEntry(list, ip)
+	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
-	if exists == 1 {
-		fmt.Printf("IP-Address: %q is already within table: %q", ip, table)
+	if exists {
+		fmt.Printf("IP-Address: %q is already within table: %q", ip, list)
 		os.Exit(0)
 	}
 	return ip
@@ -197,6 +525,33 @@ func Run() {
 	// help templates
 	cli.AppHelpTemplate = helpMainPage
 	cli.CommandHelpTemplate = helpCommandPage
-	// run app
+	app.Flags = globalArgs
+	app.Before = openDatabase
 	app.Run(os.Args)
+	if st != nil {
+		st.Close()
+	}
+}
+
+//openDatabase : open the database given by the global flags before any command is run
+func openDatabase(c *cli.Context) error {
+	// the knock client is run on other machines and needs no database
+	if c.Args().First() == "knock" {
+		return nil
+	}
+	var err error
+	if path := c.GlobalString("db"); path != "" {
+		st, err = store.Open(path)
+	} else {
+		st, err = store.OpenDefault()
+	}
+	if err != nil {
+		log.Fatalf("Unable to open database: %s\n", err.Error())
+	}
+	st.SetUser(currentUser())
+	// roll back provisional changes that were never confirmed
+	if err = expirePending(); err != nil {
+		fmt.Printf("Unable to roll back unconfirmed changes! SQL-Error: %s\n", err.Error())
+	}
+	return nil
 }
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"goaway2/importer"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//ufwDefaultsFile : location of ufw's default policies (default/ufw next to the parent of a given rules directory)
+const ufwDefaultsFile = "/etc/default/ufw"
+
+//ufwRulesDir : default location of ufw's user rules
+const ufwRulesDir = "/etc/ufw"
+
+/***Functions***/
+
+//convertFile : parse the given file with the given parser
+func convertFile(parse func(io.Reader, string, *importer.Policy) error, path string, p *importer.Policy) error {
+	// read from stdin when no path is given
+	if path == "" || path == "-" {
+		return parse(os.Stdin, "stdin", p)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parse(f, path, p)
+}
+
+//policyConvert : collect policy from a foreign firewall configuration and report untranslated constructs
+func policyConvert(c *cli.Context, from string) *policyFile {
+	var err error
+	foreign := new(importer.Policy)
+	switch from {
+	case "ufw":
+		dir, defaults := c.String("file"), c.String("defaults")
+		if dir == "" {
+			dir = ufwRulesDir
+		}
+		// the defaults of rules taken from elsewhere (e.g. a copied /etc) are read from the same tree
+		if defaults == "" {
+			defaults = ufwDefaultsFile
+			if dir != ufwRulesDir {
+				defaults = filepath.Join(filepath.Dir(filepath.Clean(dir)), "default", "ufw")
+			}
+		}
+		err = convertFile(importer.ParseUFWDefaults, defaults, foreign)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Printf("No ufw defaults at %q, keeping the current defaults (see --defaults)\n", defaults)
+		case err != nil:
+			cliError(c, fmt.Sprintf("Unable to read ufw defaults: %s", err.Error()))
+		}
+		for _, name := range []string{"user.rules", "user6.rules"} {
+			if err = convertFile(importer.ParseUFWRules, filepath.Join(dir, name), foreign); err != nil {
+				cliError(c, fmt.Sprintf("Unable to read ufw rules: %s", err.Error()))
+			}
+		}
+	case "iptables-save":
+		if err = convertFile(importer.ParseIPTablesSave, c.String("file"), foreign); err != nil {
+			cliError(c, fmt.Sprintf("Unable to read iptables-save dump: %s", err.Error()))
+		}
+	}
+	current, err := policyCollect()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	p := convertPolicy(foreign, from, current.Defaults)
+	// report everything that could not be translated
+	if len(foreign.Skipped) > 0 {
+		fmt.Printf("Untranslated constructs (%d):\n", len(foreign.Skipped))
+		for _, skipped := range foreign.Skipped {
+			fmt.Printf("  %s\n", skipped)
+		}
+	}
+	return p
+}
+
+//convertPolicy : build policy from a foreign configuration keeping the current defaults for any that were not found
+// (every rule keeps its allow/deny action so it is decided first-match regardless of the defaults)
+func convertPolicy(foreign *importer.Policy, from string, current policyDefault) *policyFile {
+	p := &policyFile{Version: policyVersion, Defaults: current}
+	if foreign.Inbound != "" {
+		p.Defaults.Inbound = foreign.Inbound
+	}
+	if foreign.Outbound != "" {
+		p.Defaults.Outbound = foreign.Outbound
+	}
+	if foreign.Forward != "" {
+		p.Defaults.Forward = foreign.Forward
+	}
+	// convert rules and blacklist entries
+	for _, r := range foreign.Translate(p.Defaults.Inbound, p.Defaults.Outbound, p.Defaults.Forward) {
+		p.Rules = append(p.Rules, policyRule{
+			Zone:     r.Zone,
+			FromIP:   r.FromIP,
+			FromPort: r.FromPort,
+			ToIP:     r.ToIP,
+			ToPort:   r.ToPort,
+			Action:   r.Action,
+		})
+	}
+	for _, ip := range foreign.Blacklist {
+		p.Blacklist = append(p.Blacklist, policyEntry{IPAddress: ip, Reason: "imported from " + from})
+	}
+	return p
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"goaway2"
+	"goaway2/importer"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Unit-Tests***/
+
+func TestConvertUFWDecide(t *testing.T) {
+	openPolicyStore(t)
+	defer st.Close()
+	foreign := new(importer.Policy)
+	dir := filepath.Join("..", "importer", "testdata", "ufw")
+	if err := convertFile(importer.ParseUFWDefaults, filepath.Join(dir, "ufw"), foreign); err != nil {
+		t.Fatalf("Unable to read ufw defaults: %s\n", err.Error())
+	}
+	for _, name := range []string{"user.rules", "user6.rules"} {
+		if err := convertFile(importer.ParseUFWRules, filepath.Join(dir, name), foreign); err != nil {
+			t.Fatalf("Unable to read ufw rules: %s\n", err.Error())
+		}
+	}
+	current, err := policyCollect()
+	if err != nil {
+		t.Fatalf("Unable to collect policy: %s\n", err.Error())
+	}
+	p := convertPolicy(foreign, "ufw", current.Defaults)
+	for n, r := range p.Rules {
+		if r.Action != "allow" && r.Action != "deny" {
+			t.Fatalf("Converted rule #%d has no action: %+v\n", n, r)
+		}
+	}
+	if err = policyCheck(p); err != nil {
+		t.Fatalf("Converted policy is invalid: %s\n", err.Error())
+	}
+	if err = policyApply(st, p, true); err != nil {
+		t.Fatalf("Unable to apply converted policy: %s\n", err.Error())
+	}
+	// check the converted policy decides packets like ufw (deny inbound except the allowed ports)
+	fw := goaway2.NewFirewall(st)
+	for _, check := range []struct {
+		hook    string
+		port    int64
+		verdict netfilter.Verdict
+	}{
+		{goaway2.HookInput, 22, netfilter.NF_ACCEPT},
+		{goaway2.HookInput, 443, netfilter.NF_ACCEPT},
+		{goaway2.HookInput, 23, netfilter.NF_DROP},
+		{goaway2.HookOutput, 25, netfilter.NF_DROP},
+		{goaway2.HookOutput, 443, netfilter.NF_ACCEPT},
+	} {
+		pkt := &goaway2.PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.0.2.10", DstPort: check.port, Protocol: "TCP", Hook: check.hook}
+		if check.hook == goaway2.HookOutput {
+			pkt.SrcIP, pkt.DstIP = pkt.DstIP, pkt.SrcIP
+		}
+		if d := fw.Decide(goaway2.NewRedBlackKV(), pkt); d.Verdict != check.verdict {
+			t.Fatalf("Unexpected decision of %s port %d: %+v\n", check.hook, check.port, d)
+		}
+	}
+}
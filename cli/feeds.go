@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"goaway2"
+	"goaway2/feeds"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//feedsName : valid feed name
+var feedsName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var feedsNameArg = cli.StringFlag{
+	Name:  "name, n",
+	Usage: "the name of the feed",
+}
+var feedsAddArgs = []cli.Flag{
+	feedsNameArg,
+	cli.StringFlag{
+		Name:  "source, s",
+		Usage: "absolute path of a list file/directory or http(s) url of a mirror",
+	},
+	cli.DurationFlag{
+		Name:  "interval, i",
+		Value: time.Hour,
+		Usage: "time between refreshes of the feed (at least 1m)",
+	},
+}
+var feedsRemoveArgs = []cli.Flag{
+	feedsNameArg,
+}
+var feedsRefreshArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "name, n",
+		Usage: "the name of the feed to refresh (default: all feeds)",
+	},
+}
+
+/***Functions***/
+
+//feedsGetName : collect feed name from the given flag and verify the feed exists
+func feedsGetName(c *cli.Context, flag string) store.Feed {
+	name := c.String(flag)
+	f, exists, err := st.Feed(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !exists {
+		cliError(c, fmt.Sprintf("Feed: %q does not exist!", name))
+	}
+	return f
+}
+
+//feedsLoad : load all registered feeds into the given layer, warning about feeds that fail to load
+func feedsLoad(c *cli.Context, layer *feeds.Layer) {
+	registered, err := st.Feeds()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	for _, f := range registered {
+		ranges, err := feeds.Load(nil, f.Name, f.Source)
+		if err != nil {
+			fmt.Printf("WARNING: unable to load feed %q: %s\n", f.Name, err.Error())
+			continue
+		}
+		layer.Set(f.Name, ranges)
+	}
+}
+
+//feedsAdd : register a new blocklist feed and load it once
+func feedsAdd(c *cli.Context) {
+	f := store.Feed{Name: c.String("name"), Source: c.String("source")}
+	if !feedsName.MatchString(f.Name) {
+		cliError(c, "Flag: \"name\" value is INVALID! (letters/digits/./-/_)")
+	}
+	if !feeds.IsURL(f.Source) && !filepath.IsAbs(f.Source) {
+		cliError(c, "Flag: \"source\" value is INVALID! (absolute path or http(s) url)")
+	}
+	interval := c.Duration("interval")
+	if interval < time.Minute {
+		cliError(c, "Flag: \"interval\" value is INVALID! (at least 1m)")
+	}
+	f.Interval = int64(interval / time.Second)
+	_, exists, err := st.Feed(f.Name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if exists {
+		fmt.Printf("Feed: %q already exists", f.Name)
+		return
+	}
+	// sources that are not available yet are retried by the daemon on every interval
+	ranges, loadErr := feeds.Load(nil, f.Name, f.Source)
+	if loadErr != nil {
+		fmt.Printf("WARNING: unable to load feed: %s\n", loadErr.Error())
+	}
+	// feeds listing the callers address lock them out so the change is provisional
+	layer := feeds.NewLayer()
+	layer.Set(f.Name, ranges)
+	session := currentSession()
+	risky := session != nil && layer.Lookup(session.clientIP) != ""
+	if risky {
+		fmt.Printf("WARNING: feed lists the address of your ssh connection (%s)!\n", session.clientIP)
+	}
+	guardChange(c, risky, func(tx *store.Store) error {
+		if err := tx.AddFeed(f); err != nil {
+			return err
+		}
+		return tx.SetFeedStatus(f.Name, int64(len(ranges)), loadErr)
+	})
+	fmt.Printf("Feed Added... (%d entries)\n", len(ranges))
+}
+
+//feedsRemove : remove a blocklist feed
+func feedsRemove(c *cli.Context) {
+	f := feedsGetName(c, "name")
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.RemoveFeed(f.Name)
+	})
+	fmt.Println("Feed Removed...")
+}
+
+//feedsRefresh : fetch feeds now and record their entry counts (the daemon loads them within a minute)
+func feedsRefresh(c *cli.Context) {
+	var (
+		registered []store.Feed
+		err        error
+	)
+	if c.String("name") != "" {
+		registered = []store.Feed{feedsGetName(c, "name")}
+	} else if registered, err = st.Feeds(); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	updater := &goaway2.FeedUpdater{Store: st}
+	for _, f := range registered {
+		n, err := updater.Refresh(f)
+		if err != nil {
+			fmt.Printf("Feed: %s FAILED: %s\n", f.Name, err.Error())
+			continue
+		}
+		fmt.Printf("Feed: %s refreshed (%d entries)\n", f.Name, n)
+	}
+}
+
+//feedsDisplay : display all feeds along with their entry counts and last refresh
+func feedsDisplay(c *cli.Context) {
+	registered, err := st.Feeds()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("      Name      | Entries | Interval |    Last Refresh     |             Source            ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, f := range registered {
+		refreshed := f.LastRefresh
+		if refreshed == "" {
+			refreshed = "never"
+		}
+		interval := time.Duration(f.Interval) * time.Second
+		fmt.Printf(" %-14s | %7d | %-8s | %-19s | %s \n", f.Name, f.Entries, interval, refreshed, f.Source)
+		if f.LastError != "" {
+			fmt.Printf("   last refresh FAILED: %s\n", f.LastError)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var historyDisplayArgs = []cli.Flag{
+	cli.IntFlag{
+		Name:  "limit, l",
+		Value: 20,
+		Usage: "maximum number of recent change batches to display",
+	},
+}
+
+/***Functions***/
+
+//currentUser : collect the name of the user running the cli (the invoking user when run via sudo)
+func currentUser() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+//historyDisplay : display the most recent changes made to the policy
+func historyDisplay(c *cli.Context) {
+	changes, err := st.History(c.Int("limit"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println(" Batch |      EntryDate      |     User     |   Table   |  Action  | Change ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, ch := range changes {
+		summary := ch.Summary()
+		if ch.Undone {
+			summary += " (undone)"
+		}
+		fmt.Printf(
+			" %-5d | %-19s | %-12s | %-9s | %-8s | %s \n",
+			ch.Batch, ch.EntryDate, ch.User, ch.Table, ch.Action, summary,
+		)
+	}
+}
+
+//historyUndo : revert the last n change batches made to the policy
+func historyUndo(c *cli.Context) {
+	n := 1
+	if arg := c.Args().First(); arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 1 {
+			cliError(c, fmt.Sprintf("Argument: %q is INVALID! (number of changes to undo)", arg))
+		}
+	}
+	changes, err := st.Undo(n)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to undo...")
+		return
+	}
+	natReinstall(changes)
+	for _, ch := range changes {
+		fmt.Printf("Undone: batch %d %s %s (%s)\n", ch.Batch, ch.Table, ch.Action, ch.Summary())
+	}
+}
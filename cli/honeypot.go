@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"goaway2"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var honeypotPortArgs = []cli.Flag{
+	cli.Int64Flag{
+		Name:  "port, p",
+		Usage: "the unused port (e.g. 23 or 3389)",
+	},
+	cli.StringFlag{
+		Name:  "protocol, P",
+		Value: "tcp",
+		Usage: "the protocol of the port (tcp/udp)",
+	},
+}
+var honeypotAddArgs = append(honeypotPortArgs,
+	cli.DurationFlag{
+		Name:  "expiry, e",
+		Value: 24 * time.Hour,
+		Usage: "time the source stays blacklisted (0 never expires)",
+	},
+	cli.BoolFlag{
+		Name:  "unverified, u",
+		Usage: "blacklist the source of the first packet instead of a completed tcp handshake (required for udp, spoofed sources get blacklisted)",
+	},
+)
+
+/***Functions***/
+
+//honeypotGetPort : collect port and protocol flags after verifying them
+func honeypotGetPort(c *cli.Context) (int64, string) {
+	port, protocol := c.Int64("port"), strings.ToLower(c.String("protocol"))
+	if port < 1 || port > 65535 {
+		cliError(c, "Flag: \"port\" value is INVALID! (1-65535)")
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		cliError(c, "Flag: \"protocol\" value is INVALID! (tcp/udp)")
+	}
+	return port, protocol
+}
+
+//honeypotAdd : turn an unused port into a honeypot blacklisting every source touching it
+func honeypotAdd(c *cli.Context) {
+	h := store.Honeypot{}
+	h.Port, h.Protocol = honeypotGetPort(c)
+	expiry := c.Duration("expiry")
+	if expiry != 0 && expiry < time.Second {
+		cliError(c, "Flag: \"expiry\" value is INVALID! (0 or at least 1s)")
+	}
+	h.Expiry, h.Unverified = int64(expiry/time.Second), c.Bool("unverified")
+	// the source of a udp packet can not be verified
+	if h.Protocol == "udp" && !h.Unverified {
+		cliError(c, "Flag: \"unverified\" is REQUIRED for udp honeypots! (their sources may be spoofed)")
+	}
+	_, exists, err := st.Honeypot(h.Port, h.Protocol)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if exists {
+		fmt.Printf("Honeypot: %d/%s already exists", h.Port, h.Protocol)
+		return
+	}
+	// a honeypot on the port of the callers ssh connection is detected as a lockout and made provisional
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.AddHoneypot(h)
+	})
+	fmt.Println("Honeypot Added...")
+}
+
+//honeypotRemove : remove a honeypot, leaving its port to the rules again
+func honeypotRemove(c *cli.Context) {
+	port, protocol := honeypotGetPort(c)
+	_, exists, err := st.Honeypot(port, protocol)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !exists {
+		cliError(c, fmt.Sprintf("Honeypot: %d/%s does not exist!", port, protocol))
+	}
+	if err = st.RemoveHoneypot(port, protocol); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("Honeypot Removed...")
+}
+
+//honeypotDisplay : display all honeypots
+func honeypotDisplay(c *cli.Context) {
+	honeypots, err := st.Honeypots()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("    Port    |   Expiry   |    Trips on   |      Reason        ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, h := range honeypots {
+		expiry := "never"
+		if h.Expiry > 0 {
+			expiry = (time.Duration(h.Expiry) * time.Second).String()
+		}
+		trip := "handshake"
+		if h.Unverified {
+			trip = "first packet"
+		}
+		fmt.Printf(" %5d/%-4s | %-10s | %-13s | %s \n", h.Port, h.Protocol, expiry, trip, goaway2.HoneypotReason(h.Port))
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"goaway2"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//knocksName : valid knock profile name
+var knocksName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var knocksNameArg = cli.StringFlag{
+	Name:  "name, n",
+	Usage: "the name of the knock profile",
+}
+var knocksAddArgs = []cli.Flag{
+	knocksNameArg,
+	cli.StringFlag{
+		Name:  "sequence, s",
+		Usage: "ports knocked in order (e.g. 7000/tcp,8000/udp,9000/tcp)",
+	},
+	cli.StringFlag{
+		Name:  "target, t",
+		Usage: "port opened for the knocking source (e.g. 22/tcp)",
+	},
+	cli.DurationFlag{
+		Name:  "window, w",
+		Value: 10 * time.Second,
+		Usage: "time the whole sequence must be knocked within",
+	},
+	cli.DurationFlag{
+		Name:  "duration, d",
+		Value: 5 * time.Minute,
+		Usage: "time the target port stays open for new connections",
+	},
+}
+var knocksRemoveArgs = []cli.Flag{
+	knocksNameArg,
+}
+
+/***Functions***/
+
+//knocksGetDuration : collect duration flag in whole seconds after verifying it is at least a second
+func knocksGetDuration(c *cli.Context, flag string) int64 {
+	value := c.Duration(flag)
+	if value < time.Second {
+		cliError(c, fmt.Sprintf("Flag: %q value is INVALID! (at least 1s)", flag))
+	}
+	return int64(value / time.Second)
+}
+
+//knocksAdd : create a knock profile guarding its target port
+func knocksAdd(c *cli.Context) {
+	k := store.Knock{Name: c.String("name")}
+	if !knocksName.MatchString(k.Name) {
+		cliError(c, "Flag: \"name\" value is INVALID! (letters/digits/-/_)")
+	}
+	sequence, err := goaway2.ParseKnockSequence(c.String("sequence"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"sequence\" value is INVALID! (%s)", err.Error()))
+	}
+	target, err := goaway2.ParseKnockPort(c.String("target"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"target\" value is INVALID! (%s)", err.Error()))
+	}
+	for _, port := range sequence {
+		if port == target {
+			cliError(c, "Flag: \"sequence\" value is INVALID! (must not contain the target port)")
+		}
+	}
+	k.Sequence, k.Target = goaway2.FormatKnockPorts(sequence), target.String()
+	k.Window, k.Duration = knocksGetDuration(c, "window"), knocksGetDuration(c, "duration")
+	_, exists, err := st.Knock(k.Name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if exists {
+		fmt.Printf("Knock: %q already exists", k.Name)
+		return
+	}
+	// guarding the port of the callers ssh connection is detected as a lockout and made provisional
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.AddKnock(k)
+	})
+	fmt.Println("Knock Added...")
+}
+
+//knocksRemove : remove a knock profile, opening its target port to the rules again
+func knocksRemove(c *cli.Context) {
+	name := c.String("name")
+	_, exists, err := st.Knock(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !exists {
+		cliError(c, fmt.Sprintf("Knock: %q does not exist!", name))
+	}
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.RemoveKnock(name)
+	})
+	fmt.Println("Knock Removed...")
+}
+
+//knocksDisplay : display all knock profiles
+func knocksDisplay(c *cli.Context) {
+	knocks, err := st.Knocks()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("      Name      |  Target   |  Window  | Duration |          Sequence           ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, k := range knocks {
+		window, duration := time.Duration(k.Window)*time.Second, time.Duration(k.Duration)*time.Second
+		fmt.Printf(" %-14s | %-9s | %-8s | %-8s | %s \n", k.Name, k.Target, window, duration, k.Sequence)
+	}
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"goaway2"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//confirmTimeout : time given to confirm a risky change before it is rolled back
+const confirmTimeout = 60 * time.Second
+
+//sshSession : ssh connection the cli is run through (collected from SSH_CONNECTION)
+type sshSession struct {
+	clientIP   string
+	clientPort int64
+	serverIP   string
+	serverPort int64
+}
+
+/***Functions***/
+
+//currentSession : collect the ssh connection of the caller (nil when not run over ssh)
+func currentSession() *sshSession {
+	return parseSession(os.Getenv("SSH_CONNECTION"))
+}
+
+//parseSession : convert ssh connection of the caller (ipv4 or ipv6) into a session (nil when malformed)
+func parseSession(connection string) *sshSession {
+	// formatted as: client-ip client-port server-ip server-port
+	fields := strings.Fields(connection)
+	if len(fields) != 4 || net.ParseIP(fields[0]) == nil || net.ParseIP(fields[2]) == nil {
+		return nil
+	}
+	clientPort, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	serverPort, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &sshSession{clientIP: fields[0], clientPort: clientPort, serverIP: fields[2], serverPort: serverPort}
+}
+
+//sessionMatchIP : check if rule ip-address/ip-range explicitly covers the given ip-address
+func sessionMatchIP(ruleIP, ip string) bool {
+	if _, iprange, err := net.ParseCIDR(ruleIP); err == nil {
+		return iprange.Contains(net.ParseIP(ip))
+	}
+	return ruleIP == ip
+}
+
+//sessionMatchPort : check if rule port/port-range explicitly covers the given port
+func sessionMatchPort(rulePort string, port int64) bool {
+	ints := strings.Split(rulePort, "-")
+	start, err := strconv.ParseInt(ints[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	end := start
+	if len(ints) == 2 {
+		if end, err = strconv.ParseInt(ints[1], 10, 64); err != nil {
+			return false
+		}
+	}
+	return start <= port && port <= end
+}
+
+//guardChange : apply change and make it provisional when it may lock the caller out
+// risky changes are rolled back automatically unless confirmed within the timeout
+func guardChange(c *cli.Context, risky bool, fn func(tx *store.Store) error) {
+	session := currentSession()
+	provisional := false
+	var pending store.Pending
+	err := st.Transaction(func(tx *store.Store) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		// warn when the callers own connection would be blocked (or cannot be checked)
+		if session != nil {
+			switch blocked, err := session.blocked(tx); {
+			case err != nil:
+				fmt.Printf("WARNING: unable to check if this change blocks your ssh connection: %s\n", err.Error())
+				risky = true
+			case blocked:
+				fmt.Printf(
+					"WARNING: this change blocks your ssh connection (%s:%d -> %s:%d)!\n",
+					session.clientIP, session.clientPort, session.serverIP, session.serverPort,
+				)
+				risky = true
+			}
+		}
+		if !risky {
+			return nil
+		}
+		var err error
+		pending, err = tx.Provisional(time.Now().Add(confirmTimeout))
+		provisional = true
+		return err
+	})
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !provisional {
+		return
+	}
+	if err = spawnRollback(); err != nil {
+		fmt.Printf("WARNING: unable to schedule rollback: %s\n", err.Error())
+	}
+	fmt.Printf(
+		"Change applied provisionally! Run \"goaway confirm\" before %s or it will be rolled back...\n",
+		pending.Deadline.Format("15:04:05"),
+	)
+}
+
+//spawnRollback : start detached process that rolls back unconfirmed changes after their deadline
+func spawnRollback() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "rollback")
+	// detach from the session so the rollback survives a dropped connection
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+//expirePending : roll back provisional changes whose deadline passed
+func expirePending() error {
+	for {
+		changes, err := st.Expire(time.Now())
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		natReinstall(changes)
+		fmt.Printf("Rolled back unconfirmed changes of batch %d...\n", changes[len(changes)-1].Batch)
+	}
+}
+
+//confirmChanges : keep all provisional changes
+func confirmChanges(c *cli.Context) {
+	confirmed, err := st.Confirm(time.Now())
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if confirmed == 0 {
+		fmt.Println("Nothing to confirm...")
+		return
+	}
+	fmt.Printf("Confirmed %d pending change(s)...\n", confirmed)
+}
+
+//rollbackWatch : wait for the deadlines of provisional changes and roll back unconfirmed ones
+func rollbackWatch(c *cli.Context) {
+	for {
+		pending, err := st.Pending()
+		if err != nil || len(pending) == 0 {
+			return
+		}
+		if wait := time.Until(pending[0].Deadline); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+		if err = expirePending(); err != nil {
+			return
+		}
+	}
+}
+
+//touchesSession : check if rule explicitly references the callers ssh connection
+func touchesSession(r store.Rule) bool {
+	session := currentSession()
+	return session != nil && session.touches(r)
+}
+
+/***Methods***/
+
+//(*sshSession).touches : check if rule explicitly references the sessions address or ports
+func (s *sshSession) touches(r store.Rule) bool {
+	return sessionMatchIP(r.FromIP, s.clientIP) || sessionMatchIP(r.ToIP, s.clientIP) ||
+		sessionMatchPort(r.ToPort, s.serverPort) || sessionMatchPort(r.FromPort, s.serverPort) ||
+		sessionMatchPort(r.FromPort, s.clientPort) || sessionMatchPort(r.ToPort, s.clientPort)
+}
+
+//(*sshSession).blocked : check if the firewall loaded from the store would drop the sessions packets
+// (hostnames of rules are not resolved, so the check needs no network access)
+func (s *sshSession) blocked(tx *store.Store) (bool, error) {
+	fw, err := goaway2.SimulateFirewall(tx)
+	if err != nil {
+		return false, err
+	}
+	kv := goaway2.NewRedBlackKV()
+	for _, pkt := range []*goaway2.PacketData{
+		{SrcIP: s.clientIP, SrcPort: s.clientPort, DstIP: s.serverIP, DstPort: s.serverPort, Protocol: "TCP", Hook: goaway2.HookInput},
+		{SrcIP: s.serverIP, SrcPort: s.serverPort, DstIP: s.clientIP, DstPort: s.clientPort, Protocol: "TCP", Hook: goaway2.HookOutput},
+	} {
+		pkt.ResolveInterfaces()
+		if fw.Decide(kv, pkt).Verdict == netfilter.NF_DROP {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"testing"
+
+	"goaway2/store"
+)
+
+/***Unit-Tests***/
+
+func TestSessionBlocked(t *testing.T) {
+	openPolicyStore(t)
+	defer st.Close()
+	sessions := []*sshSession{
+		parseSession("198.51.100.7 40000 192.0.2.10 22"),
+		parseSession("2001:db8::7 40000 2001:db8::10 22"),
+	}
+	if parseSession("198.51.100.7 40000 192.0.2.10") != nil {
+		t.Fatalf("Able to parse malformed ssh connection\n")
+	}
+	// check sessions are not blocked by the defaults of a fresh database
+	for _, s := range sessions {
+		if s == nil {
+			t.Fatalf("Unable to parse ssh connection\n")
+		}
+		if blocked, err := s.blocked(st); blocked || err != nil {
+			t.Fatalf("Session %s blocked by the defaults (%v)\n", s.clientIP, err)
+		}
+	}
+	// check rules denying the ssh port block ipv4 and ipv6 sessions alike
+	if err := st.AppendRule(store.Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", Action: "deny"}); err != nil {
+		t.Fatalf("Unable to add rule: %s\n", err.Error())
+	}
+	for _, s := range sessions {
+		if blocked, err := s.blocked(st); !blocked || err != nil {
+			t.Fatalf("Session %s not blocked by rule (%v)\n", s.clientIP, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"goaway2/nat"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var natIfaceArg = cli.StringFlag{
+	Name:  "iface, i",
+	Usage: "the external network interface (e.g. eth0)",
+}
+var natForwardArgs = []cli.Flag{
+	natIfaceArg,
+	cli.StringFlag{
+		Name:  "proto",
+		Value: "tcp",
+		Usage: "protocol of the forwarded port (tcp/udp)",
+	},
+	cli.StringFlag{
+		Name:  "port, p",
+		Usage: "the external port/port-range that is forwarded",
+	},
+	cli.StringFlag{
+		Name:  "to, t",
+		Usage: "the internal host and optional port the packets are forwarded to (ip[:port])",
+	},
+}
+var natUnforwardArgs = natForwardArgs[:3]
+var natMasqueradeArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "iface, i",
+		Usage: "the outbound network interface packets are masqueraded on (e.g. eth0)",
+	},
+	cli.StringFlag{
+		Name:  "source, s",
+		Value: "any",
+		Usage: "the source ip-addresses that are masqueraded (any/ip/[a network class])",
+	},
+}
+var natUnmasqueradeArgs = natMasqueradeArgs[:1]
+
+/***Functions***/
+
+//natGetPort : collect given flag argument from context after verifying its a port-number/port-range
+func natGetPort(c *cli.Context, flag string) string {
+	port := c.String(flag)
+	if port == "" || port == "any" || !checkPort(port) {
+		cliError(c, fmt.Sprintf("Flag: %q value is NOT an INTEGER or a INTEGER-RANGE! (00/00-00)", flag))
+	}
+	return port
+}
+
+//natGetProto : collect protocol of port forward after verifying its tcp/udp
+func natGetProto(c *cli.Context) string {
+	proto := strings.ToLower(c.String("proto"))
+	if proto != "tcp" && proto != "udp" {
+		cliError(c, "Flag: \"proto\" value is INVALID! (tcp/udp)")
+	}
+	return proto
+}
+
+//natGetTo : collect internal host and port of port forward
+func natGetTo(c *cli.Context) (string, string) {
+	to := c.String("to")
+	host, port := to, ""
+	if i := strings.LastIndex(to, ":"); i >= 0 {
+		host, port = to[:i], to[i+1:]
+		if port == "" || !checkPort(port) || port == "any" {
+			cliError(c, "Flag: \"to\" port is NOT an INTEGER or a INTEGER-RANGE! (ip[:00/00-00])")
+		}
+	}
+	if net.ParseIP(host) == nil {
+		cliError(c, "Flag: \"to\" value is INVALID! (ip[:port])")
+	}
+	return host, port
+}
+
+//natGetIface : collect interface name after verifying its valid
+func natGetIface(c *cli.Context) string {
+	iface := c.String("iface")
+	if iface == "" || !checkIface(iface) {
+		cliError(c, "Flag: \"iface\" value is INVALID! (interface name)")
+	}
+	return iface
+}
+
+//natInstall : install the stored nat configuration into the kernel
+func natInstall() {
+	if err := nat.Apply(st, &nat.IPTables{}); err != nil {
+		fmt.Printf("WARNING: unable to install nat configuration: %s\n", err.Error())
+	}
+}
+
+//natReinstall : install the nat configuration again when any of the given changes touched it
+func natReinstall(changes []store.Change) {
+	for _, ch := range changes {
+		if ch.Table == "nat" {
+			natInstall()
+			return
+		}
+	}
+}
+
+//natForward : forward an external port to an internal host
+func natForward(c *cli.Context) {
+	f := store.PortForward{
+		Interface: natGetIface(c),
+		Protocol:  natGetProto(c),
+		Port:      natGetPort(c, "port"),
+	}
+	f.ToIP, f.ToPort = natGetTo(c)
+	if err := st.AddPortForward(f); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	natInstall()
+	fmt.Println("Port Forwarded...")
+	// forwarded flows pass the FORWARD hook and are judged like any other forwarded packet
+	if opts, err := st.Options(); err == nil {
+		fmt.Printf("Forwarded packets are checked against the forward rules (forward default is %s)\n", opts.Forward)
+	}
+}
+
+//natUnforward : remove the forward of an external port
+func natUnforward(c *cli.Context) {
+	if err := st.RemovePortForward(natGetIface(c), natGetProto(c), natGetPort(c, "port")); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	natInstall()
+	fmt.Println("Port Forward Removed...")
+}
+
+//natMasquerade : masquerade packets leaving an interface
+func natMasquerade(c *cli.Context) {
+	m := store.Masquerade{Interface: natGetIface(c), Source: getIP(c, "source")}
+	if err := st.AddMasquerade(m); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	natInstall()
+	fmt.Println("Masquerade Added...")
+}
+
+//natUnmasquerade : stop masquerading packets leaving an interface
+func natUnmasquerade(c *cli.Context) {
+	if err := st.RemoveMasquerade(natGetIface(c)); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	natInstall()
+	fmt.Println("Masquerade Removed...")
+}
+
+//natApply : install the stored nat configuration again (e.g. after a reboot)
+func natApply(c *cli.Context) {
+	if err := nat.Apply(st, &nat.IPTables{}); err != nil {
+		cliError(c, fmt.Sprintf("NAT-ERROR: %s", err.Error()))
+	}
+	fmt.Println("NAT Installed...")
+}
+
+//natDisplay : display all port forwards and masquerades
+func natDisplay(c *cli.Context) {
+	n, err := st.NAT()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("  Interface   | Proto |    Port     |       Forwarded To        ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, f := range n.Forwards {
+		to := f.ToIP
+		if f.ToPort != "" {
+			to += ":" + f.ToPort
+		}
+		fmt.Printf(" %-12s | %-5s | %-11s | %s \n", f.Interface, f.Protocol, f.Port, to)
+	}
+	fmt.Println()
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("  Interface   |   Masqueraded Source ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, m := range n.Masquerades {
+		fmt.Printf(" %-12s | %s \n", m.Interface, m.Source)
+	}
+}
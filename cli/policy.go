@@ -0,0 +1,651 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"goaway2"
+	"goaway2/geoip"
+	"goaway2/profiles"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// policy files are json documents containing the complete firewall policy:
+//
+//	{
+//	  "version": 2,
+//	  "defaults":  {"inbound": "allow", "outbound": "ask", "forward": "deny", "ask_timeout": 30, "ask_verdict": "deny"},
+//	  "zones":     [{"name": "public", "inbound": "deny", "outbound": "allow", "interfaces": ["eth0"]}],
+//	  "rules":     [{"zone": "any", "source_ip": "any", "source_port": "any",
+//	                 "dest_ip": "any", "dest_port": "22", "audit": false,
+//	                 "profile": "service:ssh", "netzone": "public",
+//	                 "in_iface": "eth1", "out_iface": "eth0", "sni": "*.example.com",
+//	                 "uid": "33", "gid": "33", "exe": "/usr/bin/curl", "action": "allow",
+//	                 "source_country": "US", "dest_country": "DE"}],
+//	  "nat":       {"forwards": [{"iface": "eth0", "proto": "tcp", "port": "8080", "to_ip": "10.0.1.5", "to_port": "80"}],
+//	                "masquerades": [{"iface": "eth0", "source": "10.0.1.0/24"}]},
+//	  "whitelist": [{"ip": "10.0.0.1", "reason": "...", "entry_date": "..."}],
+//	  "blacklist": [{"ip": "10.0.0.2", "reason": "...", "entry_date": "...", "last_seen": "..."},
+//	                {"ip": "country:RU", "reason": "...", "entry_date": "...", "last_seen": "..."}]
+//	}
+//
+// rules are stored in rule-number order and "version" is the policy schema version (raised
+// whenever fields change the meaning of a policy, unknown fields are rejected),
+// the optional "profile" refers to a service/application profile by name and the
+// optional "netzone" to a zone declared within "zones" (absent zones are left untouched),
+// an absent "forward" default, absent prompt settings and an absent "nat" leave the current
+// configuration untouched
+
+/***Variables***/
+
+//policyVersion : current version of the policy file schema
+const policyVersion = 2
+
+//policyFile : serialized firewall policy used for import/export
+type policyFile struct {
+	Version   int           `json:"version"`
+	Defaults  policyDefault `json:"defaults"`
+	Zones     []policyZone  `json:"zones,omitempty"`
+	Rules     []policyRule  `json:"rules"`
+	NAT       *policyNAT    `json:"nat,omitempty"`
+	Whitelist []policyEntry `json:"whitelist"`
+	Blacklist []policyEntry `json:"blacklist"`
+}
+
+//policyDefault : serialized ruleopts table
+type policyDefault struct {
+	Inbound  string `json:"inbound"`
+	Outbound string `json:"outbound"`
+	Forward  string `json:"forward,omitempty"`
+	// prompt settings of the ask outbound default (absent keeps the current settings)
+	AskTimeout int    `json:"ask_timeout,omitempty"`
+	AskVerdict string `json:"ask_verdict,omitempty"`
+	// verdict of blacklisted sources and cap of tarpitted flows (absent keeps the current settings)
+	BlacklistAction string `json:"blacklist_action,omitempty"`
+	TarpitFlows     int    `json:"tarpit_flows,omitempty"`
+	// audit mode of the whole firewall
+	Audit bool `json:"audit,omitempty"`
+}
+
+//policyZone : serialized zone from zones/zoneifaces tables
+type policyZone struct {
+	Name       string   `json:"name"`
+	Inbound    string   `json:"inbound"`
+	Outbound   string   `json:"outbound"`
+	Interfaces []string `json:"interfaces"`
+}
+
+//policyRule : serialized rule from rules table
+type policyRule struct {
+	Zone     string `json:"zone"`
+	FromIP   string `json:"source_ip"`
+	FromPort string `json:"source_port"`
+	ToIP     string `json:"dest_ip"`
+	ToPort   string `json:"dest_port"`
+	Audit    bool   `json:"audit"`
+	Profile  string `json:"profile,omitempty"`
+	NetZone  string `json:"netzone,omitempty"`
+	InIface  string `json:"in_iface,omitempty"`
+	OutIface string `json:"out_iface,omitempty"`
+	SNI      string `json:"sni,omitempty"`
+	Host     string `json:"host,omitempty"`
+	UID      string `json:"uid,omitempty"`
+	GID      string `json:"gid,omitempty"`
+	Exe      string `json:"exe,omitempty"`
+	Action   string `json:"action,omitempty"`
+	// iso country codes of the source/destination address
+	SrcCountry string `json:"source_country,omitempty"`
+	DstCountry string `json:"dest_country,omitempty"`
+}
+
+//policyNAT : serialized portforwards/masquerades tables
+type policyNAT struct {
+	Forwards    []policyForward    `json:"forwards"`
+	Masquerades []policyMasquerade `json:"masquerades"`
+}
+
+//policyForward : serialized port forward from portforwards table
+type policyForward struct {
+	Interface string `json:"iface"`
+	Protocol  string `json:"proto"`
+	Port      string `json:"port"`
+	ToIP      string `json:"to_ip"`
+	ToPort    string `json:"to_port,omitempty"`
+}
+
+//policyMasquerade : serialized masquerade from masquerades table
+type policyMasquerade struct {
+	Interface string `json:"iface"`
+	Source    string `json:"source"`
+}
+
+//policyEntry : serialized ip-address entry from whitelist/blacklist tables
+type policyEntry struct {
+	IPAddress string `json:"ip"`
+	Reason    string `json:"reason"`
+	EntryDate string `json:"entry_date,omitempty"`
+	LastSeen  string `json:"last_seen,omitempty"`
+	Expires   string `json:"expires,omitempty"`
+}
+
+var exportArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "file, f",
+		Usage: "file to write the policy to (default: stdout)",
+	},
+}
+var importArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "file, f",
+		Usage: "file to read the policy from (ufw: rules directory, iptables-save: dump or stdin)",
+	},
+	cli.StringFlag{
+		Name:  "defaults",
+		Usage: "ufw defaults file (default: /etc/default/ufw, or default/ufw next to the parent of --file)",
+	},
+	cli.StringFlag{
+		Name:  "from",
+		Value: "goaway",
+		Usage: "format of the imported policy (goaway/ufw/iptables-save)",
+	},
+	cli.BoolFlag{
+		Name:  "replace",
+		Usage: "replace the existing policy instead of merging into it",
+	},
+}
+
+/***Functions***/
+
+//policyCollect : collect the complete firewall policy from the database
+func policyCollect() (*policyFile, error) {
+	p := &policyFile{
+		Version:   policyVersion,
+		Rules:     []policyRule{},
+		Whitelist: []policyEntry{},
+		Blacklist: []policyEntry{},
+	}
+	// collect defaults
+	opts, err := st.Options()
+	if err != nil {
+		return nil, err
+	}
+	p.Defaults = policyDefault{Inbound: opts.Inbound, Outbound: opts.Outbound, Forward: opts.Forward, Audit: opts.Audit}
+	if opts.Outbound == "ask" {
+		p.Defaults.AskTimeout, p.Defaults.AskVerdict = opts.AskTimeout, opts.AskVerdict
+	}
+	if opts.BlacklistAction == "tarpit" {
+		p.Defaults.BlacklistAction, p.Defaults.TarpitFlows = opts.BlacklistAction, opts.TarpitFlows
+	}
+	// collect zones
+	zones, err := st.NetZones()
+	if err != nil {
+		return nil, err
+	}
+	for _, z := range zones {
+		p.Zones = append(p.Zones, policyZone{Name: z.Name, Inbound: z.Inbound, Outbound: z.Outbound, Interfaces: z.Interfaces})
+	}
+	// collect rules
+	rules, err := st.Rules()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		p.Rules = append(p.Rules, policyRule{
+			Zone:       r.Zone,
+			FromIP:     r.FromIP,
+			FromPort:   r.FromPort,
+			ToIP:       r.ToIP,
+			ToPort:     r.ToPort,
+			Audit:      r.Audit,
+			Profile:    r.Profile,
+			NetZone:    r.NetZone,
+			InIface:    r.InIface,
+			OutIface:   r.OutIface,
+			SNI:        r.SNI,
+			Host:       r.Host,
+			UID:        r.UID,
+			GID:        r.GID,
+			Exe:        r.Exe,
+			Action:     r.Action,
+			SrcCountry: r.SrcCountry,
+			DstCountry: r.DstCountry,
+		})
+	}
+	// collect nat only when configured
+	n, err := st.NAT()
+	if err != nil {
+		return nil, err
+	}
+	if len(n.Forwards) > 0 || len(n.Masquerades) > 0 {
+		p.NAT = &policyNAT{Forwards: []policyForward{}, Masquerades: []policyMasquerade{}}
+		for _, f := range n.Forwards {
+			p.NAT.Forwards = append(p.NAT.Forwards, policyForward(f))
+		}
+		for _, m := range n.Masquerades {
+			p.NAT.Masquerades = append(p.NAT.Masquerades, policyMasquerade(m))
+		}
+	}
+	// collect whitelist and blacklist
+	whitelist, err := st.Entries(store.Whitelist)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range whitelist {
+		p.Whitelist = append(p.Whitelist, policyEntry{IPAddress: e.IPAddress, Reason: e.Reason, EntryDate: e.EntryDate})
+	}
+	blacklist, err := st.Entries(store.Blacklist)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blacklist {
+		p.Blacklist = append(p.Blacklist, policyEntry(e))
+	}
+	return p, nil
+}
+
+//policyCheckEntry : verify ip-address entry for whitelist/blacklist
+func policyCheckEntry(list string, n int, e policyEntry) error {
+	// blacklist entries may block every address of a country or autonomous system
+	if code := goaway2.CountryOfEntry(e.IPAddress); code != "" && list == "blacklist" {
+		if err := geoip.CheckCountry(code); err != nil {
+			return fmt.Errorf("%s[%d]: \"ip\" value is INVALID! (%s)", list, n, err.Error())
+		}
+	} else if geoip.IsASN(e.IPAddress) && list == "blacklist" {
+		if number, _ := geoip.ParseASN(e.IPAddress); number != e.IPAddress {
+			return fmt.Errorf("%s[%d]: \"ip\" value is INVALID! (expected %s)", list, n, number)
+		}
+	} else if !checkIP(e.IPAddress) || e.IPAddress == "any" {
+		return fmt.Errorf("%s[%d]: \"ip\" value is INVALID! (ip)", list, n)
+	}
+	if _, _, err := net.ParseCIDR(e.IPAddress); err == nil {
+		return fmt.Errorf("%s[%d]: \"ip\" must not be an IP-Range!", list, n)
+	}
+	if _, err := time.Parse(store.DateLayout, e.Expires); e.Expires != "" && (err != nil || list != "blacklist") {
+		return fmt.Errorf("%s[%d]: \"expires\" value is INVALID! (blacklist only, YYYY-MM-DD HH:MM:SS utc)", list, n)
+	}
+	if e.Reason == "" {
+		return fmt.Errorf("%s[%d]: \"reason\" must not be blank!", list, n)
+	}
+	return nil
+}
+
+//policyCheckProfiles : verify every profile the rules of the policy refer to exists
+// (the firewall refuses to load rules with an unknown profile)
+func policyCheckProfiles(p *policyFile, set *profiles.Set) error {
+	for n, r := range p.Rules {
+		if r.Profile == "" {
+			continue
+		}
+		if _, err := set.Lookup(r.Profile); err != nil {
+			return fmt.Errorf("rules[%d]: \"profile\" is unknown! (%s)", n, err.Error())
+		}
+	}
+	return nil
+}
+
+//policyCheck : verify the policy using the same checks as the individual commands
+func policyCheck(p *policyFile) error {
+	if p.Version != policyVersion {
+		return fmt.Errorf("unsupported policy version: %d (expected %d)", p.Version, policyVersion)
+	}
+	// check defaults
+	if p.Defaults.Inbound != "allow" && p.Defaults.Inbound != "deny" {
+		return fmt.Errorf("defaults: \"inbound\" value is INVALID! (allow/deny)")
+	}
+	if p.Defaults.Outbound != "allow" && p.Defaults.Outbound != "deny" && p.Defaults.Outbound != "ask" {
+		return fmt.Errorf("defaults: \"outbound\" value is INVALID! (allow/deny/ask)")
+	}
+	if p.Defaults.AskTimeout < 0 || (p.Defaults.AskVerdict != "" && p.Defaults.AskVerdict != "allow" && p.Defaults.AskVerdict != "deny") {
+		return fmt.Errorf("defaults: \"ask_timeout\"/\"ask_verdict\" value is INVALID! (seconds/allow/deny)")
+	}
+	if p.Defaults.Forward != "" && p.Defaults.Forward != "allow" && p.Defaults.Forward != "deny" {
+		return fmt.Errorf("defaults: \"forward\" value is INVALID! (allow/deny)")
+	}
+	if p.Defaults.TarpitFlows < 0 || (p.Defaults.BlacklistAction != "" && p.Defaults.BlacklistAction != "drop" && p.Defaults.BlacklistAction != "tarpit") {
+		return fmt.Errorf("defaults: \"blacklist_action\"/\"tarpit_flows\" value is INVALID! (drop/tarpit/flows)")
+	}
+	// check zones
+	zones := make(map[string]bool)
+	for n, z := range p.Zones {
+		switch {
+		case !checkNetZone(z.Name):
+			return fmt.Errorf("zones[%d]: \"name\" value is INVALID! (letters/digits/-/_)", n)
+		case zones[z.Name]:
+			return fmt.Errorf("zones[%d]: duplicate zone %q", n, z.Name)
+		case (z.Inbound != "allow" && z.Inbound != "deny") || (z.Outbound != "allow" && z.Outbound != "deny"):
+			return fmt.Errorf("zones[%d]: defaults are INVALID! (allow/deny)", n)
+		}
+		zones[z.Name] = true
+	}
+	// check rules
+	for n, r := range p.Rules {
+		switch {
+		case r.NetZone != "" && !zones[r.NetZone]:
+			return fmt.Errorf("rules[%d]: \"netzone\" %q is not declared within zones", n, r.NetZone)
+		case !checkZone(r.Zone):
+			return fmt.Errorf("rules[%d]: \"zone\" value is INVALID! (any/inbound/outbound/forward)", n)
+		case r.NetZone != "" && r.Zone == "forward":
+			return fmt.Errorf("rules[%d]: \"netzone\" must not be used along with the forward zone", n)
+		case (r.InIface != "" && !checkIface(r.InIface)) || (r.OutIface != "" && !checkIface(r.OutIface)):
+			return fmt.Errorf("rules[%d]: \"in_iface\"/\"out_iface\" value is INVALID! (interface name)", n)
+		case !checkAddr(r.FromIP):
+			return fmt.Errorf("rules[%d]: \"source_ip\" value is INVALID! (any/ip/[a network class]/hostname/*.domain/AS00)", n)
+		case !checkPort(r.FromPort):
+			return fmt.Errorf("rules[%d]: \"source_port\" value is NOT an INTEGER or a INTEGER-RANGE! (any/00/00-00)", n)
+		case !checkAddr(r.ToIP):
+			return fmt.Errorf("rules[%d]: \"dest_ip\" value is INVALID! (any/ip/[a network class]/hostname/*.domain/AS00)", n)
+		case !checkPort(r.ToPort):
+			return fmt.Errorf("rules[%d]: \"dest_port\" value is NOT an INTEGER or a INTEGER-RANGE! (any/00/00-00)", n)
+		case r.Profile != "" && !profiles.CheckRef(r.Profile):
+			return fmt.Errorf("rules[%d]: \"profile\" value is INVALID! (service:name/app:name)", n)
+		case r.Profile != "" && r.ToPort != "any":
+			return fmt.Errorf("rules[%d]: \"dest_port\" must be \"any\" along with a profile", n)
+		case (r.SNI != "" && !goaway2.IsHostPattern(r.SNI)) || (r.Host != "" && !goaway2.IsHostPattern(r.Host)):
+			return fmt.Errorf("rules[%d]: \"sni\"/\"host\" value is INVALID! (hostname/*.domain)", n)
+		case r.SNI != "" && r.Host != "":
+			return fmt.Errorf("rules[%d]: \"sni\" and \"host\" must not be used at once", n)
+		case (r.UID != "" && !checkID(r.UID)) || (r.GID != "" && !checkID(r.GID)):
+			return fmt.Errorf("rules[%d]: \"uid\"/\"gid\" value is INVALID! (numeric id)", n)
+		case !checkAction(r.Action):
+			return fmt.Errorf("rules[%d]: \"action\" value is INVALID! (allow/deny/tarpit)", n)
+		case r.Exe != "" && !checkExe(r.Exe):
+			return fmt.Errorf("rules[%d]: \"exe\" value is INVALID! (absolute path)", n)
+		case (r.SrcCountry != "" && geoip.CheckCountry(r.SrcCountry) != nil) || (r.DstCountry != "" && geoip.CheckCountry(r.DstCountry) != nil):
+			return fmt.Errorf("rules[%d]: \"source_country\"/\"dest_country\" value is INVALID! (country code e.g. US)", n)
+		case (r.UID != "" || r.GID != "" || r.Exe != "") && r.Zone == "forward":
+			return fmt.Errorf("rules[%d]: \"uid\"/\"gid\"/\"exe\" must not be used along with the forward zone", n)
+		case r.FromIP == "any" && r.FromPort == "any" && r.ToIP == "any" && r.ToPort == "any" && r.Profile == "" &&
+			r.SNI == "" && r.Host == "" && r.UID == "" && r.GID == "" && r.Exe == "" && r.SrcCountry == "" && r.DstCountry == "":
+			return fmt.Errorf("rules[%d]: all values must not be \"any\" at once", n)
+		}
+	}
+	// check nat
+	if p.NAT != nil {
+		for n, f := range p.NAT.Forwards {
+			switch {
+			case !checkIface(f.Interface):
+				return fmt.Errorf("nat.forwards[%d]: \"iface\" value is INVALID! (interface name)", n)
+			case f.Protocol != "tcp" && f.Protocol != "udp":
+				return fmt.Errorf("nat.forwards[%d]: \"proto\" value is INVALID! (tcp/udp)", n)
+			case f.Port == "any" || !checkPort(f.Port):
+				return fmt.Errorf("nat.forwards[%d]: \"port\" value is NOT an INTEGER or a INTEGER-RANGE! (00/00-00)", n)
+			case net.ParseIP(f.ToIP) == nil:
+				return fmt.Errorf("nat.forwards[%d]: \"to_ip\" value is INVALID! (ip)", n)
+			case f.ToPort != "" && (f.ToPort == "any" || !checkPort(f.ToPort)):
+				return fmt.Errorf("nat.forwards[%d]: \"to_port\" value is NOT an INTEGER or a INTEGER-RANGE! (00/00-00)", n)
+			}
+		}
+		for n, m := range p.NAT.Masquerades {
+			if !checkIface(m.Interface) {
+				return fmt.Errorf("nat.masquerades[%d]: \"iface\" value is INVALID! (interface name)", n)
+			}
+			if !checkIP(m.Source) {
+				return fmt.Errorf("nat.masquerades[%d]: \"source\" value is INVALID! (any/ip/[a network class])", n)
+			}
+		}
+	}
+	// check whitelist and blacklist
+	for n, e := range p.Whitelist {
+		if err := policyCheckEntry("whitelist", n, e); err != nil {
+			return err
+		}
+	}
+	for n, e := range p.Blacklist {
+		if err := policyCheckEntry("blacklist", n, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//policyApply : write the policy into the database using the given transaction
+func policyApply(tx *store.Store, p *policyFile, replace bool) error {
+	// clear existing policy when replacing it
+	if replace {
+		if err := tx.FlushRules(); err != nil {
+			return err
+		}
+		for _, list := range []string{store.Whitelist, store.Blacklist} {
+			if err := tx.FlushEntries(list); err != nil {
+				return err
+			}
+		}
+	}
+	// defaults are always overwritten except for an absent forward default
+	opts, err := tx.Options()
+	if err != nil {
+		return err
+	}
+	opts.Inbound, opts.Outbound, opts.Audit = p.Defaults.Inbound, p.Defaults.Outbound, p.Defaults.Audit
+	if p.Defaults.Forward != "" {
+		opts.Forward = p.Defaults.Forward
+	}
+	if p.Defaults.AskTimeout != 0 {
+		opts.AskTimeout = p.Defaults.AskTimeout
+	}
+	if p.Defaults.AskVerdict != "" {
+		opts.AskVerdict = p.Defaults.AskVerdict
+	}
+	if p.Defaults.BlacklistAction != "" {
+		opts.BlacklistAction = p.Defaults.BlacklistAction
+	}
+	if p.Defaults.TarpitFlows != 0 {
+		opts.TarpitFlows = p.Defaults.TarpitFlows
+	}
+	if err = tx.SetOptions(opts); err != nil {
+		return err
+	}
+	// zones are only touched when the policy declares them
+	if p.Zones != nil {
+		if err := policyApplyZones(tx, p.Zones, replace); err != nil {
+			return err
+		}
+	}
+	// append rules that do not already exist
+	for _, r := range p.Rules {
+		rule := store.Rule{
+			Zone:       r.Zone,
+			FromIP:     r.FromIP,
+			FromPort:   r.FromPort,
+			ToIP:       r.ToIP,
+			ToPort:     r.ToPort,
+			Audit:      r.Audit,
+			Profile:    r.Profile,
+			NetZone:    r.NetZone,
+			InIface:    r.InIface,
+			OutIface:   r.OutIface,
+			SNI:        strings.ToLower(r.SNI),
+			Host:       strings.ToLower(r.Host),
+			UID:        r.UID,
+			GID:        r.GID,
+			Exe:        r.Exe,
+			Action:     r.Action,
+			SrcCountry: r.SrcCountry,
+			DstCountry: r.DstCountry,
+		}
+		exists, err := tx.HasRule(rule)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err = tx.AppendRule(rule); err != nil {
+			return err
+		}
+	}
+	// nat is only touched when the policy declares it
+	if p.NAT != nil {
+		if err := policyApplyNAT(tx, p.NAT, replace); err != nil {
+			return err
+		}
+	}
+	// append list entries that do not already exist
+	if err := policyApplyEntries(tx, store.Whitelist, p.Whitelist); err != nil {
+		return err
+	}
+	return policyApplyEntries(tx, store.Blacklist, p.Blacklist)
+}
+
+//policyApplyZones : replace all zones or create/update the given zones and their bindings
+func policyApplyZones(tx *store.Store, zones []policyZone, replace bool) error {
+	if replace {
+		var netzones []store.NetZone
+		for _, z := range zones {
+			netzones = append(netzones, store.NetZone(z))
+		}
+		return tx.SetNetZones(netzones)
+	}
+	for _, z := range zones {
+		exists, err := tx.HasNetZone(z.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			err = tx.SetNetZoneDefaults(z.Name, z.Inbound, z.Outbound)
+		} else {
+			err = tx.AddNetZone(z.Name, z.Inbound, z.Outbound)
+		}
+		if err != nil {
+			return err
+		}
+		for _, iface := range z.Interfaces {
+			if err = tx.BindInterface(iface, z.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//policyApplyNAT : replace the nat configuration or add/update the given port forwards and masquerades
+func policyApplyNAT(tx *store.Store, pn *policyNAT, replace bool) error {
+	var n store.NAT
+	for _, f := range pn.Forwards {
+		n.Forwards = append(n.Forwards, store.PortForward(f))
+	}
+	for _, m := range pn.Masquerades {
+		n.Masquerades = append(n.Masquerades, store.Masquerade(m))
+	}
+	if replace {
+		return tx.SetNAT(n)
+	}
+	for _, f := range n.Forwards {
+		if err := tx.AddPortForward(f); err != nil {
+			return err
+		}
+	}
+	for _, m := range n.Masquerades {
+		if err := tx.AddMasquerade(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//policyApplyEntries : append list entries that do not already exist
+func policyApplyEntries(tx *store.Store, list string, entries []policyEntry) error {
+	for _, e := range entries {
+		exists, err := tx.HasEntry(list, e.IPAddress)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err = tx.AddEntry(list, store.Entry(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//policyExport : write the complete firewall policy as a versioned file
+func policyExport(c *cli.Context) {
+	p, err := policyCollect()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		cliError(c, fmt.Sprintf("Unable to encode policy: %s", err.Error()))
+	}
+	data = append(data, '\n')
+	// write to stdout unless a file is given
+	path := c.String("file")
+	if path == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		cliError(c, fmt.Sprintf("Unable to write policy: %s", err.Error()))
+	}
+	fmt.Printf("Policy exported to %q\n", path)
+}
+
+//policyRead : read a versioned policy file
+func policyRead(c *cli.Context) *policyFile {
+	path := c.String("file")
+	if path == "" {
+		cliError(c, "Flag: \"file\" must not be blank!")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		cliError(c, fmt.Sprintf("Unable to read policy: %s", err.Error()))
+	}
+	p, err := policyDecode(data)
+	if err != nil {
+		cliError(c, fmt.Sprintf("Unable to decode policy: %s", err.Error()))
+	}
+	return p
+}
+
+//policyDecode : decode a policy rejecting unknown fields instead of silently dropping them
+func policyDecode(data []byte) (*policyFile, error) {
+	p := new(policyFile)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+//policyImport : read a policy and merge/replace the firewall policy
+func policyImport(c *cli.Context) {
+	var p *policyFile
+	switch from := c.String("from"); from {
+	case "goaway":
+		p = policyRead(c)
+	case "ufw", "iptables-save":
+		p = policyConvert(c, from)
+	default:
+		cliError(c, "Flag: \"from\" value is INVALID! (goaway/ufw/iptables-save)")
+	}
+	if err := policyCheck(p); err != nil {
+		cliError(c, fmt.Sprintf("Invalid policy: %s", err.Error()))
+	}
+	if err := policyCheckProfiles(p, profilesLoad(c)); err != nil {
+		cliError(c, fmt.Sprintf("Invalid policy: %s", err.Error()))
+	}
+	replace := c.Bool("replace")
+	guardChange(c, replace || p.Defaults.Inbound == "deny" || p.Defaults.Outbound == "deny", func(tx *store.Store) error {
+		return policyApply(tx, p, replace)
+	})
+	if p.NAT != nil {
+		natInstall()
+	}
+	if replace {
+		fmt.Println("Policy Replaced...")
+	} else {
+		fmt.Println("Policy Merged...")
+	}
+}
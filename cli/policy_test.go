@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goaway2/profiles"
+	"goaway2/store"
+)
+
+/***Variables***/
+
+//examplePolicy : policy touching every section of the policy file
+var examplePolicy = policyFile{
+	Version:  policyVersion,
+	Defaults: policyDefault{Inbound: "deny", Outbound: "ask", Forward: "deny", AskTimeout: 20, AskVerdict: "allow", Audit: true},
+	Zones:    []policyZone{{Name: "public", Inbound: "deny", Outbound: "allow", Interfaces: []string{"eth0"}}},
+	Rules: []policyRule{
+		{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", NetZone: "public", Action: "allow"},
+		{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "443", SNI: "*.example.com", Action: "deny"},
+		{Zone: "any", FromIP: "10.0.0.0/8", FromPort: "any", ToIP: "any", ToPort: "any", Audit: true},
+	},
+	NAT: &policyNAT{
+		Forwards:    []policyForward{{Interface: "eth0", Protocol: "tcp", Port: "8080", ToIP: "10.0.1.5", ToPort: "80"}},
+		Masquerades: []policyMasquerade{{Interface: "eth0", Source: "10.0.1.0/24"}},
+	},
+	Whitelist: []policyEntry{{IPAddress: "10.0.0.1", Reason: "admin", EntryDate: "2020-01-02 03:04:05"}},
+	Blacklist: []policyEntry{
+		{IPAddress: "198.51.100.7", Reason: "scanner", EntryDate: "2020-01-02 03:04:05", LastSeen: "2020-01-02 03:04:05"},
+		{IPAddress: "country:RU", Reason: "geo", EntryDate: "2020-01-02 03:04:05", LastSeen: "2020-01-02 03:04:05"},
+	},
+}
+
+/***Functions***/
+
+//openPolicyStore : open an in-memory database as the store used by the policy commands
+func openPolicyStore(t *testing.T) {
+	var err error
+	if st, err = store.Open(":memory:"); err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+}
+
+//policyJSON : encode policy for comparisons
+func policyJSON(t *testing.T, p *policyFile) string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Unable to encode policy: %s\n", err.Error())
+	}
+	return string(data)
+}
+
+/***Unit-Tests***/
+
+func TestPolicyRoundTrip(t *testing.T) {
+	openPolicyStore(t)
+	defer st.Close()
+	// decode the encoded policy the way import reads it
+	p, err := policyDecode([]byte(policyJSON(t, &examplePolicy)))
+	if err != nil {
+		t.Fatalf("Unable to decode policy: %s\n", err.Error())
+	}
+	if err = policyCheck(p); err != nil {
+		t.Fatalf("Example policy is invalid: %s\n", err.Error())
+	}
+	// check unknown fields and older versions are rejected
+	if _, err = policyDecode([]byte(`{"version": 2, "rules": [{"zone": "any", "verdict": "allow"}]}`)); err == nil {
+		t.Fatalf("Able to decode policy with unknown fields\n")
+	}
+	if err = policyCheck(&policyFile{Version: 1}); err == nil {
+		t.Fatalf("Able to import policy of version 1\n")
+	}
+	if err = policyApply(st, p, true); err != nil {
+		t.Fatalf("Unable to apply policy: %s\n", err.Error())
+	}
+	exported, err := policyCollect()
+	if err != nil {
+		t.Fatalf("Unable to collect policy: %s\n", err.Error())
+	}
+	if got, want := policyJSON(t, exported), policyJSON(t, &examplePolicy); got != want {
+		t.Fatalf("Exported policy differs from the imported one:\n%s\n%s\n", got, want)
+	}
+	// replacing the policy with its export changes nothing
+	if err = policyApply(st, exported, true); err != nil {
+		t.Fatalf("Unable to apply exported policy: %s\n", err.Error())
+	}
+	if again, _ := policyCollect(); !reflect.DeepEqual(again, exported) {
+		t.Fatalf("Policy changed after replacing it with its export:\n%+v\n%+v\n", again, exported)
+	}
+}
+
+func TestPolicyProfiles(t *testing.T) {
+	dir := filepath.Join("..", "profiles", "testdata")
+	set, err := profiles.Load(filepath.Join(dir, "services"), filepath.Join(dir, "applications.d"))
+	if err != nil {
+		t.Fatalf("Unable to load profiles: %s\n", err.Error())
+	}
+	// check rules may only refer to existing profiles
+	p := &policyFile{Rules: []policyRule{
+		{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "any", Profile: profiles.ServiceRef("ssh")},
+		{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "any", Profile: profiles.AppRef("OpenSSH")},
+	}}
+	if err = policyCheckProfiles(p, set); err != nil {
+		t.Fatalf("Unable to check existing profiles: %s\n", err.Error())
+	}
+	p.Rules = append(p.Rules, policyRule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "any", Profile: profiles.AppRef("Missing")})
+	if err = policyCheckProfiles(p, set); err == nil {
+		t.Fatalf("Able to import rule with a missing profile\n")
+	}
+}
+
+func TestPolicyMerge(t *testing.T) {
+	openPolicyStore(t)
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AppendRule(store.Rule{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "443", SNI: "*.example.com", Action: "deny"})
+	st.AppendRule(store.Rule{Zone: "any", FromIP: "any", FromPort: "any", ToIP: "203.0.113.5", ToPort: "any"})
+	st.AddEntry(store.Whitelist, store.Entry{IPAddress: "10.0.0.1", Reason: "admin"})
+	st.AddEntry(store.Blacklist, store.Entry{IPAddress: "203.0.113.5", Reason: "existing"})
+	p := examplePolicy
+	if err := policyApply(st, &p, false); err != nil {
+		t.Fatalf("Unable to merge policy: %s\n", err.Error())
+	}
+	merged, err := policyCollect()
+	if err != nil {
+		t.Fatalf("Unable to collect policy: %s\n", err.Error())
+	}
+	// existing rules are kept in front of the new ones and duplicates are skipped
+	if len(merged.Rules) != 4 || merged.Rules[1].ToIP != "203.0.113.5" || merged.Rules[2].ToPort != "22" || merged.Rules[3].FromIP != "10.0.0.0/8" {
+		t.Fatalf("Unexpected merged rules: %+v\n", merged.Rules)
+	}
+	if len(merged.Whitelist) != 1 || merged.Whitelist[0].Reason != "admin" || len(merged.Blacklist) != 3 {
+		t.Fatalf("Unexpected merged entries: %+v %+v\n", merged.Whitelist, merged.Blacklist)
+	}
+	if merged.Defaults != examplePolicy.Defaults || merged.NAT == nil {
+		t.Fatalf("Unexpected merged defaults/nat: %+v %+v\n", merged.Defaults, merged.NAT)
+	}
+	// declared zones are bound while the default zones are kept
+	var bound bool
+	for _, z := range merged.Zones {
+		bound = bound || (z.Name == "public" && reflect.DeepEqual(z.Interfaces, []string{"eth0"}))
+	}
+	if !bound || len(merged.Zones) < 2 {
+		t.Fatalf("Unexpected merged zones: %+v\n", merged.Zones)
+	}
+	// merging the same policy again changes nothing
+	if err = policyApply(st, &p, false); err != nil {
+		t.Fatalf("Unable to merge policy again: %s\n", err.Error())
+	}
+	if again, _ := policyCollect(); !reflect.DeepEqual(again, merged) {
+		t.Fatalf("Policy changed after merging it twice:\n%+v\n%+v\n", again, merged)
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"goaway2/profiles"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Functions***/
+
+//profilesLoad : load services and application profiles rules may refer to
+func profilesLoad(c *cli.Context) *profiles.Set {
+	set, err := profiles.Load(profiles.ServicesFile, profiles.AppsDir)
+	if err != nil {
+		cliError(c, fmt.Sprintf("Profile-Error: %s", err.Error()))
+	}
+	return set
+}
+
+//profilesDisplay : display all application profiles
+func profilesDisplay(c *cli.Context) {
+	set := profilesLoad(c)
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("        Application         |                 Ports                  ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, p := range set.Apps() {
+		fmt.Printf(" %-26s | %s \n", p.Name, p.String())
+	}
+}
+
+//profilesInfo : display the given application profile or service
+func profilesInfo(c *cli.Context) {
+	name := strings.Join(c.Args(), " ")
+	if name == "" {
+		cliError(c, "Info requires a profile name!")
+	}
+	set := profilesLoad(c)
+	// prefer application profiles over services of the same name
+	kind := "Application"
+	p, err := set.Lookup(profiles.AppRef(name))
+	if err != nil {
+		kind = "Service"
+		if p, err = set.Lookup(profiles.ServiceRef(name)); err != nil {
+			cliError(c, fmt.Sprintf("Profile: %q does not exist!", name))
+		}
+	}
+	fmt.Printf("%s: %s\n", kind, p.Name)
+	if p.Title != "" {
+		fmt.Printf("Title:       %s\n", p.Title)
+	}
+	if p.Description != "" {
+		fmt.Printf("Description: %s\n", p.Description)
+	}
+	fmt.Printf("Ports:       %s\n", p.String())
+}
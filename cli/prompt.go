@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"goaway2"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var promptArgs = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "once",
+		Usage: "exit after the pending prompts were answered instead of waiting for new ones",
+	},
+}
+
+var promptForgetArgs = []cli.Flag{
+	cli.IntFlag{
+		Name:  "num, n",
+		Value: -1,
+		Usage: "the number of the answer (see 'goaway prompt answers')",
+	},
+}
+
+//promptPollWait : time a single poll of the daemon waits for new prompts
+const promptPollWait = 10 * time.Second
+
+/***Functions***/
+
+//promptDescribe : describe the program and destination of a prompt
+func promptDescribe(p goaway2.Prompt) string {
+	program := "unknown program"
+	switch {
+	case p.Exe != "":
+		program = fmt.Sprintf("%s (pid %d, uid %d)", p.Exe, p.PID, p.UID)
+	case p.UID >= 0:
+		program = fmt.Sprintf("socket of uid %d", p.UID)
+	}
+	return fmt.Sprintf("%s wants to connect to %s:%d/%s", program, p.DstIP, p.DstPort, strings.ToLower(p.Protocol))
+}
+
+//promptParse : parse an answer of the form "allow|deny [once|forever|<duration>]"
+func promptParse(id int64, line string) (goaway2.PromptAnswer, error) {
+	a := goaway2.PromptAnswer{ID: id, Scope: goaway2.ScopeOnce}
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) == 0 || len(fields) > 2 {
+		return a, fmt.Errorf("expected: allow|deny [once|forever|<duration>]")
+	}
+	switch fields[0] {
+	case "a", "allow":
+		a.Verdict = "allow"
+	case "d", "deny":
+		a.Verdict = "deny"
+	default:
+		return a, fmt.Errorf("unknown verdict %q (allow/deny)", fields[0])
+	}
+	if len(fields) == 1 || fields[1] == goaway2.ScopeOnce {
+		return a, nil
+	}
+	if fields[1] == goaway2.ScopeForever {
+		a.Scope = goaway2.ScopeForever
+		return a, nil
+	}
+	d, err := time.ParseDuration(fields[1])
+	if err != nil || d < time.Second {
+		return a, fmt.Errorf("invalid duration %q (e.g. 10m/1h)", fields[1])
+	}
+	a.Scope, a.Duration = goaway2.ScopeDuration, int64(d/time.Second)
+	return a, nil
+}
+
+//promptRun : show prompts of the running daemon and send the answers read from stdin
+func promptRun(c *cli.Context) {
+	input := bufio.NewScanner(os.Stdin)
+	fmt.Println("Answer prompts with: allow|deny [once|forever|<duration e.g. 1h>]")
+	for {
+		var prompts []goaway2.Prompt
+		args := map[string]int64{"wait": int64(promptPollWait / time.Second)}
+		if err := goaway2.ControlCall(goaway2.ControlSocket, "prompts", args, &prompts); err != nil {
+			cliError(c, fmt.Sprintf("CONTROL-ERROR: %s", err.Error()))
+		}
+		if len(prompts) == 0 && c.Bool("once") {
+			return
+		}
+		for _, p := range prompts {
+			remaining := time.Until(p.Deadline).Round(time.Second)
+			if remaining <= 0 {
+				continue
+			}
+			fmt.Printf("\n[#%d] %s\n", p.ID, promptDescribe(p))
+			for {
+				fmt.Printf("answer within %s > ", remaining)
+				if !input.Scan() {
+					return
+				}
+				a, err := promptParse(p.ID, input.Text())
+				if err != nil {
+					fmt.Printf("INVALID: %s\n", err.Error())
+					continue
+				}
+				if err = goaway2.ControlCall(goaway2.ControlSocket, "answer", a, nil); err != nil {
+					fmt.Printf("Unable to answer: %s\n", err.Error())
+				}
+				break
+			}
+		}
+	}
+}
+
+//promptAnswers : display the answers of prompts answered forever
+func promptAnswers(c *cli.Context) {
+	answers, err := st.Answers()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println(" Num | Action |       Destination        |          Program         ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, a := range answers {
+		program := "any"
+		switch {
+		case a.Exe != "":
+			program = a.Exe
+		case a.UID != "":
+			program = "uid " + a.UID
+		}
+		fmt.Printf(" %3d | %-6s | %-24s | %s \n", a.RuleNum, a.Action, a.ToIP+":"+a.ToPort, program)
+	}
+}
+
+//promptForget : forget a prompt answered forever (the running daemon keeps it until restarted)
+func promptForget(c *cli.Context) {
+	num := c.Int("num")
+	if num < 0 {
+		cliError(c, "Flag: \"num\" must be >= 0")
+	}
+	removed, err := st.RemoveAnswer(num)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !removed {
+		cliError(c, fmt.Sprintf("Answer: #%d does not exist!", num))
+	}
+	fmt.Println("Answer Forgotten... (restart the daemon to prompt its connections again)")
+}
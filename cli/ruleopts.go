@@ -2,17 +2,16 @@ package cli
 
 import (
 	"fmt"
+	"time"
+
+	"goaway2"
+	"goaway2/store"
 
 	cli "gopkg.in/urfave/cli.v1"
 )
 
 /***Variables***/
 
-type ruleoptRecord struct {
-	Inbound  string
-	Outbound string
-}
-
 var ruleoptsAllowArgs = []cli.Flag{
 	cli.BoolFlag{
 		Name:  "inbound, i",
@@ -22,6 +21,10 @@ var ruleoptsAllowArgs = []cli.Flag{
 		Name:  "outbound, o",
 		Usage: "Allow outbound packets by default",
 	},
+	cli.BoolFlag{
+		Name:  "forward, f",
+		Usage: "Allow forwarded packets by default (router mode)",
+	},
 }
 var ruleoptsDenyArgs = []cli.Flag{
 	cli.BoolFlag{
@@ -32,21 +35,70 @@ var ruleoptsDenyArgs = []cli.Flag{
 		Name:  "outbound, o",
 		Usage: "Deny outbound packets by default",
 	},
+	cli.BoolFlag{
+		Name:  "forward, f",
+		Usage: "Deny forwarded packets by default",
+	},
+}
+var ruleoptsAskArgs = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "outbound, o",
+		Usage: "Ask about outbound packets no rule decided (answered by \"goaway prompt\")",
+	},
+	cli.DurationFlag{
+		Name:  "timeout, t",
+		Value: 30 * time.Second,
+		Usage: "time packets are held waiting for an answer",
+	},
+	cli.StringFlag{
+		Name:  "timeout-verdict, v",
+		Value: "deny",
+		Usage: "verdict of unanswered prompts (allow/deny)",
+	},
+}
+var ruleoptsBlacklistArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "action, a",
+		Value: "drop",
+		Usage: "drop packets of blacklisted sources or tarpit their connections (drop/tarpit)",
+	},
+	cli.IntFlag{
+		Name:  "max-flows, m",
+		Value: goaway2.DefaultTarpitFlows,
+		Usage: "connections tarpitted at once, SYNs of further connections are dropped",
+	},
+}
+
+var ruleoptsAuditArgs = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "on",
+		Usage: "Record packets that would be dropped instead of dropping them",
+	},
+	cli.BoolFlag{
+		Name:  "off",
+		Usage: "Drop packets again",
+	},
+	cli.DurationFlag{
+		Name:  "retention, r",
+		Value: 30 * 24 * time.Hour,
+		Usage: "time audit entries are kept (0 keeps them forever)",
+	},
 }
 
 /***Variables***/
 
 //optSet : allow or deny given rule within the ruleopts table
 func optSet(c *cli.Context, feild, value string) {
-	if _, err := db.Exec("UPDATE ruleopts SET "+feild+"=?", value); err != nil {
+	if err := st.SetOption(feild, value); err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
 }
 
-//ruleoptsAllow : set firewall to allow inbound/outbound packets by default
+//ruleoptsAllow : set firewall to allow inbound/outbound/forwarded packets by default
 func ruleoptsAllow(c *cli.Context) {
 	inbound := c.Bool("inbound")
 	outbound := c.Bool("outbound")
+	forward := c.Bool("forward")
 	if inbound {
 		optSet(c, "Inbound", "allow")
 		fmt.Println("Inbound: Allow")
@@ -55,37 +107,157 @@ func ruleoptsAllow(c *cli.Context) {
 		optSet(c, "Outbound", "allow")
 		fmt.Println("Outbound: Allow")
 	}
-	if !inbound && !outbound {
+	if forward {
+		optSet(c, "Forward", "allow")
+		fmt.Println("Forward: Allow")
+	}
+	if !inbound && !outbound && !forward {
 		cliError(c, "Allow requires at least one flag!")
 	}
 }
 
-//ruleoptsDeny : set firewall to deny inbound/outbound packets by default
+//ruleoptsDeny : set firewall to deny inbound/outbound/forwarded packets by default
 func ruleoptsDeny(c *cli.Context) {
 	inbound := c.Bool("inbound")
 	outbound := c.Bool("outbound")
+	forward := c.Bool("forward")
+	if !inbound && !outbound && !forward {
+		cliError(c, "Deny requires at least one flag!")
+	}
+	// denying inbound/outbound by default may lock out the caller so the change is provisional
+	guardChange(c, inbound || outbound, func(tx *store.Store) error {
+		if inbound {
+			if err := tx.SetOption("Inbound", "deny"); err != nil {
+				return err
+			}
+		}
+		if outbound {
+			if err := tx.SetOption("Outbound", "deny"); err != nil {
+				return err
+			}
+		}
+		if forward {
+			return tx.SetOption("Forward", "deny")
+		}
+		return nil
+	})
 	if inbound {
-		optSet(c, "Inbound", "deny")
 		fmt.Println("Inbound: Deny")
 	}
 	if outbound {
-		optSet(c, "Outbound", "deny")
 		fmt.Println("Outbound: Deny")
 	}
-	if !inbound && !outbound {
-		cliError(c, "Deny requires at least one flag!")
+	if forward {
+		fmt.Println("Forward: Deny")
+	}
+}
+
+//ruleoptsAsk : set firewall to ask about outbound packets no rule decided
+func ruleoptsAsk(c *cli.Context) {
+	if !c.Bool("outbound") {
+		cliError(c, "Ask requires the outbound flag! (only outbound packets can be attributed to a program)")
+	}
+	timeout, verdict := c.Duration("timeout"), c.String("timeout-verdict")
+	if timeout < time.Second {
+		cliError(c, "Flag: \"timeout\" must be at least 1s!")
+	}
+	if verdict != "allow" && verdict != "deny" {
+		cliError(c, "Flag: \"timeout-verdict\" value is INVALID! (allow/deny)")
+	}
+	// unanswered prompts deny outbound packets so the change is provisional like deny
+	guardChange(c, verdict == "deny", func(tx *store.Store) error {
+		opts, err := tx.Options()
+		if err != nil {
+			return err
+		}
+		opts.Outbound, opts.AskTimeout, opts.AskVerdict = "ask", int(timeout/time.Second), verdict
+		return tx.SetOptions(opts)
+	})
+	fmt.Printf("Outbound: Ask (unanswered after %s: %s)\n", timeout, verdict)
+}
+
+//ruleoptsBlacklist : set the verdict of blacklisted sources and the cap of tarpitted flows
+func ruleoptsBlacklist(c *cli.Context) {
+	action, flows := c.String("action"), c.Int("max-flows")
+	if action != "drop" && action != "tarpit" {
+		cliError(c, "Flag: \"action\" value is INVALID! (drop/tarpit)")
+	}
+	if flows < 1 {
+		cliError(c, "Flag: \"max-flows\" must be at least 1!")
+	}
+	opts, err := st.Options()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	opts.BlacklistAction, opts.TarpitFlows = action, flows
+	if err = st.SetOptions(opts); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if action == "tarpit" {
+		fmt.Printf("Blacklist: Tarpit (at most %d connections)\n", flows)
+		return
+	}
+	fmt.Println("Blacklist: Drop")
+}
+
+//ruleoptsAudit : place the whole firewall within or out of audit mode and set the audit-log retention
+func ruleoptsAudit(c *cli.Context) {
+	on, off, retention := c.Bool("on"), c.Bool("off"), c.Duration("retention")
+	if on && off {
+		cliError(c, "Audit accepts only one of the on/off flags!")
+	}
+	if !on && !off && !c.IsSet("retention") {
+		cliError(c, "Audit requires the on, off or retention flag!")
+	}
+	if retention != 0 && retention < time.Hour {
+		cliError(c, "Flag: \"retention\" value is INVALID! (0 or at least 1h)")
+	}
+	// leaving audit mode drops packets again and may lock out the caller
+	guardChange(c, off, func(tx *store.Store) error {
+		opts, err := tx.Options()
+		if err != nil {
+			return err
+		}
+		if on || off {
+			opts.Audit = on
+		}
+		if c.IsSet("retention") {
+			opts.AuditRetention = int(retention / time.Second)
+		}
+		return tx.SetOptions(opts)
+	})
+	switch {
+	case on:
+		fmt.Println("Audit: On")
+	case off:
+		fmt.Println("Audit: Off")
+	}
+	switch {
+	case !c.IsSet("retention"):
+	case retention == 0:
+		fmt.Println("Audit-Log: entries are kept forever")
+	default:
+		fmt.Printf("Audit-Log: entries are kept for %s\n", retention)
 	}
 }
 
 //ruleoptsDisplay : display the given rule options from sql-table
 func ruleoptsDisplay(c *cli.Context) {
-	opt := new(ruleoptRecord)
-	err := db.QueryRow("SELECT * FROM ruleopts LIMIT 1").Scan(&opt.Inbound, &opt.Outbound)
+	opt, err := st.Options()
 	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
-	fmt.Println("~~~~~~~~~~~~~~~~~~~~")
-	fmt.Println(" Inbound | Outbound ")
-	fmt.Println("~~~~~~~~~~~~~~~~~~~~")
-	fmt.Printf(" %-7s | %-7s \n", opt.Inbound, opt.Outbound)
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println(" Inbound | Outbound | Forward ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Printf(" %-7s | %-8s | %-7s \n", opt.Inbound, opt.Outbound, opt.Forward)
+	if opt.Outbound == "ask" {
+		fmt.Printf("\nUnanswered prompts: %s after %ds\n", opt.AskVerdict, opt.AskTimeout)
+	}
+	if opt.BlacklistAction == "tarpit" {
+		fmt.Printf("\nBlacklisted sources: tarpit (at most %d connections)\n", opt.TarpitFlows)
+	}
+	if opt.Audit {
+		fmt.Println("\nAudit mode: packets that would be dropped are only recorded")
+	}
 }
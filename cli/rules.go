@@ -2,34 +2,34 @@ package cli
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"goaway2"
+	"goaway2/geoip"
+	"goaway2/profiles"
+	"goaway2/store"
+
 	cli "gopkg.in/urfave/cli.v1"
 )
 
 /***Variables***/
 
-//rulesRecord : struct used to extract rules from sql-table
-type rulesRecord struct {
-	RuleNum  int
-	Zone     string
-	FromIP   string
-	FromPort string
-	ToIP     string
-	ToPort   string
-}
-
 var rulesAppendArgs = []cli.Flag{
 	cli.StringFlag{
 		Name:  "zone, z",
 		Value: "any",
-		Usage: "what direction does this rule apply? (any/inbound/outbound)",
+		Usage: "what direction does this rule apply? (any/inbound/outbound/forward)",
 	},
 	cli.StringFlag{
 		Name:  "sourceip, sip",
 		Value: "any",
-		Usage: "what source ip-addresses the rule applies to",
+		Usage: "what source ip-addresses/hostname (e.g. *.example.com)/autonomous system (e.g. AS64512) the rule applies to",
 	},
 	cli.StringFlag{
 		Name:  "sport, sp",
@@ -39,13 +39,69 @@ var rulesAppendArgs = []cli.Flag{
 	cli.StringFlag{
 		Name:  "destip, dip",
 		Value: "any",
-		Usage: "what destination ip-addresses the rule applies to",
+		Usage: "what destination ip-addresses/hostname (e.g. *.github.com)/autonomous system (e.g. AS15169) the rule applies to",
 	},
 	cli.StringFlag{
 		Name:  "dport, dp",
 		Value: "any",
 		Usage: "what destination-port(s) the rule applies to",
 	},
+	cli.BoolFlag{
+		Name:  "audit, a",
+		Usage: "only record packets the rule would drop instead of dropping them",
+	},
+	cli.StringFlag{
+		Name:  "service, s",
+		Usage: "use the destination ports of a service from /etc/services (replaces dport)",
+	},
+	cli.StringFlag{
+		Name:  "app",
+		Usage: "use the destination ports of an application profile (replaces dport)",
+	},
+	cli.StringFlag{
+		Name:  "netzone, n",
+		Usage: "the zone (see zones) the rule belongs to instead of the global rule chain",
+	},
+	cli.StringFlag{
+		Name:  "iniface",
+		Usage: "the interface packets must be received on (e.g. eth1 for traffic forwarded from the lan)",
+	},
+	cli.StringFlag{
+		Name:  "outiface",
+		Usage: "the interface packets must be sent out of (e.g. eth0 for traffic forwarded to the wan)",
+	},
+	cli.StringFlag{
+		Name:  "sni",
+		Usage: "the tls server name (e.g. *.example.com) of the first data packet the rule applies to",
+	},
+	cli.StringFlag{
+		Name:  "host",
+		Usage: "the http host header (e.g. *.example.com) of the first data packet the rule applies to",
+	},
+	cli.StringFlag{
+		Name:  "uid",
+		Usage: "the user (name or id) owning the local socket the rule applies to",
+	},
+	cli.StringFlag{
+		Name:  "gid",
+		Usage: "the group (name or id) of the process owning the local socket the rule applies to",
+	},
+	cli.StringFlag{
+		Name:  "exe",
+		Usage: "the executable (absolute path) of the process owning the local socket the rule applies to",
+	},
+	cli.StringFlag{
+		Name:  "src-country",
+		Usage: "the country (iso code e.g. US) the source address must be located in",
+	},
+	cli.StringFlag{
+		Name:  "dst-country",
+		Usage: "the country (iso code e.g. US) the destination address must be located in",
+	},
+	cli.StringFlag{
+		Name:  "action",
+		Usage: "allow/deny/tarpit matching packets regardless of the default (default: drop matching packets when allowing, others when denying)",
+	},
 }
 var rulesInsertArgs = append(rulesAppendArgs, cli.StringFlag{
 	Name:  "rulenum, index",
@@ -59,6 +115,13 @@ var rulesRemoveArgs = []cli.Flag{
 		Usage: "what rule-number (index) should be deleted",
 	},
 }
+var rulesAuditArgs = []cli.Flag{
+	rulesRemoveArgs[0],
+	cli.BoolFlag{
+		Name:  "enforce, e",
+		Usage: "take the rule out of audit mode and enforce it",
+	},
+}
 var rulesFlushArgs = []cli.Flag{
 	cli.BoolFlag{
 		Name:  "yes, y",
@@ -68,49 +131,285 @@ var rulesFlushArgs = []cli.Flag{
 
 /***Functions***/
 
+//checkPort : verify validity of value as a port-number/port-range/any
+func checkPort(port string) bool {
+	if port == "any" {
+		return true
+	}
+	ints := strings.Split(port, "-")
+	if len(ints) > 2 {
+		return false
+	}
+	for _, i := range ints {
+		if _, err := strconv.ParseInt(i, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+//checkZone : verify validity of value as a rule zone
+func checkZone(zone string) bool {
+	return zone == "any" || zone == "inbound" || zone == "outbound" || zone == "forward"
+}
+
+//checkAddr : verify validity of value as a ip-range/ip-address/hostname/wildcard hostname/any
+func checkAddr(addr string) bool {
+	return checkIP(addr) || goaway2.IsHostPattern(addr) || geoip.IsASN(addr)
+}
+
+//rulesGetAddr : collect given flag argument from context after verifying validity as a rule address
+func rulesGetAddr(c *cli.Context, flag string) string {
+	addr := c.String(flag)
+	if !checkAddr(addr) {
+		cliError(c, fmt.Sprintf("Flag: \"%s\" value is INVALID! (any/ip/[a network class]/hostname/*.domain/AS00)", flag))
+	}
+	if number, err := geoip.ParseASN(addr); err == nil {
+		return number
+	}
+	return addr
+}
+
+//checkIface : verify validity of value as a network interface name
+func checkIface(name string) bool {
+	return len(name) < 16 && netIfaceName.MatchString(name)
+}
+
+//rulesIfaceName : describe rule interface for display (blank for any)
+func rulesIfaceName(name string) string {
+	if name == "" {
+		return "any"
+	}
+	return name
+}
+
 //rulesGetPort : collect given flag argument from context after verfifying validity as a port-number
 func rulesGetPort(c *cli.Context, flag string) string {
-	// set variables
 	var port = c.String(flag)
-	var errorMessage = fmt.Sprintf("Flag: %q value is NOT an INTEGER or a INTEGER-RANGE! (any/00/00-00)", flag)
 	// if port != (any/a valid integer range/a valid integer): error
-	if port == "any" {
-		return port
-	} else if !strings.Contains(port, "-") {
-		if _, err := strconv.ParseInt(port, 10, 64); err != nil {
-			cliError(c, errorMessage)
-		}
-	} else {
-		ints := strings.Split(port, "-")
-		if _, err := strconv.ParseInt(ints[0], 10, 64); err != nil {
-			cliError(c, errorMessage)
-		} else if _, err := strconv.ParseInt(ints[0], 10, 64); err != nil {
-			cliError(c, errorMessage)
-		}
+	if !checkPort(port) {
+		cliError(c, fmt.Sprintf("Flag: %q value is NOT an INTEGER or a INTEGER-RANGE! (any/00/00-00)", flag))
 	}
 	return port
 }
 
 //rulesGetArgs : collect, vefify, and return base arguments for append/insert functions
-func rulesGetArgs(c *cli.Context) (string, string, string, string, string) {
+func rulesGetArgs(c *cli.Context) store.Rule {
 	zone := c.String("zone")
-	if zone != "any" && zone != "inbound" && zone != "outbound" {
-		cliError(c, "Flag: \"zone\" value is INVALID! (any/inbound/outbound)")
-	}
-	sip := getIP(c, "sip")
-	sport := rulesGetPort(c, "sport")
-	dip := getIP(c, "dip")
-	dport := rulesGetPort(c, "dport")
-	if sip == "any" && sport == "any" && dip == "any" && dport == "any" {
+	if !checkZone(zone) {
+		cliError(c, "Flag: \"zone\" value is INVALID! (any/inbound/outbound/forward)")
+	}
+	rule := store.Rule{
+		Zone:     zone,
+		FromIP:   rulesGetAddr(c, "sip"),
+		FromPort: rulesGetPort(c, "sport"),
+		ToIP:     rulesGetAddr(c, "dip"),
+		ToPort:   rulesGetPort(c, "dport"),
+		Audit:    c.Bool("audit"),
+		Profile:  rulesGetProfile(c),
+		NetZone:  c.String("netzone"),
+		InIface:  rulesGetIface(c, "iniface"),
+		OutIface: rulesGetIface(c, "outiface"),
+		SNI:      rulesGetHostname(c, "sni"),
+		Host:     rulesGetHostname(c, "host"),
+		UID:      rulesGetUser(c),
+		GID:      rulesGetGroup(c),
+		Exe:      rulesGetExe(c),
+		Action:   c.String("action"),
+	}
+	rule.SrcCountry, rule.DstCountry = rulesGetCountry(c, "src-country"), rulesGetCountry(c, "dst-country")
+	if rule.SrcCountry != "" || rule.DstCountry != "" {
+		warnDatabase("country", geoip.CountryFiles)
+	}
+	if geoip.IsASN(rule.FromIP) || geoip.IsASN(rule.ToIP) {
+		warnDatabase("asn", geoip.ASNFiles)
+	}
+	if !checkAction(rule.Action) {
+		cliError(c, "Flag: \"action\" value is INVALID! (allow/deny/tarpit)")
+	}
+	if rule.NetZone != "" {
+		zonesGetName(c, "netzone")
+	}
+	if rule.NetZone != "" && rule.Zone == "forward" {
+		cliError(c, "Flag: \"netzone\" must not be used along with the forward zone! (forwarded packets use the global rule chain)")
+	}
+	if (rule.UID != "" || rule.GID != "" || rule.Exe != "") && rule.Zone == "forward" {
+		cliError(c, "Flags: \"uid\"/\"gid\"/\"exe\" must not be used along with the forward zone! (forwarded packets have no local owner)")
+	}
+	if rule.Profile != "" && rule.ToPort != "any" {
+		cliError(c, "Flag: \"dport\" must not be used along with a profile!")
+	}
+	if rule.SNI != "" && rule.Host != "" {
+		cliError(c, "Flags: \"sni\" and \"host\" must not be used at once! (a flow is either tls or http)")
+	}
+	if rule.FromIP == "any" && rule.FromPort == "any" && rule.ToIP == "any" && rule.ToPort == "any" && rule.Profile == "" &&
+		rule.SNI == "" && rule.Host == "" && rule.UID == "" && rule.GID == "" && rule.Exe == "" &&
+		rule.SrcCountry == "" && rule.DstCountry == "" {
 		cliError(c, "All command flags must not be \"any\" at once")
 	}
-	return zone, sip, sport, dip, dport
+	return rule
+}
+
+//rulesGetIface : collect optional interface from the given flag and warn when no such interface exists
+func rulesGetIface(c *cli.Context, flag string) string {
+	name := c.String(flag)
+	if name == "" {
+		return ""
+	}
+	if !checkIface(name) {
+		cliError(c, fmt.Sprintf("Flag: %q value is INVALID! (interface name)", flag))
+	}
+	if _, err := net.InterfaceByName(name); err != nil {
+		fmt.Printf("WARNING: interface %q does not exist (yet)!\n", name)
+	}
+	return name
+}
+
+//rulesGetHostname : collect optional hostname/wildcard hostname the first data packet of a flow is matched with
+func rulesGetHostname(c *cli.Context, flag string) string {
+	name := c.String(flag)
+	if name != "" && !goaway2.IsHostPattern(name) {
+		cliError(c, fmt.Sprintf("Flag: %q value is INVALID! (hostname/*.domain)", flag))
+	}
+	return strings.ToLower(name)
+}
+
+//checkID : verify validity of value as a numeric user/group id
+func checkID(id string) bool {
+	n, err := strconv.ParseUint(id, 10, 32)
+	return err == nil && n < math.MaxUint32
+}
+
+//checkAction : verify validity of value as a rule action (blank follows the default)
+func checkAction(action string) bool {
+	return action == "" || action == "allow" || action == "deny" || action == "tarpit"
+}
+
+//checkExe : verify validity of value as an executable path
+func checkExe(exe string) bool {
+	return filepath.IsAbs(exe) && filepath.Clean(exe) == exe
+}
+
+//rulesGetUser : collect optional user name/id and return it as a numeric user id
+func rulesGetUser(c *cli.Context) string {
+	name := c.String("uid")
+	if name == "" || checkID(name) {
+		return name
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"uid\" value is INVALID! (%s)", err.Error()))
+	}
+	return u.Uid
+}
+
+//rulesGetGroup : collect optional group name/id and return it as a numeric group id
+func rulesGetGroup(c *cli.Context) string {
+	name := c.String("gid")
+	if name == "" || checkID(name) {
+		return name
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"gid\" value is INVALID! (%s)", err.Error()))
+	}
+	return g.Gid
+}
+
+//rulesGetExe : collect optional executable path and warn when no such file exists
+func rulesGetExe(c *cli.Context) string {
+	exe := c.String("exe")
+	if exe == "" {
+		return ""
+	}
+	if !checkExe(exe) {
+		cliError(c, "Flag: \"exe\" value is INVALID! (absolute path e.g. /usr/bin/curl)")
+	}
+	if _, err := os.Stat(exe); err != nil {
+		fmt.Printf("WARNING: executable %q does not exist (yet)!\n", exe)
+	}
+	return exe
+}
+
+//rulesGetCountry : collect optional country code from the given flag
+func rulesGetCountry(c *cli.Context, flag string) string {
+	code := strings.ToUpper(c.String(flag))
+	if code == "" {
+		return ""
+	}
+	if err := geoip.CheckCountry(code); err != nil {
+		cliError(c, fmt.Sprintf("Flag: %q value is INVALID! (%s)", flag, err.Error()))
+	}
+	return code
+}
+
+//warnDatabase : warn when none of the country/asn database files exists
+func warnDatabase(kind string, files []string) {
+	for _, path := range files {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+	}
+	fmt.Printf("WARNING: no %s database found (%s), rules and entries using it will not match!\n", kind, strings.Join(files, " or "))
+}
+
+//rulesMatchName : describe profile and sni/host a rule is matched with for display
+func rulesMatchName(rule store.Rule) string {
+	var match []string
+	if rule.Profile != "" {
+		match = append(match, rule.Profile)
+	}
+	if rule.SNI != "" {
+		match = append(match, "sni="+rule.SNI)
+	}
+	if rule.Host != "" {
+		match = append(match, "host="+rule.Host)
+	}
+	if rule.UID != "" {
+		match = append(match, "uid="+rule.UID)
+	}
+	if rule.GID != "" {
+		match = append(match, "gid="+rule.GID)
+	}
+	if rule.Exe != "" {
+		match = append(match, "exe="+rule.Exe)
+	}
+	if rule.SrcCountry != "" {
+		match = append(match, "src-country="+rule.SrcCountry)
+	}
+	if rule.DstCountry != "" {
+		match = append(match, "dst-country="+rule.DstCountry)
+	}
+	if rule.Action != "" {
+		match = append(match, "action="+rule.Action)
+	}
+	return strings.Join(match, " ")
+}
+
+//rulesGetProfile : collect service/application profile reference after verifying the profile exists
+func rulesGetProfile(c *cli.Context) string {
+	service, app := c.String("service"), c.String("app")
+	var ref string
+	switch {
+	case service != "" && app != "":
+		cliError(c, "Flags: \"service\" and \"app\" must not be used at once!")
+	case service != "":
+		ref = profiles.ServiceRef(service)
+	case app != "":
+		ref = profiles.AppRef(app)
+	default:
+		return ""
+	}
+	if _, err := profilesLoad(c).Lookup(ref); err != nil {
+		cliError(c, fmt.Sprintf("Profile-Error: %s", err.Error()))
+	}
+	return ref
 }
 
 //rulesGetIndex: pull index and verify validity
-func rulesGetIndex(c *cli.Context) int64 {
+func rulesGetIndex(c *cli.Context) int {
 	// get index from arguments
-	index, err := strconv.ParseInt(c.String("rulenum"), 10, 64)
+	index, err := strconv.Atoi(c.String("rulenum"))
 	if err != nil {
 		cliError(c, "Flag: \"rulenum\" is NOT an INTEGER!")
 	}
@@ -119,13 +418,12 @@ func rulesGetIndex(c *cli.Context) int64 {
 		cliError(c, "Flag: \"rulenum\" must be >= 0")
 	}
 	// check that the index is not too high
-	var lastnum int64
-	err = db.QueryRow("SELECT IFNULL(max(RuleNum)+1, 0) FROM rules").Scan(&lastnum)
+	lastnum, err := st.NextRuleNum()
 	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
 	if index > lastnum {
-		cliError(c, fmt.Sprintf("Flag: \"rulenum\" must be %d or below", index))
+		cliError(c, fmt.Sprintf("Flag: \"rulenum\" must be %d or below", lastnum))
 	}
 	return index
 }
@@ -133,33 +431,23 @@ func rulesGetIndex(c *cli.Context) int64 {
 //rulesAppend : append a new rule within rules table
 func rulesAppend(c *cli.Context) {
 	// get variables via flags
-	zone, sip, sport, dip, dport := rulesGetArgs(c)
+	rule := rulesGetArgs(c)
 	// run append
-	if _, err := db.Exec(
-		"INSERT INTO rules VALUES ((SELECT IFNULL(max(RuleNum)+1,0) FROM rules),?,?,?,?,?);",
-		zone, sip, sport, dip, dport,
-	); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
+	guardChange(c, touchesSession(rule), func(tx *store.Store) error {
+		return tx.AppendRule(rule)
+	})
 	fmt.Println("Rule Appended...")
 }
 
 //rulesInsert : insert a new rule within rules table at an index
 func rulesInsert(c *cli.Context) {
 	// get variables
-	zone, sip, sport, dip, dport := rulesGetArgs(c)
+	rule := rulesGetArgs(c)
 	index := rulesGetIndex(c)
-	// update all rules after used index to one index above
-	if _, err := db.Exec("UPDATE rules SET RuleNum=RuleNum+1 WHERE RuleNum >= ?;", index); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
-	// insert new rule into proper place
-	if _, err := db.Exec(
-		"INSERT INTO rules VALUES (?,?,?,?,?,?);",
-		index, zone, sip, sport, dip, dport,
-	); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
+	// insert new rule into proper place and shift following rules
+	guardChange(c, touchesSession(rule), func(tx *store.Store) error {
+		return tx.InsertRule(index, rule)
+	})
 	fmt.Println("Rule Inserted...")
 }
 
@@ -167,17 +455,29 @@ func rulesInsert(c *cli.Context) {
 func rulesDelete(c *cli.Context) {
 	// get variables
 	index := rulesGetIndex(c)
-	// delete given rule
-	if _, err := db.Exec("DELETE FROM rules WHERE RuleNum=?;", index); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
-	// update all rules after used index to one index below
-	if _, err := db.Exec("UPDATE rules SET RuleNum=RuleNum-1 WHERE RuleNum > ?;", index); err != nil {
-		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-	}
+	// delete given rule and shift following rules
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.DeleteRule(index)
+	})
 	fmt.Println("Rule Removed...")
 }
 
+//rulesAudit : place existing rule within or take it out of audit mode
+func rulesAudit(c *cli.Context) {
+	// get variables
+	index := rulesGetIndex(c)
+	enforce := c.Bool("enforce")
+	// update audit mode of the given rule (enforcing it starts dropping packets so the change is provisional)
+	guardChange(c, enforce, func(tx *store.Store) error {
+		return tx.SetRuleAudit(index, !enforce)
+	})
+	if enforce {
+		fmt.Println("Rule Enforced...")
+	} else {
+		fmt.Println("Rule Audited...")
+	}
+}
+
 //rulesFlush : remove all rules from rules table
 func rulesFlush(c *cli.Context) {
 	// get variables
@@ -199,9 +499,9 @@ func rulesFlush(c *cli.Context) {
 	}
 	// do deletion or abort
 	if delete {
-		if _, err := db.Exec("DELETE FROM rules;"); err != nil {
-			cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
-		}
+		guardChange(c, true, func(tx *store.Store) error {
+			return tx.FlushRules()
+		})
 		fmt.Println("Rules Deleted...")
 	} else {
 		fmt.Println("Aborting Operation...")
@@ -210,21 +510,21 @@ func rulesFlush(c *cli.Context) {
 
 //rulesDisplay : display all existing firewall rules
 func rulesDisplay(c *cli.Context) {
-	rows, err := db.Query("SELECT * FROM rules ORDER BY RuleNum")
+	rules, err := st.Rules()
 	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
-	var rule *rulesRecord
-	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
-	fmt.Println("   #  |   Zone   |        SrcIP       | SrcPort |        DstIP       | DstPort ")
-	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
-	for rows.Next() {
-		rule = new(rulesRecord)
-		rows.Scan(&rule.RuleNum, &rule.Zone, &rule.FromIP, &rule.FromPort, &rule.ToIP, &rule.ToPort)
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("   #  |   Zone   |  NetZone   |     Ifaces      |        SrcIP       | SrcPort |        DstIP       | DstPort | Audit | Profile/Match ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, rule := range rules {
+		var ifaces string
+		if rule.InIface != "" || rule.OutIface != "" {
+			ifaces = rulesIfaceName(rule.InIface) + ">" + rulesIfaceName(rule.OutIface)
+		}
 		fmt.Printf(
-			" %-4d | %-8s | %-18s | %-7s | %-18s | %-7s \n",
-			rule.RuleNum, rule.Zone, rule.FromIP, rule.FromPort, rule.ToIP, rule.ToPort,
+			" %-4d | %-8s | %-10s | %-15s | %-18s | %-7s | %-18s | %-7s | %-5t | %s \n",
+			rule.RuleNum, rule.Zone, rule.NetZone, ifaces, rule.FromIP, rule.FromPort, rule.ToIP, rule.ToPort, rule.Audit, rulesMatchName(rule),
 		)
 	}
-	rows.Close()
 }
@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"goaway2"
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//spaDefaultPort : udp port spa packets are sent to by default
+const spaDefaultPort = 62201
+
+var spaNameArg = cli.StringFlag{
+	Name:  "name, n",
+	Usage: "the name of the spa profile",
+}
+var spaAddArgs = []cli.Flag{
+	spaNameArg,
+	cli.Int64Flag{
+		Name:  "port, p",
+		Value: spaDefaultPort,
+		Usage: "udp port spa packets are sent to (stays closed)",
+	},
+	cli.StringFlag{
+		Name:  "access, a",
+		Usage: "ports clients may request (e.g. 22/tcp,443/tcp)",
+	},
+	cli.StringFlag{
+		Name:  "key, k",
+		Usage: "base64 encoded hmac key shared with the clients (default: generated)",
+	},
+	cli.DurationFlag{
+		Name:  "duration, d",
+		Value: 5 * time.Minute,
+		Usage: "time a requested port stays open for new connections",
+	},
+	cli.DurationFlag{
+		Name:  "max-age",
+		Value: 30 * time.Second,
+		Usage: "time the clock of a client may differ from the local clock",
+	},
+}
+var spaRemoveArgs = []cli.Flag{
+	spaNameArg,
+}
+var knockArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "server, s",
+		Usage: "hostname or ip-address of the firewall",
+	},
+	cli.Int64Flag{
+		Name:  "port, p",
+		Value: spaDefaultPort,
+		Usage: "udp port of the spa profile",
+	},
+	cli.StringFlag{
+		Name:  "access, a",
+		Usage: "port requested to be opened (e.g. 22/tcp)",
+	},
+	cli.StringFlag{
+		Name:  "key-file, f",
+		Usage: "file containing the base64 encoded hmac key of the spa profile",
+	},
+	cli.StringFlag{
+		Name:  "key, k",
+		Usage: "base64 encoded hmac key of the spa profile (visible to other local users, prefer --key-file)",
+	},
+	cli.StringFlag{
+		Name:  "allow-ip",
+		Usage: "ip-address to open the port for (default: the address the packet arrives from)",
+	},
+}
+
+/***Functions***/
+
+//spaGetPort : collect udp port flag after verifying its range
+func spaGetPort(c *cli.Context) int64 {
+	port := c.Int64("port")
+	if port < 1 || port > 65535 {
+		cliError(c, "Flag: \"port\" value is INVALID! (1-65535)")
+	}
+	return port
+}
+
+//spaAdd : create a spa profile guarding the ports it grants access to
+func spaAdd(c *cli.Context) {
+	a := store.SPA{Name: c.String("name"), Port: spaGetPort(c), Key: c.String("key")}
+	if !knocksName.MatchString(a.Name) {
+		cliError(c, "Flag: \"name\" value is INVALID! (letters/digits/-/_)")
+	}
+	access, err := goaway2.ParseKnockAccess(c.String("access"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"access\" value is INVALID! (%s)", err.Error()))
+	}
+	for _, port := range access {
+		if port.Port == a.Port && port.Protocol == "udp" {
+			cliError(c, "Flag: \"access\" value is INVALID! (must not contain the spa port)")
+		}
+	}
+	a.Access = goaway2.FormatKnockPorts(access)
+	if a.Key == "" {
+		if a.Key, err = goaway2.GenerateSPAKey(); err != nil {
+			cliError(c, fmt.Sprintf("Unable to generate key: %s", err.Error()))
+		}
+	} else if _, err = goaway2.ParseSPAKey(a.Key); err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"key\" value is INVALID! (%s)", err.Error()))
+	}
+	a.Duration, a.MaxAge = knocksGetDuration(c, "duration"), knocksGetDuration(c, "max-age")
+	_, exists, err := st.SPA(a.Name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if exists {
+		fmt.Printf("SPA: %q already exists", a.Name)
+		return
+	}
+	// guarding the port of the callers ssh connection is detected as a lockout and made provisional
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.AddSPA(a)
+	})
+	fmt.Println("SPA Added...")
+	if c.String("key") == "" {
+		fmt.Printf("Key: %s (store it within the key file of the clients)\n", a.Key)
+	}
+}
+
+//spaRemove : remove a spa profile, opening its ports to the rules again
+func spaRemove(c *cli.Context) {
+	name := c.String("name")
+	_, exists, err := st.SPA(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !exists {
+		cliError(c, fmt.Sprintf("SPA: %q does not exist!", name))
+	}
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.RemoveSPA(name)
+	})
+	fmt.Println("SPA Removed...")
+}
+
+//spaDisplay : display all spa profiles (without their keys)
+func spaDisplay(c *cli.Context) {
+	spas, err := st.SPAs()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("      Name      |   Port    | Duration | Max Age  |           Access           ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, a := range spas {
+		duration, maxAge := time.Duration(a.Duration)*time.Second, time.Duration(a.MaxAge)*time.Second
+		fmt.Printf(" %-14s | %5d/udp | %-8s | %-8s | %s \n", a.Name, a.Port, duration, maxAge, a.Access)
+	}
+}
+
+//knockSend : send a spa packet requesting a port of a remote firewall to be opened
+func knockSend(c *cli.Context) {
+	server := c.String("server")
+	if server == "" {
+		cliError(c, "Flag: \"server\" must not be blank!")
+	}
+	port := spaGetPort(c)
+	access, err := goaway2.ParseKnockPort(c.String("access"))
+	if err != nil {
+		cliError(c, fmt.Sprintf("Flag: \"access\" value is INVALID! (%s)", err.Error()))
+	}
+	allowIP := c.String("allow-ip")
+	if allowIP != "" && net.ParseIP(allowIP).To4() == nil {
+		cliError(c, "Flag: \"allow-ip\" value is INVALID! (ipv4-address)")
+	}
+	encoded := c.String("key")
+	if c.String("key-file") != "" {
+		raw, err := ioutil.ReadFile(c.String("key-file"))
+		if err != nil {
+			cliError(c, fmt.Sprintf("Unable to read key file: %s", err.Error()))
+		}
+		encoded = string(raw)
+	}
+	if encoded == "" {
+		cliError(c, "Flag: \"key-file\" or \"key\" is required!")
+	}
+	key, err := goaway2.ParseSPAKey(encoded)
+	if err != nil {
+		cliError(c, err.Error())
+	}
+	req, err := goaway2.NewSPARequest(access, allowIP, time.Now())
+	if err != nil {
+		cliError(c, fmt.Sprintf("Unable to create request: %s", err.Error()))
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(server, strconv.FormatInt(port, 10)))
+	if err != nil {
+		cliError(c, fmt.Sprintf("Unable to reach server: %s", err.Error()))
+	}
+	defer conn.Close()
+	if _, err = conn.Write(goaway2.EncodeSPA(key, req)); err != nil {
+		cliError(c, fmt.Sprintf("Unable to send spa packet: %s", err.Error()))
+	}
+	fmt.Printf("SPA packet sent to %s requesting %s\n", conn.RemoteAddr(), access)
+}
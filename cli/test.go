@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"goaway2"
+	"goaway2/geoip"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+var testArgs = []cli.Flag{
+	cli.StringFlag{
+		Name:  "src",
+		Usage: "source ip-address of the hypothetical packet",
+	},
+	cli.Int64Flag{
+		Name:  "sport",
+		Usage: "source port of the hypothetical packet",
+	},
+	cli.StringFlag{
+		Name:  "dst",
+		Usage: "destination ip-address of the hypothetical packet",
+	},
+	cli.Int64Flag{
+		Name:  "dport",
+		Usage: "destination port of the hypothetical packet",
+	},
+	cli.StringFlag{
+		Name:  "proto",
+		Value: "tcp",
+		Usage: "protocol of the hypothetical packet (tcp/udp/icmp)",
+	},
+	cli.StringFlag{
+		Name:  "iniface",
+		Usage: "interface the hypothetical packet is received on (default: from the local destination)",
+	},
+	cli.StringFlag{
+		Name:  "outiface",
+		Usage: "interface the hypothetical packet is sent out of (default: from the local source)",
+	},
+	cli.StringFlag{
+		Name:  "hook",
+		Usage: "netfilter hook the hypothetical packet is queued from (input/output/forward) (default: from the local source)",
+	},
+	cli.StringFlag{
+		Name:  "sni",
+		Usage: "tls server name the hypothetical packet carries (makes it the first data packet of the flow)",
+	},
+	cli.StringFlag{
+		Name:  "host",
+		Usage: "http host header the hypothetical packet carries (makes it the first data packet of the flow)",
+	},
+	cli.BoolFlag{
+		Name:  "payload",
+		Usage: "treat the hypothetical packet as a data packet without a server name or host header",
+	},
+	cli.Int64Flag{
+		Name:  "uid",
+		Value: -1,
+		Usage: "user id owning the local socket of the hypothetical packet (default: unknown owner)",
+	},
+	cli.Int64Flag{
+		Name:  "gid",
+		Value: -1,
+		Usage: "group id of the process owning the local socket of the hypothetical packet",
+	},
+	cli.StringFlag{
+		Name:  "exe",
+		Usage: "executable of the process owning the local socket of the hypothetical packet",
+	},
+}
+
+/***Functions***/
+
+//testGetPort : collect given flag argument from context after verifying its a valid port-number
+func testGetPort(c *cli.Context, flag string) int64 {
+	port := c.Int64(flag)
+	if port < 0 || port > 65535 {
+		cliError(c, fmt.Sprintf("Flag: %q value must be between 0 and 65535!", flag))
+	}
+	return port
+}
+
+//testGetPacket : collect and verify hypothetical packet data from flags
+func testGetPacket(c *cli.Context) *goaway2.PacketData {
+	pkt := &goaway2.PacketData{
+		SrcIP:    getIP(c, "src"),
+		SrcPort:  testGetPort(c, "sport"),
+		DstIP:    getIP(c, "dst"),
+		DstPort:  testGetPort(c, "dport"),
+		Protocol: strings.ToUpper(c.String("proto")),
+		InIface:  c.String("iniface"),
+		OutIface: c.String("outiface"),
+		Hook:     strings.ToUpper(c.String("hook")),
+		SNI:      strings.ToLower(c.String("sni")),
+		Host:     strings.ToLower(c.String("host")),
+	}
+	pkt.Payload = c.Bool("payload") || pkt.SNI != "" || pkt.Host != ""
+	// without data the tcp packet is the SYN opening the connection
+	pkt.Syn = pkt.Protocol == "TCP" && !pkt.Payload
+	if strings.Contains(pkt.SrcIP, "/") || pkt.SrcIP == "any" {
+		cliError(c, "Flag: \"src\" must be a single ip-address!")
+	}
+	if strings.Contains(pkt.DstIP, "/") || pkt.DstIP == "any" {
+		cliError(c, "Flag: \"dst\" must be a single ip-address!")
+	}
+	switch pkt.Protocol {
+	case "TCP", "UDP", "ICMP":
+	default:
+		cliError(c, "Flag: \"proto\" value is INVALID! (tcp/udp/icmp)")
+	}
+	switch pkt.Hook {
+	case "", goaway2.HookInput, goaway2.HookOutput, goaway2.HookForward:
+	default:
+		cliError(c, "Flag: \"hook\" value is INVALID! (input/output/forward)")
+	}
+	if pkt.SNI != "" && pkt.Host != "" {
+		cliError(c, "Flags: \"sni\" and \"host\" must not be used at once!")
+	}
+	pkt.ResolveInterfaces()
+	// simulated owners replace the lookup of the local socket
+	if uid := c.Int64("uid"); uid >= 0 {
+		pkt.Owner = &goaway2.Owner{UID: uid, GID: c.Int64("gid"), Exe: c.String("exe")}
+	} else if c.Int64("gid") >= 0 || c.String("exe") != "" {
+		cliError(c, "Flag: \"uid\" must be set along with \"gid\"/\"exe\"!")
+	}
+	return pkt
+}
+
+//testVerdict : convert netfilter verdict into readable string
+func testVerdict(v netfilter.Verdict) string {
+	if v == netfilter.NF_ACCEPT {
+		return "ACCEPT"
+	}
+	return "DROP"
+}
+
+//testLookup : describe country/autonomous system of an address for display
+func testLookup(db *geoip.DB, ip string) string {
+	if value := db.Lookup(ip); value != "" {
+		return value
+	}
+	return "unknown"
+}
+
+//testEntry : describe blacklist entry that matched when it is not the address itself
+func testEntry(d goaway2.Decision, ip string) string {
+	if d.Entry == "" || d.Entry == ip {
+		return ""
+	}
+	return fmt.Sprintf(" (entry %s)", d.Entry)
+}
+
+//testPacket : run hypothetical packet through firewall logic and display the decision
+func testPacket(c *cli.Context) {
+	pkt := testGetPacket(c)
+	// load firewall using the current database without touching netfilter
+	fw := goaway2.NewFirewall(st)
+	feedsLoad(c, fw.Feeds())
+	// tcp data is queued once the handshake of its flow is accepted, decide both like the daemon does
+	var d goaway2.Decision
+	var flow bool
+	if syn := *pkt; pkt.Payload && pkt.Protocol == "TCP" {
+		syn.Syn, syn.Payload, syn.SNI, syn.Host = true, false, "", ""
+		if d = fw.Decide(goaway2.NewRedBlackKV(), &syn); d.Verdict == netfilter.NF_ACCEPT {
+			d, flow = fw.DecideFlow(pkt), true
+		}
+	} else {
+		d = fw.Decide(goaway2.NewRedBlackKV(), pkt)
+	}
+	// display decision path
+	fmt.Printf("Packet:  %s %s:%d -> %s:%d\n", pkt.Protocol, pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort)
+	fmt.Printf("Verdict: %s\n", testVerdict(d.Verdict))
+	if pkt.Owner != nil {
+		fmt.Printf("Owner:   uid=%d gid=%d exe=%s\n", pkt.Owner.UID, pkt.Owner.GID, pkt.Owner.Exe)
+	}
+	// countries/autonomous systems matched by rules and blacklist entries
+	if geo := fw.GeoIP(); geo != nil {
+		fmt.Printf("Country: %s -> %s (%s)\n", testLookup(geo, pkt.SrcIP), testLookup(geo, pkt.DstIP), geo.Path)
+	}
+	if asns := fw.ASN(); asns != nil {
+		fmt.Printf("ASN:     %s -> %s (%s)\n", testLookup(asns, pkt.SrcIP), testLookup(asns, pkt.DstIP), asns.Path)
+	}
+	if d.NetZone != "" {
+		fmt.Printf("Zone:    %s (interface %s)\n", d.NetZone, pkt.Iface(d.Direction))
+	}
+	switch d.Reason {
+	case "blacklist-src":
+		fmt.Printf("Reason:  source %s is blacklisted%s\n", pkt.SrcIP, testEntry(d, pkt.SrcIP))
+	case "blacklist-dst":
+		fmt.Printf("Reason:  destination %s is blacklisted%s\n", pkt.DstIP, testEntry(d, pkt.DstIP))
+	case "whitelist":
+		fmt.Printf("Reason:  source %s is whitelisted\n", pkt.SrcIP)
+	case "honeypot":
+		switch {
+		case d.Trip:
+			fmt.Printf("Reason:  port %d is a honeypot, the source gets blacklisted as %q\n", pkt.DstPort, d.Honeypot)
+		case d.Challenge:
+			fmt.Printf("Reason:  port %d is a honeypot, the SYN is answered to verify the source by its handshake\n", pkt.DstPort)
+		default:
+			fmt.Printf("Reason:  port %d is a honeypot, the packet is dropped without verifying its source\n", pkt.DstPort)
+		}
+	case "knock":
+		if d.Verdict == netfilter.NF_ACCEPT {
+			fmt.Printf("Reason:  port %d is opened for %s by knock/spa profile %s\n", pkt.DstPort, pkt.SrcIP, d.Knock)
+		} else {
+			fmt.Printf("Reason:  port %d is guarded by knock/spa profile %s (the source did not knock)\n", pkt.DstPort, d.Knock)
+		}
+	case "rule":
+		switch {
+		case d.Action != "":
+			fmt.Printf("Reason:  rule #%d matched (action is %s)\n", d.RuleNum, d.Action)
+		case d.Default != "deny":
+			fmt.Printf("Reason:  rule #%d matched (%s default is %s)\n", d.RuleNum, d.Direction, d.Default)
+		default:
+			fmt.Printf("Reason:  rule #%d did not match (%s default is deny)\n", d.RuleNum, d.Direction)
+		}
+		fmt.Printf("Rule:    %s\n", d.Rule)
+		if d.Audit {
+			fmt.Println("Audit:   rule is in audit mode, the drop is only recorded")
+		}
+	case "payload":
+		fmt.Printf("Reason:  the ClientHello/request can not be read, sni/host rule #%d fails closed\n", d.RuleNum)
+		fmt.Printf("Rule:    %s\n", d.Rule)
+	case "answer":
+		fmt.Printf("Reason:  no rule decided the packet, prompt answer #%d did (see 'goaway prompt answers')\n", d.RuleNum)
+		fmt.Printf("Rule:    %s\n", d.Rule)
+	case "ask":
+		fmt.Printf("Reason:  no rule decided the packet, it is held for a prompt (unanswered: %s)\n", testVerdict(d.Verdict))
+	case "flow":
+		fmt.Println("Reason:  the handshake of the flow was accepted and no sni/host rule blocked its data")
+	default:
+		fmt.Printf("Reason:  no rule blocked the packet (%s default is %s)\n", d.Direction, d.Default)
+	}
+	if d.Tarpit {
+		fmt.Println("Tarpit:  SYNs are answered with a zero-window SYN-ACK and the connection is ignored")
+	}
+	if d.Pending {
+		fmt.Println("Pending: sni/host rules are decided once the ClientHello/request is read (see --sni/--host/--payload)")
+	}
+	if flow && d.Verdict == netfilter.NF_DROP && !fw.Audit {
+		fmt.Println("Reset:   the flow is reset and its later packets are rejected")
+	}
+	if fw.Audit && d.Verdict == netfilter.NF_DROP {
+		fmt.Println("Audit:   the firewall is in audit mode, the drop is only recorded")
+	}
+}
@@ -4,22 +4,17 @@ import (
 	"fmt"
 	"net"
 
+	"goaway2/store"
+
 	cli "gopkg.in/urfave/cli.v1"
 )
 
-/***Variables***/
-
-type whitelistRecord struct {
-	IPAddress string
-	EntryDate string
-}
-
 /***Functions***/
 
 //whitelistAppend : append given ip-address to whitelist
 func whitelistAppend(c *cli.Context) {
 	// get variables
-	ip := getIPWithDuplicate(c, "whitelist")
+	ip := getIPWithDuplicate(c, store.Whitelist)
 	// ensure ip is not a range
 	if _, _, err := net.ParseCIDR(ip); err == nil {
 		cliError(c, "Flag: \"ipaddress\" must not be an IP-Range!")
@@ -29,7 +24,7 @@ func whitelistAppend(c *cli.Context) {
 		cliError(c, "Flag: \"reason\" must not be blank!")
 	}
 	// run append
-	if _, err := db.Exec("INSERT INTO whitelist VALUES(?,datetime('now'),?,0);", ip, reason); err != nil {
+	if err := st.AddEntry(store.Whitelist, store.Entry{IPAddress: ip, Reason: reason}); err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
 	fmt.Println("Entry added to whitelist")
@@ -39,7 +34,7 @@ func whitelistAppend(c *cli.Context) {
 func whitelistRemove(c *cli.Context) {
 	ip := getIP(c, "ipaddress")
 	// run delete
-	if _, err := db.Exec("DELETE FROM whitelist WHERE IPAddress=?;", ip); err != nil {
+	if err := st.RemoveEntry(store.Whitelist, ip); err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
 	fmt.Println("Entry removed from whitelist")
@@ -47,20 +42,14 @@ func whitelistRemove(c *cli.Context) {
 
 //whitelistDisplay: display all ip-addresses in whitelist
 func whitelistDisplay(c *cli.Context) {
-	rows, err := db.Query("SELECT IPAddress,EntryDate FROM whitelist WHERE LogicalDelete=0")
+	entries, err := st.Entries(store.Whitelist)
 	if err != nil {
 		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
 	}
-	var counter int
-	var rec *whitelistRecord
 	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
 	fmt.Println("   #   |   IP-Address    |      EntryDate      ")
 	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
-	for rows.Next() {
-		rec = new(whitelistRecord)
-		rows.Scan(&rec.IPAddress, &rec.EntryDate)
+	for counter, rec := range entries {
 		fmt.Printf(" %-5d | %-15s | %s \n", counter, rec.IPAddress, rec.EntryDate)
-		counter++
 	}
-	rows.Close()
 }
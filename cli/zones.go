@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"goaway2/store"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/***Variables***/
+
+//netZoneName : valid zone name
+var netZoneName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+//netIfaceName : valid network interface name
+var netIfaceName = regexp.MustCompile(`^[A-Za-z0-9_.:@-]+$`)
+
+var zonesNameArg = cli.StringFlag{
+	Name:  "name, n",
+	Usage: "the name of the zone",
+}
+var zonesIfaceArg = cli.StringFlag{
+	Name:  "iface, i",
+	Usage: "the network interface (e.g. eth0)",
+}
+var zonesAddArgs = []cli.Flag{
+	zonesNameArg,
+	cli.StringFlag{
+		Name:  "inbound",
+		Value: "deny",
+		Usage: "default for inbound packets of the zone (allow/deny)",
+	},
+	cli.StringFlag{
+		Name:  "outbound",
+		Value: "allow",
+		Usage: "default for outbound packets of the zone (allow/deny)",
+	},
+}
+var zonesSetArgs = []cli.Flag{
+	zonesNameArg,
+	cli.StringFlag{
+		Name:  "inbound",
+		Usage: "default for inbound packets of the zone (allow/deny)",
+	},
+	cli.StringFlag{
+		Name:  "outbound",
+		Usage: "default for outbound packets of the zone (allow/deny)",
+	},
+}
+var zonesRemoveArgs = []cli.Flag{
+	zonesNameArg,
+}
+var zonesBindArgs = []cli.Flag{
+	zonesNameArg,
+	zonesIfaceArg,
+}
+var zonesUnbindArgs = []cli.Flag{
+	zonesIfaceArg,
+}
+
+/***Functions***/
+
+//checkNetZone : verify validity of value as a zone name
+func checkNetZone(name string) bool {
+	return netZoneName.MatchString(name)
+}
+
+//zonesGetDefault : collect given flag argument from context after verifying its allow/deny
+func zonesGetDefault(c *cli.Context, flag string) string {
+	value := c.String(flag)
+	if value != "allow" && value != "deny" {
+		cliError(c, fmt.Sprintf("Flag: %q value is INVALID! (allow/deny)", flag))
+	}
+	return value
+}
+
+//zonesGetName : collect zone name from the given flag and verify the zone exists
+func zonesGetName(c *cli.Context, flag string) string {
+	name := c.String(flag)
+	exists, err := st.HasNetZone(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if !exists {
+		cliError(c, fmt.Sprintf("Zone: %q does not exist!", name))
+	}
+	return name
+}
+
+//zonesGetIface : collect interface name and warn when no such interface exists
+func zonesGetIface(c *cli.Context) string {
+	iface := c.String("iface")
+	if iface == "" {
+		cliError(c, "Flag: \"iface\" must not be blank!")
+	}
+	if _, err := net.InterfaceByName(iface); err != nil {
+		fmt.Printf("WARNING: interface %q does not exist (yet)!\n", iface)
+	}
+	return iface
+}
+
+//zonesAdd : create a new zone
+func zonesAdd(c *cli.Context) {
+	name := c.String("name")
+	if !checkNetZone(name) {
+		cliError(c, "Flag: \"name\" value is INVALID! (letters/digits/-/_)")
+	}
+	inbound, outbound := zonesGetDefault(c, "inbound"), zonesGetDefault(c, "outbound")
+	exists, err := st.HasNetZone(name)
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	if exists {
+		fmt.Printf("Zone: %q already exists", name)
+		return
+	}
+	if err = st.AddNetZone(name, inbound, outbound); err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("Zone Added...")
+}
+
+//zonesRemove : remove an existing zone and its interface bindings
+func zonesRemove(c *cli.Context) {
+	name := zonesGetName(c, "name")
+	guardChange(c, false, func(tx *store.Store) error {
+		return tx.RemoveNetZone(name)
+	})
+	fmt.Println("Zone Removed...")
+}
+
+//zonesSet : set the defaults of an existing zone
+func zonesSet(c *cli.Context) {
+	name := zonesGetName(c, "name")
+	zones, err := st.NetZones()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	// keep defaults that are not given
+	var inbound, outbound string
+	for _, z := range zones {
+		if z.Name == name {
+			inbound, outbound = z.Inbound, z.Outbound
+		}
+	}
+	if c.String("inbound") == "" && c.String("outbound") == "" {
+		cliError(c, "Set requires at least one flag!")
+	}
+	if c.String("inbound") != "" {
+		inbound = zonesGetDefault(c, "inbound")
+	}
+	if c.String("outbound") != "" {
+		outbound = zonesGetDefault(c, "outbound")
+	}
+	// denying by default may lock out the caller so the change is provisional
+	guardChange(c, inbound == "deny" || outbound == "deny", func(tx *store.Store) error {
+		return tx.SetNetZoneDefaults(name, inbound, outbound)
+	})
+	fmt.Printf("Zone: %s Inbound: %s Outbound: %s\n", name, inbound, outbound)
+}
+
+//zonesBind : bind network interface to an existing zone
+func zonesBind(c *cli.Context) {
+	name := zonesGetName(c, "name")
+	iface := zonesGetIface(c)
+	// binding changes the policy of the whole interface so the change is provisional
+	guardChange(c, true, func(tx *store.Store) error {
+		return tx.BindInterface(iface, name)
+	})
+	fmt.Printf("Interface: %s bound to zone: %s\n", iface, name)
+}
+
+//zonesUnbind : remove the zone binding of a network interface
+func zonesUnbind(c *cli.Context) {
+	iface := c.String("iface")
+	if iface == "" {
+		cliError(c, "Flag: \"iface\" must not be blank!")
+	}
+	guardChange(c, true, func(tx *store.Store) error {
+		return tx.UnbindInterface(iface)
+	})
+	fmt.Printf("Interface: %s unbound\n", iface)
+}
+
+//zonesDisplay : display all zones along with their defaults and interfaces
+func zonesDisplay(c *cli.Context) {
+	zones, err := st.NetZones()
+	if err != nil {
+		cliError(c, fmt.Sprintf("SQL-ERROR: %s", err.Error()))
+	}
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	fmt.Println("       Zone       | Inbound | Outbound |      Interfaces        ")
+	fmt.Println("~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
+	for _, z := range zones {
+		fmt.Printf(" %-16s | %-7s | %-8s | %s \n", z.Name, z.Inbound, z.Outbound, strings.Join(z.Interfaces, ","))
+	}
+}
@@ -0,0 +1,158 @@
+package goaway2
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/***Variables***/
+
+//ControlSocket : path of the unix socket the daemon serves the control api on
+// the socket is created within a directory only root may enter
+const ControlSocket = "/run/goaway/control.sock"
+
+//ControlRequest : request sent to the control api
+type ControlRequest struct {
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+}
+
+//ControlResponse : response returned by the control api
+type ControlResponse struct {
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+//ControlHandler : function answering a control api command
+type ControlHandler func(args json.RawMessage) (interface{}, error)
+
+//ControlServer : unix socket api used by the cli to query the running daemon
+// requests and responses are newline separated json documents
+type ControlServer struct {
+	Path   string
+	Logger *log.Logger
+
+	lock     sync.RWMutex
+	handlers map[string]ControlHandler
+}
+
+/***Functions***/
+
+//NewControlServer : create control api serving the builtin commands on the given socket path
+func NewControlServer(path string, logger *log.Logger) *ControlServer {
+	s := &ControlServer{Path: path, Logger: logger, handlers: make(map[string]ControlHandler)}
+	s.Handle("addresses", func(json.RawMessage) (interface{}, error) {
+		return LocalAddrs(), nil
+	})
+	return s
+}
+
+//ControlCall : send command to the control api at the given path and decode its result
+func ControlCall(path, command string, args, result interface{}) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("unable to reach daemon: %s", err.Error())
+	}
+	defer conn.Close()
+	req := ControlRequest{Command: command}
+	if args != nil {
+		if req.Args, err = json.Marshal(args); err != nil {
+			return err
+		}
+	}
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var resp ControlResponse
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if result == nil || resp.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+/***Methods***/
+
+//(*ControlServer).Handle : register handler for the given command
+func (s *ControlServer) Handle(command string, fn ControlHandler) {
+	s.lock.Lock()
+	s.handlers[command] = fn
+	s.lock.Unlock()
+}
+
+//(*ControlServer).Serve : listen on the socket and answer requests until the listener fails
+func (s *ControlServer) Serve() error {
+	// only root may control the daemon, so the socket is never reachable by others
+	// (not even between creating it and restricting its mode)
+	if err := controlDir(filepath.Dir(s.Path)); err != nil {
+		return err
+	}
+	os.Remove(s.Path)
+	l, err := net.Listen("unix", s.Path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if err = os.Chmod(s.Path, 0600); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+//(*ControlServer).serveConn : answer requests of a single connection
+func (s *ControlServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	// check the credentials of the peer as well in case the socket was made reachable
+	uid, err := controlPeer(conn)
+	if err != nil || (uid != 0 && uid != os.Geteuid()) {
+		s.Logger.Printf("Refused control connection of uid %d: %v\n", uid, err)
+		return
+	}
+	dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+	for {
+		var req ControlRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if err := enc.Encode(s.answer(req)); err != nil {
+			s.Logger.Printf("Unable to answer control request: %s\n", err.Error())
+			return
+		}
+	}
+}
+
+//(*ControlServer).answer : run handler of the requested command
+func (s *ControlServer) answer(req ControlRequest) (resp ControlResponse) {
+	s.lock.RLock()
+	fn, ok := s.handlers[req.Command]
+	s.lock.RUnlock()
+	if !ok {
+		resp.Error = fmt.Sprintf("unknown command %q", req.Command)
+		return resp
+	}
+	result, err := fn(req.Args)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	if resp.Result, err = json.Marshal(result); err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
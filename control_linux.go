@@ -0,0 +1,48 @@
+package goaway2
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+/***Functions***/
+
+//controlPeer : return the uid of the process connected to the control socket (SO_PEERCRED)
+func controlPeer(conn net.Conn) (int, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1, fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var cred *syscall.Ucred
+	if cerr := raw.Control(func(fd uintptr) {
+		cred, err = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); cerr != nil {
+		return -1, cerr
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(cred.Uid), nil
+}
+
+//controlDir : create the directory of the control socket and make sure only the daemon may enter it
+func controlDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	// refuse symlinks and directories created by someone else
+	if stat, ok := info.Sys().(*syscall.Stat_t); !info.IsDir() || !ok || int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("control socket directory %s is not a directory owned by the daemon", dir)
+	}
+	return os.Chmod(dir, 0700)
+}
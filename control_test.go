@@ -0,0 +1,54 @@
+package goaway2
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/***Unit-Tests***/
+
+func TestControlServer(t *testing.T) {
+	defer restoreAddrs(t)()
+	setLocalAddrs(map[string]string{"10.1.0.1": "eth0"})
+	dir, err := ioutil.TempDir("", "goaway-control")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s\n", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "goaway", "control.sock")
+	s := NewControlServer(path, log.New(ioutil.Discard, "", 0))
+	s.Handle("fail", func(json.RawMessage) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	go s.Serve()
+	// wait for the socket to appear
+	for i := 0; i < 200; i++ {
+		if _, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// check the socket is created within a directory only the daemon may enter
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("Unexpected socket directory: %v %v\n", info, err)
+	}
+	var addrs map[string]string
+	if err = ControlCall(path, "addresses", nil, &addrs); err != nil {
+		t.Fatalf("Unable to call control api: %s\n", err.Error())
+	}
+	if len(addrs) != 1 || addrs["10.1.0.1"] != "eth0" {
+		t.Fatalf("Unexpected addresses: %v\n", addrs)
+	}
+	if err = ControlCall(path, "fail", nil, nil); err == nil || err.Error() != "failed" {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if err = ControlCall(path, "bogus", nil, nil); err == nil {
+		t.Fatalf("Able to call unknown command\n")
+	}
+}
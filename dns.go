@@ -0,0 +1,193 @@
+package goaway2
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/***Variables***/
+
+const (
+	// minDNSTTL : shortest time an observed address stays associated with its hostname
+	minDNSTTL = 60 * time.Second
+	// resolveTTL : time addresses resolved while loading rules stay associated with their hostname
+	resolveTTL = 5 * time.Minute
+	// dnsPurgeInterval : interval expired addresses are purged from the cache
+	dnsPurgeInterval = time.Minute
+)
+
+//resolveHost : resolver used for hostnames of rules (replaced within tests)
+var resolveHost = net.LookupIP
+
+//DNSCache : hostnames of ip-addresses learned from observed dns answers and rule resolution
+type DNSCache struct {
+	lock      sync.RWMutex
+	names     map[string]map[string]time.Time // ip -> hostname -> expiry
+	nextPurge time.Time
+}
+
+/***Functions***/
+
+//NewDNSCache : create empty dns cache
+func NewDNSCache() *DNSCache {
+	return &DNSCache{names: make(map[string]map[string]time.Time)}
+}
+
+//IsHostPattern : check if value is a hostname or wildcard hostname (*.example.com) instead of an ip-address
+func IsHostPattern(value string) bool {
+	if value == "any" || net.ParseIP(value) != nil || strings.Contains(value, "/") {
+		return false
+	}
+	name := strings.TrimPrefix(value, "*.")
+	if name == "" || len(name) > 253 || !strings.Contains(name, ".") {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//matchHost : match hostname against hostname or wildcard hostname (wildcards only match subdomains)
+func matchHost(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(name, pattern[1:])
+	}
+	return name == pattern
+}
+
+//canonicalHost : lowercase hostname without the trailing dot of dns answers
+func canonicalHost(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+/***Methods***/
+
+//(*DNSCache).Add : associate ip-address with the hostname for the given ttl
+func (c *DNSCache) Add(name, ip string, now time.Time, ttl time.Duration) {
+	name, expiry := canonicalHost(name), now.Add(ttl)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	names, ok := c.names[ip]
+	if !ok {
+		names = make(map[string]time.Time)
+		c.names[ip] = names
+	}
+	if expiry.After(names[name]) {
+		names[name] = expiry
+	}
+	// purge expired addresses every once in a while
+	if now.After(c.nextPurge) {
+		c.purge(now)
+		c.nextPurge = now.Add(dnsPurgeInterval)
+	}
+}
+
+//(*DNSCache).purge : remove expired hostnames (lock must be held)
+func (c *DNSCache) purge(now time.Time) {
+	for ip, names := range c.names {
+		for name, expiry := range names {
+			if now.After(expiry) {
+				delete(names, name)
+			}
+		}
+		if len(names) == 0 {
+			delete(c.names, ip)
+		}
+	}
+}
+
+//(*DNSCache).Match : check if ip-address currently belongs to a hostname matching the pattern
+func (c *DNSCache) Match(pattern, ip string, now time.Time) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for name, expiry := range c.names[ip] {
+		if !now.After(expiry) && matchHost(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+//(*DNSCache).Names : return hostnames currently associated with the ip-address
+func (c *DNSCache) Names(ip string, now time.Time) (names []string) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for name, expiry := range c.names[ip] {
+		if !now.After(expiry) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//(*DNSCache).Observe : learn addresses from the dns answer carried by the packet (if any)
+func (c *DNSCache) Observe(pkt gopacket.Packet, now time.Time) {
+	if layer := pkt.Layer(layers.LayerTypeDNS); layer != nil {
+		c.ObserveDNS(layer.(*layers.DNS), now)
+	}
+}
+
+//(*DNSCache).ObserveDNS : learn addresses of the queried hostnames and their aliases from a dns answer
+func (c *DNSCache) ObserveDNS(dns *layers.DNS, now time.Time) {
+	if !dns.QR || dns.ResponseCode != layers.DNSResponseCodeNoErr {
+		return
+	}
+	// collect aliases so addresses are also associated with the names pointing to them
+	aliases := make(map[string][]string)
+	for _, rr := range dns.Answers {
+		if rr.Type == layers.DNSTypeCNAME {
+			target := canonicalHost(string(rr.CNAME))
+			aliases[target] = append(aliases[target], canonicalHost(string(rr.Name)))
+		}
+	}
+	for _, rr := range dns.Answers {
+		if rr.Type != layers.DNSTypeA || rr.IP == nil {
+			continue
+		}
+		ttl := time.Duration(rr.TTL) * time.Second
+		if ttl < minDNSTTL {
+			ttl = minDNSTTL
+		}
+		ip := rr.IP.String()
+		// walk the alias chain back to the queried hostname
+		seen := make(map[string]bool)
+		pending := []string{canonicalHost(string(rr.Name))}
+		for len(pending) > 0 {
+			name := pending[0]
+			pending = pending[1:]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			c.Add(name, ip, now, ttl)
+			pending = append(pending, aliases[name]...)
+		}
+	}
+}
+
+//(*DNSCache).Resolve : resolve hostname and associate its ipv4-addresses with it
+func (c *DNSCache) Resolve(name string, now time.Time) error {
+	ips, err := resolveHost(name)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			c.Add(name, ip.String(), now, resolveTTL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package goaway2
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+/***Functions***/
+
+//replayDNS : feed recorded dns answers into the cache and return the time of the first answer
+func replayDNS(t *testing.T, cache *DNSCache, name string) time.Time {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Unable to open capture: %s\n", err.Error())
+	}
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatalf("Unable to read capture: %s\n", err.Error())
+	}
+	var start time.Time
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		if start.IsZero() {
+			start = ci.Timestamp
+		}
+		cache.Observe(gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default), ci.Timestamp)
+	}
+	if start.IsZero() {
+		t.Fatalf("Capture %q contains no packets\n", name)
+	}
+	return start
+}
+
+/***Unit-Tests***/
+
+func TestDNSCacheObserve(t *testing.T) {
+	cache := NewDNSCache()
+	start := replayDNS(t, cache, "dns.pcap")
+	now := start.Add(10 * time.Second)
+	for _, check := range []struct {
+		pattern, ip string
+		match       bool
+	}{
+		{"github.com", "140.82.112.3", true},
+		{"www.github.com", "140.82.112.3", true}, // alias of github.com
+		{"*.github.com", "140.82.112.6", true},
+		{"*.github.com", "140.82.112.3", true},
+		{"github.com", "140.82.112.6", false},
+		{"*.github.com", "93.184.216.34", false},
+		{"example.org", "93.184.216.34", true},
+	} {
+		if cache.Match(check.pattern, check.ip, now) != check.match {
+			t.Fatalf("Unexpected match of %s for %s: %t\n", check.ip, check.pattern, !check.match)
+		}
+	}
+	// check addresses expire along with the ttl of their answer
+	later := start.Add(2 * time.Minute)
+	if cache.Match("github.com", "140.82.112.3", later) || !cache.Match("api.github.com", "140.82.112.6", later) {
+		t.Fatalf("Unexpected expiry: %v / %v\n", cache.Names("140.82.112.3", later), cache.Names("140.82.112.6", later))
+	}
+}
+
+func TestHostPattern(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"github.com":      true,
+		"*.github.com":    true,
+		"any":             false,
+		"10.0.0.1":        false,
+		"10.0.0.0/8":      false,
+		"localhost":       false,
+		"bad_name.com":    false,
+		"*.":              false,
+		"-github.com":     false,
+		"api.github.com.": false,
+	} {
+		if IsHostPattern(value) != expected {
+			t.Fatalf("Unexpected host pattern check of %q\n", value)
+		}
+	}
+}
+
+func TestFirewallHostRule(t *testing.T) {
+	resolved := resolveHost
+	defer func() { resolveHost = resolved }()
+	resolveHost = func(name string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("198.51.100.7")}, nil
+	}
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AppendRule(store.Rule{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "*.github.com", ToPort: "443"})
+	st.AppendRule(store.Rule{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "blocked.example.net", ToPort: "any"})
+	fw := newFirewall(st, profiles.NewSet())
+	replayDNS(t, fw.DNS(), "dns.pcap")
+	// the recorded answers are long expired, so learn them again as if they just arrived
+	fw.DNS().Add("api.github.com", "140.82.112.6", time.Now(), time.Minute)
+	kv := NewRedBlackKV()
+	for _, check := range []struct {
+		ip   string
+		port int64
+		drop bool
+	}{
+		{"140.82.112.6", 443, true},
+		{"140.82.112.6", 22, false},
+		{"93.184.216.34", 443, false},
+		{"198.51.100.7", 80, true}, // resolved while loading the rules
+	} {
+		pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: check.ip, DstPort: check.port, Protocol: "TCP", Hook: HookOutput}
+		if drop := fw.Decide(kv, pkt).Verdict == netfilter.NF_DROP; drop != check.drop {
+			t.Fatalf("Unexpected verdict for %s:%d: drop=%t\n", check.ip, check.port, drop)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package goaway2
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goaway2/feeds"
+	"goaway2/store"
+)
+
+/***Variables***/
+
+//feedPrefix : prefix of the decision entries naming the blocklist feed that listed an address
+const feedPrefix = "feed:"
+
+//FeedUpdater : keeps the blocklist feeds registered in the store loaded and refreshed on their interval
+type FeedUpdater struct {
+	Store        *store.Store
+	Layer        *feeds.Layer  // layer the feeds are loaded into (e.g. fw.Feeds())
+	Client       *http.Client  // client fetching http mirrors (feeds.DefaultClient if nil)
+	PollInterval time.Duration // interval the registered feeds are checked for due refreshes
+	Logger       *log.Logger
+
+	loaded map[string]loadedFeed // feeds within the layer by name
+}
+
+//loadedFeed : source and time a feed was last loaded from
+type loadedFeed struct {
+	source    string
+	at        time.Time
+	refreshed string // LastRefresh of the feed after it was loaded
+}
+
+/***Functions***/
+
+//FeedEntry : describe blocklist feed as decision entry (feed:name)
+func FeedEntry(name string) string {
+	return feedPrefix + name
+}
+
+//FeedOfEntry : return the feed name of a decision entry ("" if entry is no feed entry)
+func FeedOfEntry(entry string) string {
+	if !strings.HasPrefix(entry, feedPrefix) {
+		return ""
+	}
+	return entry[len(feedPrefix):]
+}
+
+/***Methods***/
+
+//(*FeedUpdater).getPollInterval : return variable with exception
+func (u *FeedUpdater) getPollInterval() time.Duration {
+	if u.PollInterval <= 0 {
+		return time.Minute
+	}
+	return u.PollInterval
+}
+
+//(*FeedUpdater).Refresh : load feed into the layer (if any) and record the result within the store
+// the layer keeps the previously loaded networks of a feed that fails to load
+func (u *FeedUpdater) Refresh(f store.Feed) (int, error) {
+	ranges, err := feeds.Load(u.Client, f.Name, f.Source)
+	if err == nil && u.Layer != nil {
+		u.Layer.Set(f.Name, ranges)
+	}
+	if serr := u.Store.SetFeedStatus(f.Name, int64(len(ranges)), err); serr != nil && err == nil {
+		err = serr
+	}
+	return len(ranges), err
+}
+
+//(*FeedUpdater).Update : load new, changed and due feeds and drop feeds removed from the store
+// feeds refreshed by another process (goaway feeds refresh) are loaded again as well
+func (u *FeedUpdater) Update(now time.Time) {
+	registered, err := u.Store.Feeds()
+	if err != nil {
+		u.Logger.Printf("Unable to collect feeds! SQL-Error: %s\n", err.Error())
+		return
+	}
+	if u.loaded == nil {
+		u.loaded = make(map[string]loadedFeed)
+	}
+	names := make(map[string]bool, len(registered))
+	for _, f := range registered {
+		names[f.Name] = true
+		last, ok := u.loaded[f.Name]
+		if ok && last.source == f.Source && last.refreshed == f.LastRefresh && now.Sub(last.at) < time.Duration(f.Interval)*time.Second {
+			continue
+		}
+		// failed feeds are retried on their next interval as well
+		n, err := u.Refresh(f)
+		if err != nil {
+			u.Logger.Printf("Unable to refresh feed %q: %s\n", f.Name, err.Error())
+		} else {
+			u.Logger.Printf("Refreshed feed %q: %d entries\n", f.Name, n)
+		}
+		refreshed, _, _ := u.Store.Feed(f.Name)
+		u.loaded[f.Name] = loadedFeed{source: f.Source, at: now, refreshed: refreshed.LastRefresh}
+	}
+	for name := range u.loaded {
+		if !names[name] {
+			delete(u.loaded, name)
+			if u.Layer != nil {
+				u.Layer.Remove(name)
+			}
+		}
+	}
+}
+
+//(*FeedUpdater).Run : keep the feeds refreshed until done is closed
+func (u *FeedUpdater) Run(done <-chan struct{}) {
+	u.Update(time.Now())
+	ticker := time.NewTicker(u.getPollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			u.Update(now)
+		}
+	}
+}
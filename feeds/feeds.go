@@ -0,0 +1,222 @@
+package feeds
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goaway2/geoip"
+)
+
+/***Variables***/
+
+//Timeout : maximum time a http mirror may take to serve a feed
+const Timeout = 30 * time.Second
+
+//DefaultClient : http client used to fetch feeds from a http mirror
+var DefaultClient = &http.Client{Timeout: Timeout}
+
+//Layer : blocklist networks of the loaded feeds resolved to the feed listing them
+type Layer struct {
+	lock  sync.Mutex               // serializes changes
+	feeds map[string][]geoip.Range // networks by feed name
+	table atomic.Value             // *geoip.Table of all feeds
+}
+
+/***Functions***/
+
+//NewLayer : create layer without any loaded feeds
+func NewLayer() *Layer {
+	l := &Layer{feeds: make(map[string][]geoip.Range)}
+	l.table.Store(geoip.NewTable(nil))
+	return l
+}
+
+//IsURL : check if feed source is a http(s) url rather than a file/directory
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+//Load : read the networks of a feed from a file, every file of a directory or a http(s) url
+// (DefaultClient is used when client is nil), the networks are labelled with the feed name
+func Load(client *http.Client, name, source string) ([]geoip.Range, error) {
+	if IsURL(source) {
+		if client == nil {
+			client = DefaultClient
+		}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: unexpected status: %s", source, resp.Status)
+		}
+		ranges, err := Parse(resp.Body, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", source, err.Error())
+		}
+		return ranges, nil
+	}
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return loadFile(source, name)
+	}
+	// directories are read in name order, hidden files are skipped
+	files, err := ioutil.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []geoip.Range
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		found, err := loadFile(filepath.Join(source, f.Name()), name)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, found...)
+	}
+	return ranges, nil
+}
+
+//loadFile : read the networks of a single feed file
+func loadFile(path, name string) ([]geoip.Range, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ranges, err := Parse(f, name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return ranges, nil
+}
+
+//Parse : read the ipv4 addresses and networks of a plain, cidr or netset (FireHOL/Spamhaus) list
+// one address/network per line, "#" and ";" start comments and trailing columns are ignored
+// (e.g. "192.0.2.0/24 ; SBL123"), ipv6 entries are skipped
+func Parse(r io.Reader, name string) ([]geoip.Range, error) {
+	var ranges []geoip.Range
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexAny(text, "#;"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		rng, ok, err := parseEntry(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		if ok {
+			rng.Value = name
+			ranges = append(ranges, rng)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+//parseEntry : convert address or network into a range (false for ipv6 entries)
+func parseEntry(entry string) (geoip.Range, bool, error) {
+	var rng geoip.Range
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return rng, false, fmt.Errorf("invalid address: %q", entry)
+		}
+		entry += "/32"
+		if ip.To4() == nil {
+			return rng, false, nil
+		}
+	}
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return rng, false, fmt.Errorf("invalid network: %q", entry)
+	}
+	ip4 := network.IP.To4()
+	if ip4 == nil || len(network.Mask) != net.IPv4len {
+		return rng, false, nil
+	}
+	rng.Start = uint32(ip4[0])<<24 | uint32(ip4[1])<<16 | uint32(ip4[2])<<8 | uint32(ip4[3])
+	ones, _ := network.Mask.Size()
+	rng.End = rng.Start | uint32(uint64(1)<<uint(32-ones)-1)
+	return rng, true, nil
+}
+
+/***Methods***/
+
+//(*Layer).Set : replace the networks of a feed
+func (l *Layer) Set(name string, ranges []geoip.Range) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.feeds[name] = ranges
+	l.rebuild()
+}
+
+//(*Layer).Remove : drop the networks of a feed
+func (l *Layer) Remove(name string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.feeds[name]; ok {
+		delete(l.feeds, name)
+		l.rebuild()
+	}
+}
+
+//(*Layer).Names : return the names of the loaded feeds in order
+func (l *Layer) Names() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	names := make([]string, 0, len(l.feeds))
+	for name := range l.feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//(*Layer).rebuild : swap in a table of the networks of all feeds (lock must be held)
+// networks listed by several feeds are attributed to the most specific entry,
+// identical entries to the feed first in name order
+func (l *Layer) rebuild() {
+	names := make([]string, 0, len(l.feeds))
+	for name := range l.feeds {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	var all []geoip.Range
+	for _, name := range names {
+		all = append(all, l.feeds[name]...)
+	}
+	l.table.Store(geoip.NewTable(all))
+}
+
+//(*Layer).Lookup : return the name of the feed listing ip-address ("" if none or without layer)
+func (l *Layer) Lookup(ip string) string {
+	if l == nil {
+		return ""
+	}
+	return l.table.Load().(*geoip.Table).Lookup(net.ParseIP(ip))
+}
@@ -0,0 +1,87 @@
+package feeds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/***Functions***/
+
+//loadLayer : load the feeds of the given sources into a new layer
+func loadLayer(t *testing.T, client *http.Client, sources map[string]string) *Layer {
+	l := NewLayer()
+	for name, source := range sources {
+		ranges, err := Load(client, name, source)
+		if err != nil {
+			t.Fatalf("Unable to load feed %q: %s\n", name, err.Error())
+		}
+		l.Set(name, ranges)
+	}
+	return l
+}
+
+//checkLookups : ensure layer resolves the given addresses to the expected feeds
+func checkLookups(t *testing.T, l *Layer, expected map[string]string) {
+	for ip, feed := range expected {
+		if found := l.Lookup(ip); found != feed {
+			t.Fatalf("Unexpected feed of %s: %q (expected %q)\n", ip, found, feed)
+		}
+	}
+}
+
+/***Unit-Tests***/
+
+func TestLoadFeeds(t *testing.T) {
+	l := loadLayer(t, nil, map[string]string{
+		"plain":   filepath.Join("testdata", "plain.txt"),
+		"drop":    filepath.Join("testdata", "drop.txt"),
+		"firehol": filepath.Join("testdata", "firehol.netset"),
+		"mirror":  filepath.Join("testdata", "mirror"),
+	})
+	// check more specific entries override the networks containing them
+	checkLookups(t, l, map[string]string{
+		"198.51.100.7":  "plain",
+		"198.51.100.8":  "firehol",
+		"198.51.100.9":  "plain",
+		"198.51.100.16": "",
+		"203.0.113.1":   "drop",
+		"203.0.113.70":  "firehol",
+		"192.0.2.1":     "mirror",
+		"192.0.2.20":    "mirror",
+		"192.0.2.200":   "drop",
+		"192.0.2.100":   "",
+		"10.20.30.40":   "firehol",
+		"2001:db8::1":   "",
+		"invalid":       "",
+	})
+	if names := strings.Join(l.Names(), ","); names != "drop,firehol,mirror,plain" {
+		t.Fatalf("Unexpected feeds: %s\n", names)
+	}
+	l.Remove("firehol")
+	checkLookups(t, l, map[string]string{"198.51.100.8": "", "203.0.113.70": "drop", "198.51.100.7": "plain"})
+	for _, bad := range []string{"198.51.100.1\nnope\n", "198.51.100.0/33\n", "198.51.100.300\n"} {
+		if _, err := Parse(strings.NewReader(bad), "bad"); err == nil {
+			t.Fatalf("Able to load invalid feed: %q\n", bad)
+		}
+	}
+	if _, err := Load(nil, "missing", filepath.Join("testdata", "missing.txt")); err == nil {
+		t.Fatalf("Able to load missing feed\n")
+	}
+	var none *Layer
+	if feed := none.Lookup("198.51.100.7"); feed != "" {
+		t.Fatalf("Unexpected feed without layer: %q\n", feed)
+	}
+}
+
+func TestLoadFeedsHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer srv.Close()
+	l := loadLayer(t, srv.Client(), map[string]string{"drop": srv.URL + "/drop.txt"})
+	checkLookups(t, l, map[string]string{"203.0.113.1": "drop", "198.51.100.7": ""})
+	if _, err := Load(srv.Client(), "missing", srv.URL+"/missing.txt"); err == nil {
+		t.Fatalf("Able to load feed of a missing url\n")
+	}
+}
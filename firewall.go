@@ -3,123 +3,609 @@ package goaway2
 //TODO:20 might want to add thread in charge of reporting recently blocked/continous attacks (logger thread) +enhancement
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goaway2/feeds"
+	"goaway2/geoip"
+	"goaway2/nat"
+	"goaway2/profiles"
+	"goaway2/store"
 
 	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
 )
 
 /***Variables***/
 
+//auditPruneTime : interval audit entries past their retention are pruned in
+const auditPruneTime = time.Hour
+
 type Firewall struct {
+	// audit mode: compute verdicts but never drop packets (loaded from the rule options)
+	Audit bool
+	// called for every source a honeypot blacklisted (from the packet handlers, nil to only log them)
+	OnHoneypot func(e HoneypotEvent)
+	// database the firewall was loaded from
+	store *store.Store
 	// rules for firewall
 	rules    []*fwRule
 	defaults *dfaults
+	// outbound rules of prompts answered forever (consulted where the ask default would prompt)
+	answers []*fwRule
+	// zones by the interfaces bound to them
+	ifaces map[string]*fwZone
+	// hostnames of addresses used by hostname rules
+	dns *DNSCache
+	// countries of addresses used by country rules and blacklist entries (nil without a database)
+	geo *geoip.DB
+	// autonomous systems of addresses used by asn rules and blacklist entries (nil without a database)
+	asns *geoip.DB
+	// networks of the blocklist feeds (kept loaded by a FeedUpdater)
+	feeds *feeds.Layer
+	// knock progress of inbound sources and the ports their sequences opened
+	knocks *Knocker
+	// connections answered with a zero-window SYN-ACK by tarpit rules and the blacklist
+	tarpit *Tarpit
+	// ClientHellos/requests of flows decided by sni/host rules (nil without such rules)
+	flows *Flows
+	// resets flows whose data packets were dropped
+	reject *Rejecter
+	// unused inbound ports blacklisting every source touching them
+	honeypots Honeypots
+	// verifies the sources of tcp honeypots by their handshake
+	verifier *honeypotVerifier
+	// gateways honeypots never blacklist
+	gateways *gatewayCache
+	// outbound packets waiting for an answer when the outbound default is ask
+	prompts *Prompter
 	// ip-caches
 	blacklist *RedBlackTree
 	whitelist *RedBlackTree
 	neutlist  *RedBlackTree
+	// last time audit entries past their retention were pruned
+	auditLock   sync.Mutex
+	auditPruned time.Time
+}
+
+//Decision : explanation of how the firewall reached a verdict for a packet
+type Decision struct {
+	Verdict   netfilter.Verdict
+	Reason    string        // blacklist-src/blacklist-dst/whitelist/honeypot/knock/rule/default/answer/ask
+	Entry     string        // blacklist entry (ip-address/country:XX/ASN/feed:name) that matched (blank if cached)
+	Direction string        // direction the packet was evaluated as (inbound/outbound/forward)
+	Default   string        // default policy for the packets direction
+	RuleNum   int           // index of the rule that decided the verdict (-1 if none)
+	Rule      string        // description of the rule that decided the verdict
+	Action    string        // action of the rule that decided the verdict (blank if it followed the default)
+	Audit     bool          // an audit-only rule would have dropped the packet
+	NetZone   string        // zone of the packets interface (blank for the global rule chain)
+	Pending   bool          // sni/host rules were skipped until the ClientHello/request of the flow is read
+	Knock     string        // knock profile guarding the destination port (reason knock)
+	Knocked   string        // knock profile whose sequence the packet completed (or spa profile it authorized)
+	Rejected  string        // reason the spa packet was rejected
+	Tarpit    bool          // the dropped packet is tarpitted (tarpit rule or blacklist action)
+	Honeypot  string        // reason of the blacklist entry the honeypot writes for the source (reason honeypot)
+	Expiry    time.Duration // time the blacklist entry of the honeypot lasts (0 never expires)
+	Trip      bool          // the packet blacklists its source (first packet of unverified honeypots or completed handshake)
+	Challenge bool          // the SYN is answered with a SYN-ACK whose handshake verifies the source (reason honeypot)
 }
 
 /***Functions***/
 
-//NewRedBlackTree : create firewall instance and load firewall rules
-func NewFirewall() *Firewall {
-	return &Firewall{
-		rules:     sqlLoadRules(),
-		defaults:  sqlLoadDefaults(),
+//NewFirewall : create firewall instance and load firewall rules from the given store
+// (the daemon opens the database shared with the cli through store.OpenDefault)
+func NewFirewall(st *store.Store) *Firewall {
+	return newFirewall(st, loadProfiles())
+}
+
+//SimulateFirewall : create firewall instance from the given store (e.g. an uncommitted transaction)
+// without resolving the hostnames of its rules, returning errors instead of exiting on them
+func SimulateFirewall(st *store.Store) (*Firewall, error) {
+	return loadFirewall(st, loadProfiles(), false)
+}
+
+//newFirewall : create firewall instance resolving rule profiles using the given profile set
+func newFirewall(st *store.Store, set *profiles.Set) *Firewall {
+	fw, err := loadFirewall(st, set, true)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return fw
+}
+
+//loadFirewall : create firewall instance from the store and resolve the hostnames of its rules if asked to
+func loadFirewall(st *store.Store, set *profiles.Set, resolve bool) (*Firewall, error) {
+	defaults, err := sqlLoadDefaults(st)
+	if err != nil {
+		return nil, err
+	}
+	fw := &Firewall{
+		Audit:     defaults.audit,
+		store:     st,
+		defaults:  defaults,
 		neutlist:  NewRedBlackTree(),
 		blacklist: NewRedBlackTree(),
-		whitelist: NewRedBlackTree(),
+		dns:       NewDNSCache(),
+		geo:       loadGeoIP(geoip.Countries, geoip.CountryFiles),
+		asns:      loadGeoIP(geoip.ASNs, geoip.ASNFiles),
+		feeds:     feeds.NewLayer(),
+	}
+	if fw.knocks, err = sqlLoadKnocks(st); err != nil {
+		return nil, err
+	}
+	if fw.honeypots, err = sqlLoadHoneypots(st); err != nil {
+		return nil, err
+	}
+	if fw.whitelist, err = sqlLoadWhitelist(st); err != nil {
+		return nil, err
+	}
+	fw.tarpit = NewTarpit(fw.defaults.tarpitFlows)
+	fw.verifier, fw.gateways = newHoneypotVerifier(), &gatewayCache{path: honeypotRoutes}
+	rules, err := sqlLoadRules(st, set, fw.dns, fw.geo, fw.asns)
+	if err != nil {
+		return nil, err
+	}
+	if resolve {
+		resolveHosts(rules, fw.dns)
 	}
+	if fw.rules, fw.ifaces, err = sqlLoadZones(st, rules); err != nil {
+		return nil, err
+	}
+	if fw.answers, err = sqlLoadAnswers(st, fw.dns, fw.geo, fw.asns); err != nil {
+		return nil, err
+	}
+	if fw.needsPayload() {
+		fw.flows = NewFlows()
+	}
+	fw.reject = &Rejecter{}
+	askVerdict, _ := parseVerdict(fw.defaults.askVerdict)
+	fw.prompts = NewPrompter(fw.defaults.askTimeout, askVerdict)
+	fw.prompts.Remember = fw.rememberPrompt
+	return fw, nil
+}
+
+//AcceptPackets : packet handler accepting every packet, used by queues that only feed the dns cache
+func AcceptPackets(l *log.Logger, kv *RBKV, pkt *PacketData) netfilter.Verdict {
+	return netfilter.NF_ACCEPT
 }
 
 /***Methods***/
 
+//(*Firewall).DNS : return the dns cache hostname rules are matched with (feed it via NetFilterQueue.DNS)
+func (fw *Firewall) DNS() *DNSCache {
+	return fw.dns
+}
+
+//(*Firewall).GeoIP : return the country database country rules are matched with (nil without one)
+func (fw *Firewall) GeoIP() *geoip.DB {
+	return fw.geo
+}
+
+//(*Firewall).ASN : return the asn database asn rules are matched with (nil without one)
+func (fw *Firewall) ASN() *geoip.DB {
+	return fw.asns
+}
+
+//(*Firewall).Feeds : return the layer of blocklist feeds packets are checked against (keep it loaded via FeedUpdater)
+func (fw *Firewall) Feeds() *feeds.Layer {
+	return fw.feeds
+}
+
+//(*Firewall).Prompter : return the prompter holding packets of the ask default (serve it via HandleControl)
+func (fw *Firewall) Prompter() *Prompter {
+	return fw.prompts
+}
+
+//(*Firewall).needsPayload : check if any rule (of any zone) is decided by the application data of a flow
+func (fw *Firewall) needsPayload() bool {
+	chains := [][]*fwRule{fw.rules}
+	for _, z := range fw.ifaces {
+		chains = append(chains, z.rules)
+	}
+	for _, rules := range chains {
+		for _, rule := range rules {
+			if rule.needsPayload() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//(*Firewall).InstallNAT : install the port forwards and masquerades of the store using the given backend
+// call it once when the daemon starts since the kernel forgets the nat table on reboot
+func (fw *Firewall) InstallNAT(b nat.Backend) error {
+	return nat.Apply(fw.store, b)
+}
+
+//(*Firewall).rememberPrompt : write answer deciding the connections of a prompt answered forever
+// (answers are kept apart from the rule chain and its history, see 'goaway prompt answers')
+func (fw *Firewall) rememberPrompt(p Prompt, a PromptAnswer) error {
+	rule := store.Rule{
+		Zone:     "outbound",
+		FromIP:   "any",
+		FromPort: "any",
+		ToIP:     p.DstIP,
+		ToPort:   strconv.FormatInt(p.DstPort, 10),
+		Action:   a.Verdict,
+	}
+	switch {
+	case p.Exe != "":
+		rule.Exe = p.Exe
+	case p.UID >= 0:
+		rule.UID = strconv.FormatInt(p.UID, 10)
+	}
+	return fw.store.AddAnswer(rule)
+}
+
 //(*Firewall).HandlePackets : packet hander used to block/allow packets based on rules
 func (fw *Firewall) HandlePackets(l *log.Logger, kv *RBKV, pkt *PacketData) netfilter.Verdict {
+	d := fw.Decide(kv, pkt)
+	// hold packets no rule decided until the prompt is answered
+	if d.Reason == "ask" {
+		d.Verdict = fw.prompts.Ask(pkt, time.Now())
+	}
+	// answer tarpitted connections instead of dropping them silently
+	if d.Tarpit && !fw.Audit {
+		if _, err := fw.tarpit.Trap(pkt, time.Now()); err != nil {
+			l.Printf("Unable to tarpit %s:%d! Tarpit-Error: %s\n", pkt.SrcIP, pkt.SrcPort, err.Error())
+		}
+	}
+	// reset flows whose data was dropped, later packets of the flow are no longer queued
+	if d.Verdict == netfilter.NF_DROP && !fw.Audit {
+		if _, err := fw.reject.Reject(pkt); err != nil {
+			l.Printf("Unable to reset %s:%d -> %s:%d! Reject-Error: %s\n", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort, err.Error())
+		}
+	}
 	switch {
-	// if src-ip is in blacklist cache
-	case fw.blacklist.Exists(kv, pkt.SrcIP):
+	// if in audit mode record would-be drops and allow the packet
+	case fw.Audit && d.Verdict == netfilter.NF_DROP:
+		fw.recordAudit(l, pkt, &d)
+		return netfilter.NF_ACCEPT
+	// if an audit-only rule would have dropped the packet
+	case d.Audit:
+		fw.recordAudit(l, pkt, &d)
+	case d.Reason == "honeypot" && d.Trip:
+		fw.tripHoneypot(l, kv, pkt, &d)
+	case d.Challenge:
+		if err := fw.verifier.Challenge(pkt); err != nil {
+			l.Printf("Unable to verify honeypot source %s! Honeypot-Error: %s\n", pkt.SrcIP, err.Error())
+		}
+	case d.Knocked != "":
+		l.Printf("Knock: %s completed the sequence/authorization of %q\n", pkt.SrcIP, d.Knocked)
+	case d.Rejected != "":
+		l.Printf("Knock: rejected spa packet of %s for %q: %s\n", pkt.SrcIP, d.Knock, d.Rejected)
+	case d.Reason == "blacklist-src":
 		l.Printf("Fast Block SRC: %s\n", pkt.SrcIP)
-		return netfilter.NF_DROP
+	case d.Reason == "blacklist-dst":
+		l.Printf("Fast Block DST: %s\n", pkt.DstIP)
+	}
+	return d.Verdict
+}
+
+//(*Firewall).HandleFlows : packet handler of the data packets flows send after their handshake (see iptables.sh)
+func (fw *Firewall) HandleFlows(l *log.Logger, kv *RBKV, pkt *PacketData) netfilter.Verdict {
+	d := fw.DecideFlow(pkt)
+	// reset flows whose data was dropped, later packets of the flow are no longer queued
+	if d.Verdict == netfilter.NF_DROP && !fw.Audit {
+		if _, err := fw.reject.Reject(pkt); err != nil {
+			l.Printf("Unable to reset %s:%d -> %s:%d! Reject-Error: %s\n", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort, err.Error())
+		}
+	}
+	switch {
+	// if in audit mode record would-be drops and allow the packet
+	case fw.Audit && d.Verdict == netfilter.NF_DROP:
+		fw.recordAudit(l, pkt, &d)
+		return netfilter.NF_ACCEPT
+	// if an audit-only rule would have dropped the packet
+	case d.Audit:
+		fw.recordAudit(l, pkt, &d)
+	}
+	return d.Verdict
+}
+
+//(*Firewall).tripHoneypot : blacklist the source that touched a honeypot port and emit the event
+func (fw *Firewall) tripHoneypot(l *log.Logger, kv *RBKV, pkt *PacketData, d *Decision) {
+	e := HoneypotEvent{Time: time.Now(), SrcIP: pkt.SrcIP, Port: pkt.DstPort, Protocol: strings.ToLower(pkt.Protocol), Reason: d.Honeypot}
+	entry := store.Entry{IPAddress: pkt.SrcIP, Reason: e.Reason}
+	if d.Expiry > 0 {
+		e.Expires = e.Time.Add(d.Expiry).Truncate(time.Second)
+		entry.Expires = e.Expires.UTC().Format(store.DateLayout)
+	}
+	// packets of the source queued before it was cached are not blacklisted twice
+	added, err := fw.store.AddHoneypotEntry(entry)
+	if err != nil {
+		l.Printf("Unable to blacklist honeypot source %s! SQL-Error: %s\n", pkt.SrcIP, err.Error())
+	}
+	fw.cacheBlacklist(kv, pkt.SrcIP, e.Expires)
+	if !added {
+		return
+	}
+	l.Printf("Honeypot: %s\n", e)
+	if fw.OnHoneypot != nil {
+		fw.OnHoneypot(e)
+	}
+}
+
+//(*Firewall).cacheBlacklist : add ip-address to the blacklist cache until its entry expires (zero never expires)
+func (fw *Firewall) cacheBlacklist(kv *RBKV, ip string, expires time.Time) {
+	value := ""
+	if !expires.IsZero() {
+		value = strconv.FormatInt(expires.Unix(), 10)
+	}
+	fw.blacklist.Set(kv, ip, value)
+}
+
+//(*Firewall).blacklisted : check if ip-address is within the blacklist cache and its entry has not expired
+func (fw *Firewall) blacklisted(kv *RBKV, ip string) bool {
+	if !fw.blacklist.Exists(kv, ip) {
+		return false
+	}
+	if expires, _ := fw.blacklist.Get(kv, ip); expires != "" {
+		if unix, _ := strconv.ParseInt(expires, 10, 64); time.Now().Unix() >= unix {
+			fw.blacklist.Delete(kv, ip)
+			return false
+		}
+	}
+	return true
+}
+
+//(*Firewall).recordAudit : log and store packet that would have been dropped
+func (fw *Firewall) recordAudit(l *log.Logger, pkt *PacketData, d *Decision) {
+	l.Printf(
+		"AUDIT: would drop %s %s:%d -> %s:%d (%s %s)\n",
+		pkt.Protocol, pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort, d.Reason, d.Rule,
+	)
+	if err := sqlRecordAudit(fw.store, pkt, d); err != nil {
+		l.Printf("Unable to record audit entry! SQL-Error: %s\n", err.Error())
+	}
+	fw.pruneAudit(l, time.Now())
+}
+
+//(*Firewall).pruneAudit : remove audit entries past their retention at most once per auditPruneTime
+func (fw *Firewall) pruneAudit(l *log.Logger, now time.Time) {
+	if fw.defaults.auditRetention <= 0 {
+		return
+	}
+	fw.auditLock.Lock()
+	if now.Sub(fw.auditPruned) < auditPruneTime {
+		fw.auditLock.Unlock()
+		return
+	}
+	fw.auditPruned = now
+	fw.auditLock.Unlock()
+	if _, err := fw.store.PruneAuditLog(now.Add(-fw.defaults.auditRetention)); err != nil {
+		l.Printf("Unable to prune audit entries! SQL-Error: %s\n", err.Error())
+	}
+}
+
+//(*Firewall).Decide : evaluate packet against lists and rules and explain the resulting verdict
+func (fw *Firewall) Decide(kv *RBKV, pkt *PacketData) (d Decision) {
+	d.RuleNum = -1
+	// read the server name/host of ClientHellos/requests split across several data packets
+	fw.flows.Observe(pkt, time.Now())
+	switch {
+	// if src-ip is in blacklist cache
+	case fw.blacklisted(kv, pkt.SrcIP):
+		d.Verdict, d.Reason, d.Tarpit = netfilter.NF_DROP, "blacklist-src", fw.defaults.blacklistAction == "tarpit"
 	// if dst-ip is in blacklist cache
-	case fw.blacklist.Exists(kv, pkt.DstIP):
-		l.Printf("Fast Block DST: %s\n", pkt.SrcIP)
-		return netfilter.NF_DROP
+	case fw.blacklisted(kv, pkt.DstIP):
+		d.Verdict, d.Reason = netfilter.NF_DROP, "blacklist-dst"
 	// if src-ip is in whitelist cache
 	case fw.whitelist.Exists(kv, pkt.SrcIP):
-		return netfilter.NF_ACCEPT
-	// if src-ip is in neutral cache
-	case fw.neutlist.Exists(kv, pkt.SrcIP):
-		return fw.checkRules(pkt)
+		d.Verdict, d.Reason = netfilter.NF_ACCEPT, "whitelist"
+	// if src-ip/dst-ip is listed by a blocklist feed (not cached since feeds change on every refresh)
+	case fw.feeds.Lookup(pkt.SrcIP) != "":
+		d.Verdict, d.Reason, d.Entry = netfilter.NF_DROP, "blacklist-src", FeedEntry(fw.feeds.Lookup(pkt.SrcIP))
+		d.Tarpit = fw.defaults.blacklistAction == "tarpit"
+	case fw.feeds.Lookup(pkt.DstIP) != "":
+		d.Verdict, d.Reason, d.Entry = netfilter.NF_DROP, "blacklist-dst", FeedEntry(fw.feeds.Lookup(pkt.DstIP))
+	// if the inbound packet touched a honeypot port (its source gets blacklisted by HandlePackets once verified)
+	case fw.touchedHoneypot(kv, pkt, &d):
+		d.Verdict, d.Reason = netfilter.NF_DROP, "honeypot"
+		d.Tarpit = !d.Challenge && fw.defaults.blacklistAction == "tarpit"
+	// if src-ip and dst-ip are in neutral cache (a new destination may be blacklisted by its country/asn)
+	case fw.neutlist.Exists(kv, pkt.SrcIP) && fw.neutlist.Exists(kv, pkt.DstIP):
+		fw.decideRules(pkt, &d)
 	// if src-ip is not in a cache
 	default:
-		var blocked string
-		db.QueryRow("SELECT IPAddress FROM blacklist WHERE LogicalDelete=0 AND (IPAddress=? OR IPAddress=?)", pkt.SrcIP, pkt.DstIP).Scan(&blocked)
-		switch blocked {
-		case pkt.SrcIP:
-			// if source ip is blacklisted
-			fw.blacklist.Set(kv, pkt.SrcIP, "")
-			return netfilter.NF_DROP
-		case pkt.DstIP:
-			// if destination ip is blacklisted
-			fw.blacklist.Set(kv, pkt.DstIP, "")
-			return netfilter.NF_DROP
+		srcCountry, dstCountry := CountryEntry(fw.geo.Lookup(pkt.SrcIP)), CountryEntry(fw.geo.Lookup(pkt.DstIP))
+		srcASN, dstASN := fw.asns.Lookup(pkt.SrcIP), fw.asns.Lookup(pkt.DstIP)
+		blocked, expires, _ := fw.store.BlacklistedUntil(pkt.SrcIP, pkt.DstIP, srcCountry, dstCountry, srcASN, dstASN)
+		switch {
+		case blocked == pkt.SrcIP || (blocked != "" && (blocked == srcCountry || blocked == srcASN)):
+			// if source ip (or its country/asn) is blacklisted
+			fw.cacheBlacklist(kv, pkt.SrcIP, expires)
+			d.Verdict, d.Reason, d.Entry = netfilter.NF_DROP, "blacklist-src", blocked
+			d.Tarpit = fw.defaults.blacklistAction == "tarpit"
+		case blocked != "":
+			// if destination ip (or its country/asn) is blacklisted
+			fw.cacheBlacklist(kv, pkt.DstIP, expires)
+			d.Verdict, d.Reason, d.Entry = netfilter.NF_DROP, "blacklist-dst", blocked
 		default:
 			// else put them in the neutral cache and evaluate the rules
 			fw.neutlist.Set(kv, pkt.SrcIP, "")
 			fw.neutlist.Set(kv, pkt.DstIP, "")
-			return fw.checkRules(pkt)
+			fw.decideRules(pkt, &d)
+		}
+	}
+	return d
+}
+
+//(*Firewall).DecideFlow : explain the verdict of a data packet sent after the handshake of its flow
+// only sni/host rules are evaluated, blacklists, knocks, honeypots and prompts decided the handshake
+func (fw *Firewall) DecideFlow(pkt *PacketData) (d Decision) {
+	d.RuleNum = -1
+	// read the server name/host of ClientHellos/requests split across several data packets
+	fw.flows.Observe(pkt, time.Now())
+	fw.matchRules(pkt, &d, true)
+	return d
+}
+
+//(*Firewall).touchedHoneypot : check if the inbound packet touched a honeypot port and explain it
+// (tcp honeypots trip on the ACK completing the handshake the SYN was challenged with, unless unverified)
+func (fw *Firewall) touchedHoneypot(kv *RBKV, pkt *PacketData, d *Decision) bool {
+	port, hp, ok := fw.honeypots.Lookup(pkt)
+	if !ok || fw.protectedSource(kv, pkt.SrcIP) {
+		return false
+	}
+	d.Direction, d.Honeypot, d.Expiry = "inbound", HoneypotReason(port.Port), hp.Expiry
+	switch {
+	case hp.Unverified:
+		d.Trip = true
+	case port.Protocol != "tcp":
+	case pkt.Syn:
+		d.Challenge = true
+	default:
+		d.Trip = fw.verifier.Verified(pkt)
+	}
+	return true
+}
+
+//(*Firewall).protectedSource : check if ip-address must never be blacklisted by a honeypot (local
+// addresses, gateways and whitelist entries added after the whitelist cache was loaded)
+func (fw *Firewall) protectedSource(kv *RBKV, ip string) bool {
+	if _, ok := localAddrs()[ip]; ok || fw.gateways.Contains(ip, time.Now()) {
+		return true
+	}
+	if whitelisted, _ := fw.store.HasEntry(store.Whitelist, ip); whitelisted {
+		fw.whitelist.Set(kv, ip, "")
+		return true
+	}
+	return false
+}
+
+//(*Firewall).decideRules : decide inbound packets to ports guarded by a knock profile by whether
+// their source knocked the sequence and every other packet by the rules
+func (fw *Firewall) decideRules(pkt *PacketData, d *Decision) {
+	if pkt.IsInbound() {
+		k := fw.knocks.Observe(pkt, time.Now())
+		d.Knocked, d.Rejected = k.Completed, k.Rejected
+		if k.Guard != "" {
+			d.Direction, d.Reason, d.Knock = "inbound", "knock", k.Guard
+			d.Verdict = netfilter.NF_DROP
+			if k.Open {
+				d.Verdict = netfilter.NF_ACCEPT
+			}
+			return
 		}
 	}
+	fw.matchRules(pkt, d, false)
 }
 
 //(*Firewall).checkRules : return verdict based on if packet is following given rules
 func (fw *Firewall) checkRules(pkt *PacketData) netfilter.Verdict {
+	var d Decision
+	fw.matchRules(pkt, &d, false)
+	return d.Verdict
+}
+
+//(*Firewall).matchRules : set verdict and deciding rule based on if packet is following given rules
+// (flow packets are data packets sent after the handshake of their flow, see DecideFlow)
+func (fw *Firewall) matchRules(pkt *PacketData, d *Decision, flow bool) {
+	// collect rules and defaults of the zone the packets interface is bound to
+	// (zones only guard the host itself, forwarded packets use the global rule chain)
+	d.Direction = pkt.Direction()
+	rules, defaults := fw.rules, fw.defaults
+	if z, ok := fw.ifaces[pkt.Iface(d.Direction)]; ok && d.Direction != "forward" {
+		rules, defaults, d.NetZone = z.rules, z.defaults, z.name
+	}
+	// collect default for the packets direction
+	d.Default = defaults.direction(d.Direction)
 	// iterate all rules until either denied or all rules pass
-	for _, rule := range fw.rules {
-		switch pkt.IsInbound() {
-		// if packet is inbound
-		case true:
-			switch fw.defaults.inbound {
-			// if inbound's default is to allow
-			case "allow":
-				// if the rule matches: drop
-				if rule.Validate(pkt) {
-					return netfilter.NF_DROP
-				} else {
-					continue
-				}
-			// if inbound's default is to deny
-			default:
-				// if rule matches: accept
-				if rule.Validate(pkt) {
-					continue
-				} else {
-					return netfilter.NF_DROP
-				}
+	var drop bool
+	for _, rule := range rules {
+		// the handshake of a flow passed every other rule, unless an allow rule accepted the whole flow
+		if flow && !rule.needsPayload() {
+			if rule.Action == "allow" && rule.Validate(pkt) {
+				d.Verdict, d.Reason, d.RuleNum, d.Rule, d.Action = netfilter.NF_ACCEPT, "rule", rule.raw.RuleNum, rule.String(), rule.Action
+				return
 			}
-		// if packet is outbound
-		default:
-			switch fw.defaults.outbound {
-			// if outbounds default is to allow
-			case "allow":
-				// if rule matches: deny
-				if rule.Validate(pkt) {
-					return netfilter.NF_DROP
-				} else {
-					continue
-				}
-			// if outbounds default is to deny
+			continue
+		}
+		// sni/host rules are decided once the ClientHello/request of the flow is read, the handshake
+		// and partial ClientHellos/requests are let through until then
+		if rule.needsPayload() && (!pkt.Payload || pkt.Partial) {
+			d.Pending = true
+			continue
+		}
+		// unreadable ClientHellos/requests are dropped by every sni/host rule that might decide the flow
+		if rule.needsPayload() && pkt.Invalid && rule.validateFlow(pkt) {
+			d.Verdict, d.Reason, d.RuleNum, d.Rule, d.Action = netfilter.NF_DROP, "payload", rule.raw.RuleNum, rule.String(), rule.Action
+			d.Audit = false
+			return
+		}
+		// rules with an action decide matching packets regardless of the default
+		if rule.Action != "" {
+			if !rule.Validate(pkt) {
+				continue
+			}
+			if rule.Action == "allow" {
+				d.Verdict, d.Reason, d.RuleNum, d.Rule, d.Action = netfilter.NF_ACCEPT, "rule", rule.raw.RuleNum, rule.String(), rule.Action
+				return
+			}
+			drop = true
+		} else {
+			switch d.Default {
+			// if default is to allow (or ask): drop when the rule matches
+			case "allow", "ask":
+				drop = rule.Validate(pkt)
+			// if default is to deny: drop when the rule does not match
 			default:
-				// if rule matches: accept
-				if rule.Validate(pkt) {
-					continue
-				} else {
-					return netfilter.NF_DROP
-				}
+				drop = !rule.Validate(pkt)
+			}
+		}
+		if !drop {
+			continue
+		}
+		// audit-only rules are recorded and then skipped
+		if rule.Audit {
+			if !d.Audit {
+				d.Audit, d.Reason, d.RuleNum, d.Rule, d.Action = true, "rule", rule.raw.RuleNum, rule.String(), rule.Action
 			}
+			continue
 		}
+		d.Verdict, d.Reason, d.RuleNum, d.Rule, d.Audit = netfilter.NF_DROP, "rule", rule.raw.RuleNum, rule.String(), false
+		d.Action, d.Tarpit = rule.Action, rule.Action == "tarpit"
+		return
+	}
+	// the handshake of the flow was decided (or prompted for) already
+	if flow {
+		d.Verdict = netfilter.NF_ACCEPT
+		if !d.Audit {
+			d.Reason = "flow"
+		}
+		return
+	}
+	// without a rule deciding the packet earlier prompt answers decide it
+	if d.Default == "ask" {
+		for _, answer := range fw.answers {
+			if !answer.Validate(pkt) {
+				continue
+			}
+			d.Verdict, d.Reason, d.RuleNum, d.Rule, d.Action = netfilter.NF_DROP, "answer", answer.raw.RuleNum, answer.String(), answer.Action
+			if answer.Action == "allow" {
+				d.Verdict = netfilter.NF_ACCEPT
+			}
+			return
+		}
+	}
+	// without an answer the packet gets the verdict of unanswered prompts
+	if d.Default == "ask" {
+		d.Verdict, d.Reason = netfilter.NF_DROP, "ask"
+		if defaults.askVerdict == "allow" {
+			d.Verdict = netfilter.NF_ACCEPT
+		}
+		return
+	}
+	d.Verdict = netfilter.NF_ACCEPT
+	if !d.Audit {
+		d.Reason = "default"
 	}
-	return netfilter.NF_ACCEPT
 }
@@ -2,13 +2,79 @@ package goaway2
 
 import (
 	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"goaway2/geoip"
+	"goaway2/profiles"
+	"goaway2/store"
 
 	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
 )
 
+/***Functions***/
+
+//firewallFixture : configuration of the in-memory store a test firewall is loaded from
+type firewallFixture struct {
+	options  store.Options
+	rules    []store.Rule
+	profiles *profiles.Set         // profiles the rules refer to (nil for none)
+	setup    func(st *store.Store) // further configuration applied before the rules (nil for none)
+}
+
+//natRecorder : nat backend remembering the configuration it installed
+type natRecorder struct {
+	installed []store.NAT
+}
+
+//(*natRecorder).Install : remember the installed configuration
+func (r *natRecorder) Install(n store.NAT) error {
+	r.installed = append(r.installed, n)
+	return nil
+}
+
+//testCheck : fail the test on the first of the given errors
+func testCheck(t *testing.T, errs ...error) {
+	t.Helper()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Unable to configure store: %s\n", err.Error())
+		}
+	}
+}
+
+//newTestFirewall : open an in-memory store configured by the fixture and load a firewall from it (the caller closes the store)
+func newTestFirewall(t *testing.T, f firewallFixture) (*Firewall, *store.Store) {
+	t.Helper()
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	testCheck(t, st.SetOptions(f.options))
+	if f.setup != nil {
+		f.setup(st)
+	}
+	for _, r := range f.rules {
+		testCheck(t, st.AppendRule(r))
+	}
+	if f.profiles == nil {
+		f.profiles = profiles.NewSet()
+	}
+	return newFirewall(st, f.profiles), st
+}
+
+/***Unit-Tests***/
+
 func TestFirewallHandler(t *testing.T) {
-	fw := NewFirewall()
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	fw := NewFirewall(st)
 	// check if outbound dns packet is dropped
 	if fw.checkRules(&PacketData{
 		SrcIP:   "192.168.200.114",
@@ -28,3 +94,480 @@ func TestFirewallHandler(t *testing.T) {
 		fmt.Println("Packet #2 Dropped")
 	}
 }
+
+func TestFirewallDecide(t *testing.T) {
+	fw := &Firewall{
+		rules:     []*fwRule{exampleRule},
+		defaults:  &dfaults{inbound: "allow", outbound: "allow"},
+		neutlist:  NewRedBlackTree(),
+		blacklist: NewRedBlackTree(),
+		whitelist: NewRedBlackTree(),
+	}
+	kv := NewRedBlackKV()
+	fw.neutlist.Set(kv, examplePktData.SrcIP, "")
+	fw.neutlist.Set(kv, examplePktData.DstIP, "")
+	// check that matching rule is reported as the reason for the drop
+	d := fw.Decide(kv, examplePktData)
+	if d.Verdict != netfilter.NF_DROP || d.Reason != "rule" || d.RuleNum != 0 {
+		t.Fatalf("Unexpected decision for rule match: %+v\n", d)
+	}
+	// check that whitelisted source is accepted
+	fw.whitelist.Set(kv, examplePktData.SrcIP, "")
+	if d = fw.Decide(kv, examplePktData); d.Reason != "whitelist" || d.Verdict != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected decision for whitelist: %+v\n", d)
+	}
+}
+
+func TestFirewallFreshDefaults(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	fw := newFirewall(st, profiles.NewSet())
+	// check a fresh database lets outbound connections through like the shipped database
+	pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Syn: true, Hook: HookOutput}
+	if d := fw.Decide(NewRedBlackKV(), pkt); d.Verdict != netfilter.NF_ACCEPT || d.Default != "allow" {
+		t.Fatalf("Unexpected decision of outbound syn: %+v\n", d)
+	}
+}
+
+func TestFirewallAuditRule(t *testing.T) {
+	audited := *exampleRule
+	audited.Audit = true
+	fw := &Firewall{
+		rules:     []*fwRule{&audited},
+		defaults:  &dfaults{inbound: "allow", outbound: "allow"},
+		neutlist:  NewRedBlackTree(),
+		blacklist: NewRedBlackTree(),
+		whitelist: NewRedBlackTree(),
+	}
+	kv := NewRedBlackKV()
+	fw.neutlist.Set(kv, examplePktData.SrcIP, "")
+	fw.neutlist.Set(kv, examplePktData.DstIP, "")
+	// check that audit-only rule is recorded but does not drop the packet
+	d := fw.Decide(kv, examplePktData)
+	if d.Verdict != netfilter.NF_ACCEPT || !d.Audit || d.RuleNum != 0 {
+		t.Fatalf("Unexpected decision for audited rule: %+v\n", d)
+	}
+}
+
+func TestFirewallProfileRule(t *testing.T) {
+	set, err := profiles.Load(filepath.Join("profiles", "testdata", "services"), filepath.Join("profiles", "testdata", "applications.d"))
+	if err != nil {
+		t.Fatalf("Unable to load profiles: %s\n", err.Error())
+	}
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow"},
+		rules: []store.Rule{
+			{Zone: "any", FromIP: "any", FromPort: "any", ToIP: "8.8.8.8", ToPort: "any", Profile: profiles.ServiceRef("domain")},
+		},
+		profiles: set,
+	})
+	defer st.Close()
+	if len(fw.rules) != 1 {
+		t.Fatalf("Unexpected rules: %v\n", fw.rules)
+	}
+	// check rules with a missing profile refuse the load instead of being skipped
+	testCheck(t, st.AppendRule(store.Rule{Zone: "any", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "any", Profile: profiles.AppRef("Missing")}))
+	if _, err = loadFirewall(st, set, false); err == nil {
+		t.Fatalf("Able to load rule with a missing profile\n")
+	}
+	// check profile ports and protocols are matched
+	for _, check := range []struct {
+		proto string
+		port  int64
+		drop  bool
+	}{
+		{"UDP", 53, true},
+		{"TCP", 53, true},
+		{"ICMP", 53, false},
+		{"UDP", 853, false},
+	} {
+		pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 10048, DstIP: "8.8.8.8", DstPort: check.port, Protocol: check.proto}
+		if drop := fw.checkRules(pkt) == netfilter.NF_DROP; drop != check.drop {
+			t.Fatalf("Unexpected verdict for %s/%d: drop=%t\n", check.proto, check.port, drop)
+		}
+	}
+}
+
+func TestFirewallNetZone(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow"},
+		rules: []store.Rule{
+			{Zone: "any", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22"},
+			{Zone: "any", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "80", NetZone: "dmz"},
+		},
+		setup: func(st *store.Store) {
+			testCheck(t,
+				st.AddNetZone("dmz", "allow", "allow"),
+				st.BindInterface("eth1", "dmz"),
+			)
+		},
+	})
+	defer st.Close()
+	kv := NewRedBlackKV()
+	for _, check := range []struct {
+		iface   string
+		port    int64
+		drop    bool
+		netzone string
+	}{
+		{"eth0", 22, true, ""},
+		{"eth0", 80, false, ""},
+		{"eth1", 22, false, "dmz"},
+		{"eth1", 80, true, "dmz"},
+	} {
+		pkt := &PacketData{SrcIP: "203.0.113.1", SrcPort: 40000, DstIP: "198.51.100.1", DstPort: check.port, Protocol: "TCP", InIface: check.iface}
+		d := fw.Decide(kv, pkt)
+		if (d.Verdict == netfilter.NF_DROP) != check.drop || d.NetZone != check.netzone {
+			t.Fatalf("Unexpected decision for %s/%d: %+v\n", check.iface, check.port, d)
+		}
+	}
+}
+
+func TestFirewallHook(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"},
+		rules: []store.Rule{
+			{Zone: "forward", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "445"},
+		},
+	})
+	defer st.Close()
+	kv := NewRedBlackKV()
+	// check direction is taken from the hook regardless of local addresses
+	for _, check := range []struct {
+		hook      string
+		drop      bool
+		direction string
+	}{
+		{HookForward, true, "forward"},
+		{HookInput, false, "inbound"},
+		{HookOutput, false, "outbound"},
+		{"", false, "inbound"},
+	} {
+		pkt := &PacketData{SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.1.2", DstPort: 445, Protocol: "TCP", Hook: check.hook}
+		d := fw.Decide(kv, pkt)
+		if (d.Verdict == netfilter.NF_DROP) != check.drop || d.Direction != check.direction {
+			t.Fatalf("Unexpected decision for hook %q: %+v\n", check.hook, d)
+		}
+	}
+}
+
+func TestFirewallForward(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "deny"},
+		rules: []store.Rule{
+			{Zone: "forward", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "any", InIface: "eth1", OutIface: "eth0"},
+		},
+		setup: func(st *store.Store) {
+			testCheck(t,
+				st.AddNetZone("lan", "allow", "allow"),
+				st.BindInterface("eth1", "lan"),
+			)
+		},
+	})
+	defer st.Close()
+	kv := NewRedBlackKV()
+	// check forwarding is only allowed from the lan to the wan interface
+	for _, check := range []struct {
+		in, out string
+		drop    bool
+	}{
+		{"eth1", "eth0", false},
+		{"eth0", "eth1", true},
+		{"eth1", "eth2", true},
+	} {
+		pkt := &PacketData{SrcIP: "10.0.1.2", SrcPort: 40000, DstIP: "203.0.113.1", DstPort: 443, Protocol: "TCP", InIface: check.in, OutIface: check.out, Hook: HookForward}
+		d := fw.Decide(kv, pkt)
+		if (d.Verdict == netfilter.NF_DROP) != check.drop || d.Default != "deny" || d.NetZone != "" {
+			t.Fatalf("Unexpected decision for %s>%s: %+v\n", check.in, check.out, d)
+		}
+	}
+}
+
+func TestFirewallSNIRule(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"},
+		rules: []store.Rule{
+			{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "443", SNI: "*.example.com"},
+		},
+	})
+	defer st.Close()
+	kv := NewRedBlackKV()
+	pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "203.0.113.9", DstPort: 443, Protocol: "TCP", Hook: HookOutput}
+	// check the handshake is held back until the first data packet
+	if d := fw.Decide(kv, pkt); d.Verdict != netfilter.NF_ACCEPT || !d.Pending {
+		t.Fatalf("Unexpected decision before data: %+v\n", d)
+	}
+	for n, check := range []struct {
+		sni  string
+		drop bool
+	}{
+		{"cdn.example.com", true},
+		{"example.org", false},
+		{"", false},
+	} {
+		data := *pkt
+		data.SrcPort += int64(n)
+		parsePayload([]byte("\x00"), &data)
+		if check.sni != "" {
+			parsePayload(clientHello(t, check.sni), &data)
+		}
+		if d := fw.DecideFlow(&data); (d.Verdict == netfilter.NF_DROP) != check.drop || d.Pending {
+			t.Fatalf("Unexpected decision for %q: %+v\n", check.sni, d)
+		}
+	}
+	// check split ClientHellos are let through until the packet completing them decides the flow
+	l, sender := log.New(ioutil.Discard, "", 0), &fakeSender{}
+	fw.reject.Sender = sender
+	hello := clientHello(t, "cdn.example.com")
+	for n, cut := range [][2]int{{0, 100}, {100, len(hello)}} {
+		d := fw.DecideFlow(flowPacket(40100, 1000+uint32(cut[0]), hello[cut[0]:cut[1]]))
+		if last := n == 1; (d.Verdict == netfilter.NF_DROP) != last || d.Pending == last {
+			t.Fatalf("Unexpected decision of packet #%d: %+v\n", n, d)
+		}
+	}
+	// check ClientHellos that can not be reassembled fail closed and their flow is reset
+	for _, cut := range [][2]int{{0, 100}, {150, len(hello)}} {
+		fw.HandleFlows(l, kv, flowPacket(40200, 1000+uint32(cut[0]), hello[cut[0]:cut[1]]))
+	}
+	if d := fw.DecideFlow(flowPacket(40200, 1000+uint32(len(hello)), []byte{0x17})); d.Reason != "payload" || d.Verdict != netfilter.NF_DROP || d.RuleNum != 0 {
+		t.Fatalf("Unexpected decision of invalid ClientHello: %+v\n", d)
+	}
+	if sender.count() != 1 {
+		t.Fatalf("Unexpected resets: %d\n", sender.count())
+	}
+	// check flows to other ports are not decided by the rule
+	other := flowPacket(40300, 1000, hello[150:])
+	other.DstPort = 8443
+	if d := fw.DecideFlow(other); d.Verdict != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected decision of other port: %+v\n", d)
+	}
+}
+
+func TestFirewallFlow(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "ask", Forward: "allow", AskVerdict: "deny"},
+		rules: []store.Rule{
+			{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "198.51.100.7", ToPort: "any", Action: "allow"},
+			{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "443", SNI: "*.example.com", Action: "deny"},
+			{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", Action: "deny"},
+		},
+	})
+	defer st.Close()
+	hello := clientHello(t, "cdn.example.com")
+	for _, check := range []struct {
+		dst     string
+		port    int64
+		verdict netfilter.Verdict
+		reason  string
+	}{
+		// sni/host rules decide the data of flows
+		{"203.0.113.9", 443, netfilter.NF_DROP, "rule"},
+		// allow rules taking precedence accepted the whole flow
+		{"198.51.100.7", 443, netfilter.NF_ACCEPT, "rule"},
+		// other rules and the ask default decided the handshake already
+		{"203.0.113.9", 22, netfilter.NF_ACCEPT, "flow"},
+		{"203.0.113.9", 8443, netfilter.NF_ACCEPT, "flow"},
+	} {
+		pkt := flowPacket(40000, 1000, hello)
+		pkt.DstIP, pkt.DstPort = check.dst, check.port
+		if d := fw.DecideFlow(pkt); d.Verdict != check.verdict || d.Reason != check.reason {
+			t.Fatalf("Unexpected decision of flow to %s:%d: %+v\n", check.dst, check.port, d)
+		}
+	}
+}
+
+func TestFirewallCountries(t *testing.T) {
+	files := geoip.CountryFiles
+	geoip.CountryFiles = []string{filepath.Join("geoip", "testdata", "country.mmdb")}
+	defer func() { geoip.CountryFiles = files }()
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"},
+		rules: []store.Rule{
+			{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", SrcCountry: "US"},
+		},
+		setup: func(st *store.Store) {
+			testCheck(t, st.AddEntry(store.Blacklist, store.Entry{IPAddress: CountryEntry("RU"), Reason: "geo"}))
+		},
+	})
+	defer st.Close()
+	if fw.GeoIP() == nil {
+		t.Fatalf("Country database was not loaded\n")
+	}
+	for _, check := range []struct {
+		src    string
+		dport  int64
+		reason string
+	}{
+		{"8.8.8.8", 22, "rule"},
+		{"1.0.0.1", 22, "default"},
+		{"9.9.9.9", 22, "default"},
+		{"5.255.1.1", 80, "blacklist-src"},
+		{"5.255.1.1", 80, "blacklist-src"}, // cached
+	} {
+		pkt := &PacketData{SrcIP: check.src, SrcPort: 40000, DstIP: "192.168.200.114", DstPort: check.dport, Protocol: "TCP", Hook: HookInput}
+		if d := fw.Decide(NewRedBlackKV(), pkt); d.Reason != check.reason {
+			t.Fatalf("Unexpected decision for %s: %+v\n", check.src, d)
+		}
+	}
+	// check countries of destinations are blacklisted as well
+	pkt := &PacketData{SrcIP: "192.168.200.115", SrcPort: 40000, DstIP: "5.255.2.2", DstPort: 443, Protocol: "TCP", Hook: HookOutput}
+	if d := fw.Decide(NewRedBlackKV(), pkt); d.Reason != "blacklist-dst" || d.Verdict != netfilter.NF_DROP {
+		t.Fatalf("Unexpected decision for blacklisted country: %+v\n", d)
+	}
+}
+
+func TestFirewallASN(t *testing.T) {
+	files := geoip.ASNFiles
+	geoip.ASNFiles = []string{filepath.Join("geoip", "testdata", "pfx2as.txt")}
+	defer func() { geoip.ASNFiles = files }()
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"},
+		rules: []store.Rule{
+			{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "as15169", ToPort: "53"},
+		},
+		setup: func(st *store.Store) {
+			testCheck(t, st.AddEntry(store.Blacklist, store.Entry{IPAddress: "AS64496", Reason: "hosting"}))
+		},
+	})
+	defer st.Close()
+	if fw.ASN() == nil {
+		t.Fatalf("ASN database was not loaded\n")
+	}
+	for _, check := range []struct {
+		dst    string
+		reason string
+		entry  string
+	}{
+		{"8.8.8.8", "rule", ""},
+		{"1.1.1.1", "default", ""},
+		{"5.255.64.1", "blacklist-dst", "AS64496"},
+		{"5.255.1.1", "default", ""},
+	} {
+		pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: check.dst, DstPort: 53, Protocol: "UDP", Hook: HookOutput}
+		if d := fw.Decide(NewRedBlackKV(), pkt); d.Reason != check.reason || d.Entry != check.entry {
+			t.Fatalf("Unexpected decision for %s: %+v\n", check.dst, d)
+		}
+	}
+}
+
+func TestFirewallFeeds(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"},
+		setup: func(st *store.Store) {
+			testCheck(t,
+				st.AddFeed(store.Feed{Name: "drop", Source: filepath.Join("feeds", "testdata", "drop.txt"), Interval: 3600}),
+				st.AddFeed(store.Feed{Name: "broken", Source: filepath.Join("feeds", "testdata", "missing.txt"), Interval: 3600}),
+			)
+		},
+	})
+	defer st.Close()
+	updater := &FeedUpdater{Store: st, Layer: fw.Feeds(), Logger: log.New(ioutil.Discard, "", 0)}
+	now := time.Now()
+	updater.Update(now)
+	for _, check := range []struct {
+		src    string
+		dst    string
+		reason string
+		entry  string
+	}{
+		{"203.0.113.5", "192.168.200.114", "blacklist-src", "feed:drop"},
+		{"192.168.200.114", "192.0.2.200", "blacklist-dst", "feed:drop"},
+		{"192.168.200.114", "192.0.2.1", "default", ""},
+	} {
+		pkt := &PacketData{SrcIP: check.src, SrcPort: 40000, DstIP: check.dst, DstPort: 53, Protocol: "UDP", Hook: HookOutput}
+		if d := fw.Decide(NewRedBlackKV(), pkt); d.Reason != check.reason || d.Entry != check.entry {
+			t.Fatalf("Unexpected decision for %s -> %s: %+v\n", check.src, check.dst, d)
+		}
+	}
+	// check the refresh results are recorded
+	if f, _, _ := st.Feed("drop"); f.Entries != 2 || f.LastRefresh == "" || f.LastError != "" {
+		t.Fatalf("Unexpected status of loaded feed: %+v\n", f)
+	}
+	if f, _, _ := st.Feed("broken"); f.Entries != 0 || f.LastRefresh != "" || f.LastError == "" {
+		t.Fatalf("Unexpected status of broken feed: %+v\n", f)
+	}
+	// check removed feeds are dropped on the next update while a neutral address stays decided by the feed
+	kv := NewRedBlackKV()
+	pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "192.0.2.200", DstPort: 53, Protocol: "UDP", Hook: HookOutput}
+	testCheck(t, st.RemoveFeed("drop"))
+	updater.Update(now.Add(time.Minute))
+	if d := fw.Decide(kv, pkt); d.Reason != "default" {
+		t.Fatalf("Unexpected decision after removing feed: %+v\n", d)
+	}
+	testCheck(t, st.AddFeed(store.Feed{Name: "drop", Source: filepath.Join("feeds", "testdata", "drop.txt"), Interval: 3600}))
+	updater.Update(now.Add(2 * time.Minute))
+	if d := fw.Decide(kv, pkt); d.Reason != "blacklist-dst" || d.Entry != "feed:drop" {
+		t.Fatalf("Unexpected decision after adding feed: %+v\n", d)
+	}
+}
+
+func TestFirewallWhitelist(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "deny", Outbound: "allow", Forward: "allow"},
+		setup: func(st *store.Store) {
+			testCheck(t,
+				st.AddEntry(store.Whitelist, store.Entry{IPAddress: "198.51.100.7", Reason: "admin"}),
+				st.AddEntry(store.Whitelist, store.Entry{IPAddress: "203.0.113.5", Reason: "office"}),
+			)
+		},
+	})
+	defer st.Close()
+	kv := NewRedBlackKV()
+	for _, check := range []struct {
+		src    string
+		reason string
+	}{
+		{"198.51.100.7", "whitelist"},
+		{"203.0.113.5", "whitelist"},
+		{"192.0.2.1", "default"},
+	} {
+		pkt := &PacketData{SrcIP: check.src, SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 22, Protocol: "TCP", Hook: HookInput}
+		if d := fw.Decide(kv, pkt); d.Reason != check.reason {
+			t.Fatalf("Unexpected decision for %s: %+v\n", check.src, d)
+		}
+	}
+}
+
+func TestFirewallAuditMode(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow", Audit: true, AuditRetention: 3600},
+		rules: []store.Rule{
+			{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", Action: "deny"},
+		},
+	})
+	defer st.Close()
+	if !fw.Audit {
+		t.Fatalf("Audit mode of the rule options was not loaded\n")
+	}
+	pkt := &PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 22, Protocol: "TCP", Hook: HookInput}
+	if v := fw.HandlePackets(log.New(ioutil.Discard, "", 0), NewRedBlackKV(), pkt); v != netfilter.NF_ACCEPT {
+		t.Fatalf("Packet was dropped in audit mode: %v\n", v)
+	}
+	if entries, _ := st.AuditLog(10); len(entries) != 1 || entries[0].Reason != "rule" {
+		t.Fatalf("Unexpected audit entries: %+v\n", entries)
+	}
+}
+
+func TestFirewallInstallNAT(t *testing.T) {
+	fw, st := newTestFirewall(t, firewallFixture{
+		options: store.Options{Inbound: "allow", Outbound: "allow", Forward: "deny"},
+		setup: func(st *store.Store) {
+			testCheck(t,
+				st.AddPortForward(store.PortForward{Interface: "eth0", Protocol: "tcp", Port: "8080", ToIP: "10.0.1.5", ToPort: "80"}),
+				st.AddMasquerade(store.Masquerade{Interface: "eth0", Source: "10.0.1.0/24"}),
+			)
+		},
+	})
+	defer st.Close()
+	// check the stored nat configuration is installed when the daemon starts
+	r := &natRecorder{}
+	if err := fw.InstallNAT(r); err != nil {
+		t.Fatalf("Unable to install nat: %s\n", err.Error())
+	}
+	if len(r.installed) != 1 || len(r.installed[0].Forwards) != 1 || r.installed[0].Forwards[0].ToIP != "10.0.1.5" || len(r.installed[0].Masquerades) != 1 {
+		t.Fatalf("Unexpected nat installed: %+v\n", r.installed)
+	}
+}
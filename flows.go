@@ -0,0 +1,115 @@
+package goaway2
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+/***Variables***/
+
+//flow reassembly limits
+const (
+	// data packets a ClientHello/request may span (below the connbytes window of iptables.sh)
+	flowMaxPackets = 16
+	flowMaxBytes   = tlsMaxRecord + 5 // largest tls record including its header
+	flowMaxFlows   = 16384            // flows remembered at once, partial ClientHellos of further flows are invalid
+	flowHold       = 2 * time.Minute  // time a flow is remembered after its last data packet
+	flowSweepTime  = time.Minute      // minimum time between sweeps of expired flows
+)
+
+//flowState : application data read from the first data packets of a tcp flow
+type flowState struct {
+	next    uint32 // sequence number of the next data byte of a partial ClientHello/request
+	data    []byte // partial ClientHello/request
+	packets int    // data packets the ClientHello/request spans so far
+	state   int    // payloadNone/payloadDone/payloadPartial/payloadInvalid
+	sni     string
+	host    string
+	seen    time.Time
+}
+
+//Flows : reassembles the ClientHello/request of tcp flows split across several data packets and
+// remembers the server name/host of a flow for its later data packets, packets that do not continue
+// a partial ClientHello/request in order make it invalid (sni/host rules fail closed)
+type Flows struct {
+	lock  sync.Mutex
+	flows map[string]*flowState
+	swept time.Time
+}
+
+/***Functions***/
+
+//NewFlows : create empty flow table
+func NewFlows() *Flows {
+	return &Flows{flows: make(map[string]*flowState)}
+}
+
+/***Methods***/
+
+//(*Flows).Observe : set the server name/host of the flow on its data packet (Partial while the
+// ClientHello/request is incomplete and Invalid if it can not be reassembled)
+func (f *Flows) Observe(pkt *PacketData, now time.Time) {
+	if f == nil || !pkt.Payload || !strings.EqualFold(pkt.Protocol, "tcp") {
+		return
+	}
+	key := fmt.Sprintf("%s:%d>%s:%d", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if now.Sub(f.swept) >= flowSweepTime {
+		f.swept = now
+		for k, s := range f.flows {
+			if now.Sub(s.seen) >= flowHold {
+				delete(f.flows, k)
+			}
+		}
+	}
+	s, ok := f.flows[key]
+	if !ok && len(f.flows) >= flowMaxFlows {
+		pkt.Invalid, pkt.Partial = pkt.Invalid || pkt.Partial, false
+		return
+	}
+	switch {
+	// the first data packet of the flow was read by parsePayload
+	case !ok:
+		s = &flowState{state: payloadDone, sni: pkt.SNI, host: pkt.Host, packets: 1}
+		switch {
+		case pkt.Invalid:
+			s.state = payloadInvalid
+		case pkt.Partial:
+			s.state, s.next = payloadPartial, pkt.Seq+uint32(len(pkt.Data))
+			s.data = append([]byte(nil), pkt.Data...)
+		}
+		f.flows[key] = s
+	// a flow continuing its partial ClientHello/request in order
+	case s.state == payloadPartial && pkt.Seq == s.next:
+		s.data = append(s.data, pkt.Data...)
+		s.next += uint32(len(pkt.Data))
+		s.packets++
+		switch {
+		case s.packets > flowMaxPackets || len(s.data) > flowMaxBytes:
+			s.state = payloadInvalid
+		default:
+			s.state = readPayload(s.data, pkt)
+			s.sni, s.host = pkt.SNI, pkt.Host
+			if s.state == payloadNone {
+				s.state = payloadDone
+			}
+		}
+		if s.state != payloadPartial {
+			s.data = nil
+		}
+	// retransmissions of data already read keep the flow partial
+	case s.state == payloadPartial && int32(pkt.Seq+uint32(len(pkt.Data))-s.next) <= 0:
+	// gaps and overlaps can not be reassembled
+	case s.state == payloadPartial:
+		s.state, s.data = payloadInvalid, nil
+	// a later request of the flow carries its own host (keep-alive)
+	case s.state == payloadDone && (pkt.SNI != "" || pkt.Host != ""):
+		s.sni, s.host = pkt.SNI, pkt.Host
+	}
+	s.seen = now
+	pkt.SNI, pkt.Host = s.sni, s.host
+	pkt.Partial, pkt.Invalid = s.state == payloadPartial, s.state == payloadInvalid
+}
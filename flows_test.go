@@ -0,0 +1,71 @@
+package goaway2
+
+import (
+	"testing"
+	"time"
+)
+
+/***Functions***/
+
+//flowPacket : build the outbound data packet of the given flow parsed like a queued packet
+func flowPacket(srcPort int64, seq uint32, data []byte) *PacketData {
+	pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: srcPort, DstIP: "203.0.113.9", DstPort: 443, Protocol: "TCP", Hook: HookOutput, Seq: seq}
+	parsePayload(data, pkt)
+	return pkt
+}
+
+/***Unit-Tests***/
+
+func TestFlows(t *testing.T) {
+	flows := NewFlows()
+	now := time.Now()
+	hello := clientHello(t, "cdn.example.com")
+	// check a ClientHello split across packets is partial until its last packet
+	for _, cut := range [][2]int{{0, 3}, {3, 100}} {
+		pkt := flowPacket(40000, 1000+uint32(cut[0]), hello[cut[0]:cut[1]])
+		if flows.Observe(pkt, now); !pkt.Partial || pkt.Invalid || pkt.SNI != "" {
+			t.Fatalf("Unexpected partial packet: %+v\n", pkt)
+		}
+	}
+	// check retransmissions of data already read keep the flow partial
+	pkt := flowPacket(40000, 1003, hello[3:100])
+	if flows.Observe(pkt, now); !pkt.Partial || pkt.Invalid {
+		t.Fatalf("Unexpected retransmitted packet: %+v\n", pkt)
+	}
+	pkt = flowPacket(40000, 1100, hello[100:])
+	if flows.Observe(pkt, now); pkt.Partial || pkt.Invalid || pkt.SNI != "cdn.example.com" {
+		t.Fatalf("Unexpected last packet: %+v\n", pkt)
+	}
+	// check later packets of the flow carry its server name
+	pkt = flowPacket(40000, 1000+uint32(len(hello)), []byte{0x17, 0x03, 0x03, 0x00, 0x01, 0x00})
+	if flows.Observe(pkt, now); pkt.SNI != "cdn.example.com" {
+		t.Fatalf("Unexpected later packet: %+v\n", pkt)
+	}
+	// check gaps make the ClientHello invalid for the rest of the flow
+	for _, cut := range [][2]int{{0, 100}, {150, len(hello)}, {100, 150}} {
+		pkt = flowPacket(40001, 1000+uint32(cut[0]), hello[cut[0]:cut[1]])
+		flows.Observe(pkt, now)
+	}
+	if !pkt.Invalid || pkt.Partial || pkt.SNI != "" {
+		t.Fatalf("Unexpected packet after gap: %+v\n", pkt)
+	}
+	// check ClientHellos spanning too many packets are invalid
+	for n := 0; n <= flowMaxPackets; n++ {
+		pkt = flowPacket(40002, 1000+uint32(n), hello[n:n+1])
+		flows.Observe(pkt, now)
+	}
+	if !pkt.Invalid {
+		t.Fatalf("Unexpected packet beyond the packet limit: %+v\n", pkt)
+	}
+	// check later requests of a flow carry their own host and idle flows are forgotten
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		pkt = flowPacket(40003, 1000, []byte("GET / HTTP/1.1\r\nHost: "+host+"\r\n\r\n"))
+		if flows.Observe(pkt, now); pkt.Host != host {
+			t.Fatalf("Unexpected host of request: %+v\n", pkt)
+		}
+	}
+	flows.Observe(flowPacket(40004, 1000, hello[:3]), now.Add(flowHold))
+	if len(flows.flows) != 1 {
+		t.Fatalf("Unexpected flows after sweep: %d\n", len(flows.flows))
+	}
+}
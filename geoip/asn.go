@@ -0,0 +1,87 @@
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/***Variables***/
+
+//asnPrefix : prefix of autonomous system numbers within rules and blacklist entries
+const asnPrefix = "AS"
+
+/***Functions***/
+
+//formatASN : describe autonomous system number (AS15169)
+func formatASN(number uint64) string {
+	return asnPrefix + strconv.FormatUint(number, 10)
+}
+
+//ParseASN : normalize autonomous system number of the form AS15169 (case insensitive)
+func ParseASN(value string) (string, error) {
+	if len(value) <= len(asnPrefix) || !strings.EqualFold(value[:len(asnPrefix)], asnPrefix) {
+		return "", fmt.Errorf("invalid autonomous system: %q (e.g. AS15169)", value)
+	}
+	number, err := strconv.ParseUint(value[len(asnPrefix):], 10, 32)
+	if err != nil || number == 0 {
+		return "", fmt.Errorf("invalid autonomous system: %q (e.g. AS15169)", value)
+	}
+	return formatASN(number), nil
+}
+
+//IsASN : check if value is an autonomous system number of the form AS15169
+func IsASN(value string) bool {
+	_, err := ParseASN(value)
+	return err == nil
+}
+
+//parsePfx2as : load the ipv4 prefixes of a pfx2as dump into a table
+// lines are "prefix<tab>length<tab>asn" (CAIDA) or "network asn", the first origin of multi-origin
+// (1234_5678) and as-set (1234,5678) prefixes is used
+func parsePfx2as(r io.Reader) (*Table, error) {
+	t := &Table{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var cidr, origin string
+		switch len(fields) {
+		case 2:
+			cidr, origin = fields[0], fields[1]
+		case 3:
+			cidr, origin = fields[0]+"/"+fields[1], fields[2]
+		default:
+			return nil, fmt.Errorf("line %d: expected 2 or 3 columns, got %d", line, len(fields))
+		}
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid prefix: %q", line, cidr)
+		}
+		if ip.To4() == nil {
+			continue
+		}
+		origins := strings.FieldsFunc(origin, func(r rune) bool { return r == '_' || r == ',' })
+		if len(origins) == 0 {
+			return nil, fmt.Errorf("line %d: invalid origin: %q", line, origin)
+		}
+		number, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(origins[0]), asnPrefix), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid origin: %q", line, origin)
+		}
+		start, _ := ipv4Int(network.IP)
+		ones, _ := network.Mask.Size()
+		end := start | uint32(uint64(1)<<uint(32-ones)-1)
+		t.ranges = append(t.ranges, Range{Start: start, End: end, Value: formatASN(number)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	t.merge()
+	return t, nil
+}
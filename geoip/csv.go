@@ -0,0 +1,86 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+/***Functions***/
+
+//parseCSV : load the ipv4 rows of a country range file into a table
+// rows are either "start,end,country" (DB-IP) or "network,country", ipv6 rows and a header are skipped
+func parseCSV(r io.Reader) (*Table, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	t := &Table{}
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rng, ok, err := parseCSVRow(row)
+		switch {
+		// header row naming the columns
+		case err != nil && line == 1:
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		case ok:
+			t.ranges = append(t.ranges, rng)
+		}
+	}
+	t.merge()
+	return t, nil
+}
+
+//parseCSVRow : convert a csv row into a country range (false for ipv6 or unknown country rows)
+func parseCSVRow(row []string) (Range, bool, error) {
+	var (
+		rng   Range
+		start net.IP
+		end   net.IP
+	)
+	switch len(row) {
+	case 2:
+		_, network, err := net.ParseCIDR(row[0])
+		if err != nil {
+			return rng, false, fmt.Errorf("invalid network: %q", row[0])
+		}
+		start = network.IP
+		end = make(net.IP, len(network.IP))
+		for i := range network.IP {
+			end[i] = network.IP[i] | ^network.Mask[i]
+		}
+	case 3:
+		start, end = net.ParseIP(row[0]), net.ParseIP(row[1])
+		if start == nil || end == nil {
+			return rng, false, fmt.Errorf("invalid range: %q-%q", row[0], row[1])
+		}
+	default:
+		return rng, false, fmt.Errorf("expected 2 or 3 columns, got %d", len(row))
+	}
+	rng.Value = strings.ToUpper(row[len(row)-1])
+	// DB-IP marks unassigned ranges with ZZ
+	if rng.Value == "ZZ" {
+		return rng, false, nil
+	}
+	if err := CheckCountry(rng.Value); err != nil {
+		return rng, false, err
+	}
+	var ok4 bool
+	if rng.Start, ok4 = ipv4Int(start); !ok4 {
+		return rng, false, nil
+	}
+	if rng.End, ok4 = ipv4Int(end); !ok4 || rng.End < rng.Start {
+		return rng, false, fmt.Errorf("invalid range: %q-%q", row[0], row[1])
+	}
+	return rng, true, nil
+}
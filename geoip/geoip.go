@@ -0,0 +1,246 @@
+package geoip
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/***Variables***/
+
+const (
+	// MMDBFile : MaxMind GeoLite2/DB-IP country database in MaxMind DB format
+	MMDBFile = "/usr/share/GeoIP/GeoLite2-Country.mmdb"
+	// CSVFile : country range file (start,end,country or network,country rows)
+	CSVFile = "/usr/share/GeoIP/dbip-country-lite.csv"
+	// ASNMMDBFile : MaxMind GeoLite2/DB-IP asn database in MaxMind DB format
+	ASNMMDBFile = "/usr/share/GeoIP/GeoLite2-ASN.mmdb"
+	// PFX2ASFile : prefix to autonomous system dump (CAIDA pfx2as format)
+	PFX2ASFile = "/usr/share/GeoIP/pfx2as.txt"
+)
+
+//databases searched in order, the first existing file is used
+var (
+	CountryFiles = []string{MMDBFile, CSVFile}
+	ASNFiles     = []string{ASNMMDBFile, PFX2ASFile}
+)
+
+//Kind : data a database resolves ip-addresses to
+type Kind int
+
+const (
+	Countries Kind = iota // iso 3166-1 alpha-2 country codes (US)
+	ASNs                  // autonomous system numbers (AS15169)
+)
+
+//checkInterval : minimum time between checks whether the database file changed
+const checkInterval = 30 * time.Second
+
+//Range : inclusive ipv4-address range assigned to a country/autonomous system
+type Range struct {
+	Start uint32
+	End   uint32
+	Value string // country code or autonomous system number
+}
+
+//Table : sorted non-overlapping ranges searched by address
+type Table struct {
+	ranges []Range
+}
+
+//DB : table loaded from a database file and reloaded when the file changes
+type DB struct {
+	Path string
+	Kind Kind
+
+	table    atomic.Value // *Table
+	lock     sync.Mutex   // serializes reloads
+	modTime  time.Time
+	size     int64
+	checked  int64 // unix-nano time of the last change check
+	checking int32 // set while a background check is running
+}
+
+/***Functions***/
+
+//Open : load database of the given kind from a mmdb/csv/pfx2as file
+func Open(path string, kind Kind) (*DB, error) {
+	db := &DB{Path: path, Kind: kind}
+	if _, err := db.Reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+//Find : open the first existing database of the given files (os.ErrNotExist if none exist)
+func Find(kind Kind, paths ...string) (*DB, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return Open(path, kind)
+	}
+	return nil, os.ErrNotExist
+}
+
+//NewTable : create table of the given ranges (more specific ranges override the ranges containing them)
+func NewTable(ranges []Range) *Table {
+	t := &Table{ranges: append([]Range(nil), ranges...)}
+	t.merge()
+	return t
+}
+
+//LoadFile : load table from mmdb file (.mmdb), country range csv file or pfx2as dump
+func LoadFile(path string, kind Kind) (*Table, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t *Table
+	switch {
+	case strings.HasSuffix(strings.ToLower(path), ".mmdb"):
+		t, err = parseMMDB(buf, kind)
+	case kind == ASNs:
+		t, err = parsePfx2as(bytes.NewReader(buf))
+	default:
+		t, err = parseCSV(bytes.NewReader(buf))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return t, nil
+}
+
+//CheckCountry : ensure code is an uppercase iso 3166-1 alpha-2 country code
+func CheckCountry(code string) error {
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return fmt.Errorf("invalid country code: %q (e.g. US/DE)", code)
+	}
+	return nil
+}
+
+//ipv4Int : convert ipv4-address to integer (false for invalid/ipv6 addresses)
+func ipv4Int(ip net.IP) (uint32, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, false
+	}
+	return uint32(ip4[0])<<24 | uint32(ip4[1])<<16 | uint32(ip4[2])<<8 | uint32(ip4[3]), true
+}
+
+/***Methods***/
+
+//(*Table).Lookup : return country code/autonomous system of ip-address ("" if unknown)
+func (t *Table) Lookup(ip net.IP) string {
+	addr, ok := ipv4Int(ip)
+	if !ok {
+		return ""
+	}
+	i := sort.Search(len(t.ranges), func(i int) bool { return t.ranges[i].End >= addr })
+	if i < len(t.ranges) && t.ranges[i].Start <= addr {
+		return t.ranges[i].Value
+	}
+	return ""
+}
+
+//(*Table).Len : return number of ranges
+func (t *Table) Len() int {
+	return len(t.ranges)
+}
+
+//(*Table).merge : sort ranges, let more specific ranges override the ranges containing them
+// (the later of identical ranges wins) and join adjacent ranges of the same value
+func (t *Table) merge() {
+	sort.SliceStable(t.ranges, func(i, j int) bool {
+		a, b := t.ranges[i], t.ranges[j]
+		return a.Start < b.Start || (a.Start == b.Start && a.End > b.End)
+	})
+	var (
+		flat   []Range
+		open   []Range // ranges containing the current position, innermost last
+		cursor uint64  // first address not yet assigned
+	)
+	emit := func(end uint32, value string) {
+		if cursor > uint64(end) {
+			return
+		}
+		if n := len(flat); n > 0 && flat[n-1].Value == value && uint64(flat[n-1].End)+1 == cursor {
+			flat[n-1].End = end
+		} else {
+			flat = append(flat, Range{Start: uint32(cursor), End: end, Value: value})
+		}
+		cursor = uint64(end) + 1
+	}
+	for _, r := range t.ranges {
+		for len(open) > 0 && open[len(open)-1].End < r.Start {
+			emit(open[len(open)-1].End, open[len(open)-1].Value)
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 && r.Start > 0 {
+			emit(r.Start-1, open[len(open)-1].Value)
+		}
+		if cursor < uint64(r.Start) {
+			cursor = uint64(r.Start)
+		}
+		open = append(open, r)
+	}
+	for len(open) > 0 {
+		emit(open[len(open)-1].End, open[len(open)-1].Value)
+		open = open[:len(open)-1]
+	}
+	t.ranges = flat
+}
+
+//(*DB).Table : return currently loaded table
+func (db *DB) Table() *Table {
+	return db.table.Load().(*Table)
+}
+
+//(*DB).Reload : reload table if the database file changed since it was last loaded
+func (db *DB) Reload() (bool, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	fi, err := os.Stat(db.Path)
+	if err != nil {
+		return false, err
+	}
+	atomic.StoreInt64(&db.checked, time.Now().UnixNano())
+	if db.table.Load() != nil && fi.ModTime().Equal(db.modTime) && fi.Size() == db.size {
+		return false, nil
+	}
+	t, err := LoadFile(db.Path, db.Kind)
+	if err != nil {
+		return false, err
+	}
+	db.table.Store(t)
+	db.modTime, db.size = fi.ModTime(), fi.Size()
+	return true, nil
+}
+
+//(*DB).Lookup : return country code/autonomous system of ip-address ("" if unknown or without database)
+// the database file is checked for changes in the background at most every checkInterval
+func (db *DB) Lookup(ip string) string {
+	if db == nil {
+		return ""
+	}
+	now := time.Now().UnixNano()
+	if now-atomic.LoadInt64(&db.checked) > int64(checkInterval) && atomic.CompareAndSwapInt32(&db.checking, 0, 1) {
+		atomic.StoreInt64(&db.checked, now)
+		go func() {
+			// keep serving the loaded table if the changed file can not be loaded
+			db.Reload()
+			atomic.StoreInt32(&db.checking, 0)
+		}()
+	}
+	return db.Table().Lookup(net.ParseIP(ip))
+}
@@ -0,0 +1,159 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/***Functions***/
+
+//checkLookups : ensure table resolves the given addresses to the expected values
+func checkLookups(t *testing.T, name string, table *Table, expected map[string]string) {
+	for ip, country := range expected {
+		if found := table.Lookup(net.ParseIP(ip)); found != country {
+			t.Fatalf("Unexpected value of %s in %s: %q (expected %q)\n", ip, name, found, country)
+		}
+	}
+}
+
+/***Unit-Tests***/
+
+func TestLoadMMDB(t *testing.T) {
+	table, err := LoadFile(filepath.Join("testdata", "country.mmdb"), Countries)
+	if err != nil {
+		t.Fatalf("Unable to load mmdb fixture: %s\n", err.Error())
+	}
+	checkLookups(t, "mmdb", table, map[string]string{
+		"1.0.0.1":       "AU",
+		"5.255.200.1":   "RU",
+		"8.8.8.8":       "US",
+		"8.8.4.4":       "US",
+		"203.0.113.5":   "NL", // registered country only
+		"203.0.113.200": "",
+		"9.9.9.9":       "",
+		"2001::1":       "",
+		"invalid":       "",
+	})
+}
+
+func TestLoadCSV(t *testing.T) {
+	table, err := LoadFile(filepath.Join("testdata", "country.csv"), Countries)
+	if err != nil {
+		t.Fatalf("Unable to load csv fixture: %s\n", err.Error())
+	}
+	checkLookups(t, "csv", table, map[string]string{
+		"1.0.0.1":       "AU",
+		"1.0.2.3":       "CN",
+		"5.255.200.1":   "RU",
+		"8.8.8.8":       "US",
+		"203.0.113.127": "NL",
+		"203.0.113.128": "",
+		"10.1.2.3":      "",
+	})
+	// adjacent ranges of the same country are joined
+	if table.Len() != 6 {
+		t.Fatalf("Unexpected number of ranges: %d\n", table.Len())
+	}
+	for _, bad := range []string{"1.0.0.0,1.0.0.255,AU\n2.0.0.0,1.0.0.0,US\n", "1.0.0.0,1.0.0.255,AU\nnope,DE\n", "start,end,country\n1.0.0.0,1.0.0.255,USA\n"} {
+		if _, err = parseCSV(strings.NewReader(bad)); err == nil {
+			t.Fatalf("Able to load invalid csv: %q\n", bad)
+		}
+	}
+}
+
+func TestLoadASN(t *testing.T) {
+	table, err := LoadFile(filepath.Join("testdata", "asn.mmdb"), ASNs)
+	if err != nil {
+		t.Fatalf("Unable to load mmdb fixture: %s\n", err.Error())
+	}
+	checkLookups(t, "mmdb", table, map[string]string{
+		"1.1.1.1":     "AS13335",
+		"8.8.8.8":     "AS15169",
+		"8.8.4.4":     "AS15169",
+		"5.255.1.1":   "AS64512",
+		"5.255.200.1": "",
+		"9.9.9.9":     "",
+	})
+	table, err = LoadFile(filepath.Join("testdata", "pfx2as.txt"), ASNs)
+	if err != nil {
+		t.Fatalf("Unable to load pfx2as fixture: %s\n", err.Error())
+	}
+	// check more specific prefixes override the prefixes containing them
+	checkLookups(t, "pfx2as", table, map[string]string{
+		"1.1.1.1":     "AS13335",
+		"8.8.8.8":     "AS15169",
+		"5.1.2.3":     "AS64496",
+		"5.255.1.1":   "AS64512",
+		"5.255.64.1":  "AS64496",
+		"5.255.200.1": "AS64512",
+		"6.0.0.1":     "",
+	})
+	// 8.8.4.0/24 and 8.8.8.0/24 are not adjacent, 5.0.0.0/8 is split around 5.255.0.0/16
+	if table.Len() != 7 {
+		t.Fatalf("Unexpected number of ranges: %d\n", table.Len())
+	}
+	for _, bad := range []string{"1.1.1.0\t24\n", "1.1.1.0\t33\t13335\n", "1.1.1.0\t24\tnope\n", "1.1.1.0\t24\t_\n"} {
+		if _, err = parsePfx2as(strings.NewReader(bad)); err == nil {
+			t.Fatalf("Able to load invalid pfx2as: %q\n", bad)
+		}
+	}
+	for value, expected := range map[string]string{"AS15169": "AS15169", "as64512": "AS64512", "AS": "", "AS0": "", "15169": "", "ASx": ""} {
+		if asn, _ := ParseASN(value); asn != expected {
+			t.Fatalf("Unexpected asn of %q: %q\n", value, asn)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goaway-geoip")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s\n", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "country.csv")
+	if err = ioutil.WriteFile(path, []byte("8.8.8.0/24,US\n"), 0644); err != nil {
+		t.Fatalf("Unable to write database: %s\n", err.Error())
+	}
+	if _, err = Find(Countries, filepath.Join(dir, "missing.mmdb")); !os.IsNotExist(err) {
+		t.Fatalf("Unexpected error without database: %v\n", err)
+	}
+	db, err := Find(Countries, filepath.Join(dir, "missing.mmdb"), path)
+	if err != nil {
+		t.Fatalf("Unable to open database: %s\n", err.Error())
+	}
+	if country := db.Lookup("8.8.8.8"); country != "US" {
+		t.Fatalf("Unexpected country: %q\n", country)
+	}
+	if changed, err := db.Reload(); changed || err != nil {
+		t.Fatalf("Reloaded unchanged database: %v\n", err)
+	}
+	// check changed files replace the table while broken files keep it
+	if err = ioutil.WriteFile(path, []byte("8.8.8.0/24,DE\n"), 0644); err != nil {
+		t.Fatalf("Unable to write database: %s\n", err.Error())
+	}
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if changed, err := db.Reload(); !changed || err != nil {
+		t.Fatalf("Unable to reload changed database: %v\n", err)
+	}
+	if country := db.Lookup("8.8.8.8"); country != "DE" {
+		t.Fatalf("Unexpected country after reload: %q\n", country)
+	}
+	if err = ioutil.WriteFile(path, []byte("8.8.8.0/24,DE,extra,columns\n8.8.8.0/24\n"), 0644); err != nil {
+		t.Fatalf("Unable to write database: %s\n", err.Error())
+	}
+	if _, err = db.Reload(); err == nil {
+		t.Fatalf("Able to reload broken database\n")
+	}
+	if country := db.Lookup("8.8.8.8"); country != "DE" {
+		t.Fatalf("Unexpected country after failed reload: %q\n", country)
+	}
+	var none *DB
+	if country := none.Lookup("8.8.8.8"); country != "" {
+		t.Fatalf("Unexpected country without database: %q\n", country)
+	}
+}
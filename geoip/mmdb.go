@@ -0,0 +1,298 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/***Variables***/
+
+//mmdbMetadataMarker : marker preceding the metadata section at the end of a mmdb file
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+//mmdb data section types
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+//mmdbDataSeparator : zero bytes between the search tree and the data section
+const mmdbDataSeparator = 16
+
+//mmdb : search tree and data section of a MaxMind DB file
+type mmdb struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	kind       Kind
+	values     map[uint]string // values of already decoded data records
+}
+
+/***Functions***/
+
+//parseMMDB : load the ipv4 networks of a MaxMind DB (GeoLite2/DB-IP country or asn format) into a table
+func parseMMDB(buf []byte, kind Kind) (*Table, error) {
+	db, err := openMMDB(buf, kind)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{}
+	// ipv4 addresses of ipv6 databases are stored within ::/96
+	node := uint(0)
+	if db.ipVersion == 6 {
+		for depth := 0; depth < 96 && node < db.nodeCount; depth++ {
+			node = db.record(node, 0)
+		}
+	}
+	if err = db.walk(node, 0, 0, t); err != nil {
+		return nil, err
+	}
+	t.merge()
+	return t, nil
+}
+
+//openMMDB : split mmdb file into its sections using the metadata
+func openMMDB(buf []byte, kind Kind) (*mmdb, error) {
+	start := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("missing mmdb metadata")
+	}
+	raw, _, err := decodeMMDB(buf[start+len(mmdbMetadataMarker):], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mmdb metadata: %s", err.Error())
+	}
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid mmdb metadata")
+	}
+	db := &mmdb{kind: kind, values: make(map[uint]string)}
+	for key, field := range map[string]*uint{"node_count": &db.nodeCount, "record_size": &db.recordSize, "ip_version": &db.ipVersion} {
+		value, ok := meta[key].(uint64)
+		if !ok {
+			return nil, fmt.Errorf("mmdb metadata is missing %q", key)
+		}
+		*field = uint(value)
+	}
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported mmdb record size: %d", db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported mmdb ip version: %d", db.ipVersion)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+mmdbDataSeparator > uint(start) {
+		return nil, fmt.Errorf("mmdb search tree exceeds the file")
+	}
+	db.tree, db.data = buf[:treeSize], buf[treeSize+mmdbDataSeparator:start]
+	return db, nil
+}
+
+//decodeMMDB : decode value of the data section at the given offset and return the offset following it
+// (pointers are followed once since pointers to pointers are not allowed)
+func decodeMMDB(section []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > 32 {
+		return nil, 0, fmt.Errorf("data nested too deep")
+	}
+	read := func(n uint) ([]byte, error) {
+		if offset+n > uint(len(section)) {
+			return nil, fmt.Errorf("data exceeds the section at offset %d", offset)
+		}
+		b := section[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+	b, err := read(1)
+	if err != nil {
+		return nil, 0, err
+	}
+	kind, size := uint(b[0]>>5), uint(b[0]&0x1f)
+	if kind == mmdbPointer {
+		n := (size >> 3) + 1
+		raw, err := read(n)
+		if err != nil {
+			return nil, 0, err
+		}
+		var target uint
+		if n < 4 {
+			target = size & 0x7
+		}
+		for _, c := range raw {
+			target = target<<8 | uint(c)
+		}
+		target += [...]uint{0, 2048, 526336, 0}[n-1]
+		value, _, err := decodeMMDB(section, target, depth+1)
+		return value, offset, err
+	}
+	if kind == mmdbExtended {
+		ext, err := read(1)
+		if err != nil {
+			return nil, 0, err
+		}
+		kind = 7 + uint(ext[0])
+	}
+	if size >= 29 {
+		raw, err := read(size - 28)
+		if err != nil {
+			return nil, 0, err
+		}
+		extra := uint(0)
+		for _, c := range raw {
+			extra = extra<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[size-29] + extra
+	}
+	switch kind {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = decodeMMDB(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			if value, offset, err = decodeMMDB(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string")
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, offset, err = decodeMMDB(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+	raw, err := read(size)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch kind {
+	case mmdbString:
+		return string(raw), offset, nil
+	case mmdbDouble, mmdbFloat:
+		if kind == mmdbFloat && size == 4 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), offset, nil
+		}
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid float size: %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size: %d", size)
+		}
+		var value uint64
+		for _, c := range raw {
+			value = value<<8 | uint64(c)
+		}
+		return value, offset, nil
+	case mmdbBytes, mmdbUint128:
+		return raw, offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type: %d", kind)
+	}
+}
+
+/***Methods***/
+
+//(*mmdb).record : return left (bit 0) or right (bit 1) record of a search tree node
+func (db *mmdb) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]>>4)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+	}
+}
+
+//(*mmdb).walk : add the networks below the record at the given ipv4 prefix length to the table
+func (db *mmdb) walk(record uint, depth int, prefix uint32, t *Table) error {
+	switch {
+	// empty record without data
+	case record == db.nodeCount:
+		return nil
+	// data record covering the whole network
+	case record > db.nodeCount:
+		value, err := db.value(record - db.nodeCount - mmdbDataSeparator)
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			end := prefix | uint32(uint64(1)<<uint(32-depth)-1)
+			t.ranges = append(t.ranges, Range{Start: prefix, End: end, Value: value})
+		}
+		return nil
+	case depth >= 32:
+		return fmt.Errorf("mmdb search tree is deeper than an ipv4 address")
+	}
+	if err := db.walk(db.record(record, 0), depth+1, prefix, t); err != nil {
+		return err
+	}
+	return db.walk(db.record(record, 1), depth+1, prefix|1<<uint(31-depth), t)
+}
+
+//(*mmdb).value : return iso code of the country (or registered country) or the autonomous system of a data record
+func (db *mmdb) value(offset uint) (string, error) {
+	if value, ok := db.values[offset]; ok {
+		return value, nil
+	}
+	raw, _, err := decodeMMDB(db.data, offset, 0)
+	if err != nil {
+		return "", err
+	}
+	var value string
+	if record, ok := raw.(map[string]interface{}); ok {
+		switch db.kind {
+		case ASNs:
+			if number, ok := record["autonomous_system_number"].(uint64); ok {
+				value = formatASN(number)
+			}
+		default:
+			for _, key := range []string{"country", "registered_country"} {
+				if c, ok := record[key].(map[string]interface{}); ok {
+					if code, ok := c["iso_code"].(string); ok {
+						value = code
+						break
+					}
+				}
+			}
+		}
+	}
+	db.values[offset] = value
+	return value, nil
+}
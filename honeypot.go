@@ -0,0 +1,174 @@
+package goaway2
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/***Variables***/
+
+//honeypotPrefix : prefix of the reason of blacklist entries written by honeypots
+const honeypotPrefix = "honeypot:"
+
+//honeypot gateway lookup
+const (
+	honeypotRoutes      = "/proc/net/route" // routing table the gateways are read from
+	honeypotGatewayTime = time.Minute       // time the gateways read from the routing table are reused
+)
+
+//Honeypots : unused inbound ports blacklisting every source touching them
+type Honeypots map[KnockPort]Honeypot
+
+//Honeypot : blacklist entry written for the sources touching a honeypot port
+type Honeypot struct {
+	Expiry time.Duration // time the blacklist entry lasts (0 never expires)
+	// trip on the first packet instead of a verified tcp handshake, the source address of a udp
+	// packet or SYN may be spoofed to blacklist any address (e.g. a dns resolver of the host)
+	Unverified bool
+}
+
+//honeypotVerifier : answers SYNs to tcp honeypots with a SYN-ACK carrying a keyed cookie, only the ACK
+// completing the handshake trips the honeypot (a spoofed source never receives the cookie)
+type honeypotVerifier struct {
+	Sender TarpitSender // sender of the SYN-ACKs (raw ip socket marked with TarpitMark if nil)
+
+	key  []byte
+	lock sync.Mutex
+}
+
+//gatewayCache : gateways of the routing table honeypots never blacklist
+type gatewayCache struct {
+	path  string
+	lock  sync.Mutex
+	addrs map[string]bool
+	read  time.Time
+}
+
+//HoneypotEvent : source blacklisted for touching a honeypot port
+type HoneypotEvent struct {
+	Time     time.Time
+	SrcIP    string
+	Port     int64
+	Protocol string    // lowercase tcp/udp
+	Reason   string    // reason of the blacklist entry (honeypot:<port>)
+	Expires  time.Time // zero if the blacklist entry never expires
+}
+
+/***Functions***/
+
+//HoneypotReason : return the reason of the blacklist entries written by the honeypot of the given port
+func HoneypotReason(port int64) string {
+	return honeypotPrefix + strconv.FormatInt(port, 10)
+}
+
+//IsHoneypotReason : check if the reason of a blacklist entry was written by a honeypot
+func IsHoneypotReason(reason string) bool {
+	return strings.HasPrefix(reason, honeypotPrefix)
+}
+
+//newHoneypotVerifier : create verifier with a random cookie key
+func newHoneypotVerifier() *honeypotVerifier {
+	key := make([]byte, sha256.Size)
+	rand.Read(key)
+	return &honeypotVerifier{key: key}
+}
+
+//readGateways : collect the ipv4 gateways of a routing table in the format of /proc/net/route
+func readGateways(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gateways := make(map[string]bool)
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		// columns: Iface Destination Gateway Flags ... (addresses in host byte order)
+		fields := strings.Fields(lines.Text())
+		if len(fields) < 3 || fields[0] == "Iface" {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil || gw == 0 {
+			continue
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.LittleEndian.PutUint32(ip, uint32(gw))
+		gateways[ip.String()] = true
+	}
+	return gateways, lines.Err()
+}
+
+/***Methods***/
+
+//(Honeypots).Lookup : return the honeypot the inbound packet touched (false if it touched none)
+func (h Honeypots) Lookup(pkt *PacketData) (KnockPort, Honeypot, bool) {
+	if len(h) == 0 || !pkt.IsInbound() {
+		return KnockPort{}, Honeypot{}, false
+	}
+	port := KnockPort{Port: pkt.DstPort, Protocol: strings.ToLower(pkt.Protocol)}
+	hp, ok := h[port]
+	return port, hp, ok
+}
+
+//(*honeypotVerifier).cookie : return the sequence number of the SYN-ACK answering the SYN with the given
+// initial sequence number
+func (v *honeypotVerifier) cookie(pkt *PacketData, isn uint32) uint32 {
+	mac := hmac.New(sha256.New, v.key)
+	fmt.Fprintf(mac, "%s:%d>%s:%d/%d", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort, isn)
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
+
+//(*honeypotVerifier).Challenge : answer the SYN with a SYN-ACK whose sequence number is the cookie
+func (v *honeypotVerifier) Challenge(pkt *PacketData) error {
+	v.lock.Lock()
+	if v.Sender == nil {
+		v.Sender = &rawSender{mark: TarpitMark}
+	}
+	sender := v.Sender
+	v.lock.Unlock()
+	packet, err := tcpPacket(pkt.DstIP, pkt.SrcIP, pkt.DstPort, pkt.SrcPort, v.cookie(pkt, pkt.Seq), pkt.Seq+1, 0x12) // SYN|ACK
+	if err != nil {
+		return err
+	}
+	return sender.Send(net.ParseIP(pkt.SrcIP), packet)
+}
+
+//(*honeypotVerifier).Verified : check if the packet completes the handshake of a challenged SYN
+// (its acknowledgment number follows the cookie)
+func (v *honeypotVerifier) Verified(pkt *PacketData) bool {
+	return !pkt.Syn && strings.EqualFold(pkt.Protocol, "tcp") && pkt.Ack == v.cookie(pkt, pkt.Seq-1)+1
+}
+
+//(*gatewayCache).Contains : check if ip-address is a gateway of the routing table (read again after
+// honeypotGatewayTime, the last gateways are kept if it can not be read)
+func (g *gatewayCache) Contains(ip string, now time.Time) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if now.Sub(g.read) >= honeypotGatewayTime {
+		g.read = now
+		if addrs, err := readGateways(g.path); err == nil {
+			g.addrs = addrs
+		}
+	}
+	return g.addrs[ip]
+}
+
+//(HoneypotEvent).String : describe the event for logging
+func (e HoneypotEvent) String() string {
+	expires := "never expires"
+	if !e.Expires.IsZero() {
+		expires = "expires " + e.Expires.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s touched %d/%s, blacklisted as %q (%s)", e.SrcIP, e.Port, e.Protocol, e.Reason, expires)
+}
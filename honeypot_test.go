@@ -0,0 +1,188 @@
+package goaway2
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Unit-Tests***/
+
+func TestFirewallHoneypot(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AddHoneypot(store.Honeypot{Port: 23, Protocol: "tcp", Expiry: 3600, Unverified: true})
+	st.AddHoneypot(store.Honeypot{Port: 3389, Protocol: "tcp", Unverified: true})
+	fw := newFirewall(st, profiles.NewSet())
+	fw.gateways.path = ""
+	var events []HoneypotEvent
+	fw.OnHoneypot = func(e HoneypotEvent) { events = append(events, e) }
+	l := log.New(ioutil.Discard, "", 0)
+	kv := NewRedBlackKV()
+	packet := func(src string, port int64, protocol string, hook string) *PacketData {
+		return &PacketData{SrcIP: src, SrcPort: 40000, DstIP: "192.168.200.114", DstPort: port, Protocol: protocol, Hook: hook}
+	}
+	const a, b = "198.51.100.7", "203.0.113.5"
+	// check outbound packets and other protocols do not touch the honeypot
+	for _, pkt := range []*PacketData{packet(a, 23, "TCP", HookOutput), packet(a, 23, "UDP", HookInput), packet(a, 22, "TCP", HookInput)} {
+		if v := fw.HandlePackets(l, kv, pkt); v != netfilter.NF_ACCEPT || len(events) != 0 {
+			t.Fatalf("Unexpected verdict of %+v: %v (%d events)\n", pkt, v, len(events))
+		}
+	}
+	// audit mode records the packet without blacklisting its source
+	fw.Audit = true
+	if v := fw.HandlePackets(l, kv, packet(a, 23, "TCP", HookInput)); v != netfilter.NF_ACCEPT || len(events) != 0 {
+		t.Fatalf("Honeypot blacklisted source in audit mode: %v\n", v)
+	}
+	fw.Audit = false
+	if d := fw.Decide(kv, packet(a, 23, "TCP", HookInput)); d.Reason != "honeypot" || d.Honeypot != "honeypot:23" || d.Expiry != time.Hour || !d.Trip {
+		t.Fatalf("Unexpected decision of the honeypot: %+v\n", d)
+	}
+	if exists, _ := st.HasEntry(store.Blacklist, a); exists {
+		t.Fatalf("Deciding a packet blacklisted its source\n")
+	}
+	if v := fw.HandlePackets(l, kv, packet(a, 23, "TCP", HookInput)); v != netfilter.NF_DROP {
+		t.Fatalf("Honeypot packet was not dropped: %v\n", v)
+	}
+	if len(events) != 1 || events[0].SrcIP != a || events[0].Reason != "honeypot:23" || events[0].Protocol != "tcp" {
+		t.Fatalf("Unexpected honeypot events: %+v\n", events)
+	}
+	if until := events[0].Expires.Sub(events[0].Time); until < time.Hour-time.Second || until > time.Hour {
+		t.Fatalf("Unexpected expiry of the blacklist entry: %s\n", until)
+	}
+	entries, _ := st.Entries(store.Blacklist)
+	if len(entries) != 1 || entries[0].IPAddress != a || entries[0].Reason != "honeypot:23" || entries[0].Expires != events[0].Expires.UTC().Format(store.DateLayout) {
+		t.Fatalf("Unexpected blacklist entries: %+v\n", entries)
+	}
+	// the blacklisted source is dropped everywhere without further events
+	if d := fw.Decide(kv, packet(a, 22, "TCP", HookInput)); d.Verdict != netfilter.NF_DROP || d.Reason != "blacklist-src" {
+		t.Fatalf("Honeypot source was not blacklisted: %+v\n", d)
+	}
+	fw.HandlePackets(l, kv, packet(a, 3389, "TCP", HookInput))
+	if len(events) != 1 {
+		t.Fatalf("Blacklisted source emitted another event: %+v\n", events)
+	}
+	// check entries without expiry never expire and expired cache entries are dropped
+	fw.HandlePackets(l, kv, packet(b, 3389, "TCP", HookInput))
+	if len(events) != 2 || !events[1].Expires.IsZero() {
+		t.Fatalf("Unexpected honeypot events: %+v\n", events)
+	}
+	if blocked, until, _ := st.BlacklistedUntil(b); blocked != b || !until.IsZero() {
+		t.Fatalf("Unexpected blacklist entry: %q until %s\n", blocked, until)
+	}
+	fw.cacheBlacklist(kv, a, time.Now().Add(-time.Second))
+	if fw.blacklisted(kv, a) || fw.blacklist.Exists(kv, a) {
+		t.Fatalf("Expired blacklist cache entry is still blacklisted\n")
+	}
+}
+
+func TestFirewallHoneypotHandshake(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AddHoneypot(store.Honeypot{Port: 3389, Protocol: "tcp"})
+	st.AddHoneypot(store.Honeypot{Port: 3389, Protocol: "udp"})
+	fw := newFirewall(st, profiles.NewSet())
+	fw.gateways.path = ""
+	sender := &fakeSender{}
+	fw.verifier.Sender = sender
+	var events []HoneypotEvent
+	fw.OnHoneypot = func(e HoneypotEvent) { events = append(events, e) }
+	l := log.New(ioutil.Discard, "", 0)
+	kv := NewRedBlackKV()
+	const a, b = "198.51.100.7", "203.0.113.5"
+	// check the SYN is challenged without blacklisting its (possibly spoofed) source
+	syn := tarpitSyn(a, 40000, 3389)
+	if d := fw.Decide(kv, syn); d.Reason != "honeypot" || !d.Challenge || d.Trip || d.Tarpit {
+		t.Fatalf("Unexpected decision of the SYN: %+v\n", d)
+	}
+	if v := fw.HandlePackets(l, kv, syn); v != netfilter.NF_DROP || sender.count() != 1 || len(events) != 0 {
+		t.Fatalf("Unexpected handling of the SYN: %v (%d packets, %d events)\n", v, sender.count(), len(events))
+	}
+	tcp := sender.packets[0][ipv4HeaderSize:]
+	cookie := binary.BigEndian.Uint32(tcp[4:])
+	if tcp[13] != 0x12 || binary.BigEndian.Uint32(tcp[8:]) != syn.Seq+1 || cookie != fw.verifier.cookie(syn, syn.Seq) {
+		t.Fatalf("Unexpected SYN-ACK: % x\n", tcp)
+	}
+	// check ACKs not following the cookie and udp packets of verified honeypots do not trip
+	ack := &PacketData{SrcIP: a, SrcPort: 40000, DstIP: syn.DstIP, DstPort: 3389, Protocol: "TCP", Hook: HookInput, Seq: syn.Seq + 1, Ack: cookie}
+	udp := &PacketData{SrcIP: b, SrcPort: 40000, DstIP: syn.DstIP, DstPort: 3389, Protocol: "UDP", Hook: HookInput}
+	for _, pkt := range []*PacketData{ack, udp} {
+		if v := fw.HandlePackets(l, kv, pkt); v != netfilter.NF_DROP || len(events) != 0 {
+			t.Fatalf("Unverified packet tripped the honeypot: %+v (%v)\n", pkt, v)
+		}
+	}
+	// check the ACK completing the handshake trips the honeypot once
+	ack.Ack = cookie + 1
+	if v := fw.HandlePackets(l, kv, ack); v != netfilter.NF_DROP || len(events) != 1 || events[0].SrcIP != a {
+		t.Fatalf("Completed handshake did not trip the honeypot: %v %+v\n", v, events)
+	}
+	if added, err := st.AddHoneypotEntry(store.Entry{IPAddress: a, Reason: "honeypot:3389"}); added || err != nil {
+		t.Fatalf("Blacklisted honeypot source twice: %v\n", err)
+	}
+	if changes, _ := st.History(10); len(changes) != 3 {
+		t.Fatalf("Honeypot entry was recorded within the history: %+v\n", changes)
+	}
+}
+
+func TestFirewallHoneypotProtected(t *testing.T) {
+	defer restoreAddrs(t)()
+	dir, err := ioutil.TempDir("", "goaway-honeypot")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s\n", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	// gateway 192.0.2.1 of the default route in host byte order
+	routes := filepath.Join(dir, "route")
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t00000000\t010200C0\t0003\t0\t0\t100\t00000000\n" +
+		"eth0\t000200C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\n"
+	if err = ioutil.WriteFile(routes, []byte(table), 0600); err != nil {
+		t.Fatalf("Unable to write routing table: %s\n", err.Error())
+	}
+	if gateways, err := readGateways(routes); err != nil || len(gateways) != 1 || !gateways["192.0.2.1"] {
+		t.Fatalf("Unexpected gateways: %v (%v)\n", gateways, err)
+	}
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AddHoneypot(store.Honeypot{Port: 53, Protocol: "udp", Unverified: true})
+	fw := newFirewall(st, profiles.NewSet())
+	fw.gateways.path = routes
+	setLocalAddrs(map[string]string{"192.0.2.10": "eth0"})
+	// whitelist entry added after the firewall was loaded
+	st.AddEntry(store.Whitelist, store.Entry{IPAddress: "198.51.100.7"})
+	kv := NewRedBlackKV()
+	for _, src := range []string{"192.0.2.1", "192.0.2.10", "198.51.100.7"} {
+		pkt := &PacketData{SrcIP: src, SrcPort: 40000, DstIP: "192.0.2.10", DstPort: 53, Protocol: "UDP", Hook: HookInput}
+		if d := fw.Decide(kv, pkt); d.Reason == "honeypot" {
+			t.Fatalf("Honeypot would blacklist protected source %s: %+v\n", src, d)
+		}
+	}
+	if !fw.whitelist.Exists(kv, "198.51.100.7") {
+		t.Fatalf("Whitelisted source was not cached\n")
+	}
+	pkt := &PacketData{SrcIP: "203.0.113.5", SrcPort: 40000, DstIP: "192.0.2.10", DstPort: 53, Protocol: "UDP", Hook: HookInput}
+	if d := fw.Decide(kv, pkt); d.Reason != "honeypot" || !d.Trip {
+		t.Fatalf("Unexpected decision of unverified honeypot: %+v\n", d)
+	}
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/***Variables***/
+
+//Rule : rule collected from a foreign firewall configuration
+type Rule struct {
+	Action   string // allow/deny
+	Zone     string // any/inbound/outbound
+	FromIP   string
+	FromPort string
+	ToIP     string
+	ToPort   string
+}
+
+//Policy : goaway policy collected from a foreign firewall configuration
+type Policy struct {
+	Inbound   string // default for inbound packets ("" if not found)
+	Outbound  string // default for outbound packets ("" if not found)
+	Forward   string // default for forwarded packets ("" if not found)
+	Rules     []Rule
+	Blacklist []string
+	Skipped   []string // constructs that could not be translated
+}
+
+/***Functions***/
+
+//convertAddr : convert foreign address notation to goaway's ip notation
+func convertAddr(addr string) (string, error) {
+	switch addr {
+	case "", "any", "0.0.0.0/0", "::/0":
+		return "any", nil
+	}
+	// strip host-masks to a single ip-address
+	addr = strings.TrimSuffix(addr, "/32")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(addr); err != nil {
+			return "", fmt.Errorf("invalid address %q", addr)
+		}
+	}
+	if ip.To4() == nil {
+		return "", fmt.Errorf("ipv6 address %q is not supported", addr)
+	}
+	return addr, nil
+}
+
+//convertPorts : convert foreign port notation (comma lists, colon ranges) to goaway's port notation
+// (ranges include both bounds in either notation)
+func convertPorts(ports string) []string {
+	if ports == "" || ports == "any" {
+		return []string{"any"}
+	}
+	split := strings.Split(ports, ",")
+	for n, p := range split {
+		split[n] = strings.Replace(p, ":", "-", 1)
+	}
+	return split
+}
+
+/***Methods***/
+
+//(*Policy).skip : record construct that could not be translated
+func (p *Policy) skip(source, format string, args ...interface{}) {
+	p.Skipped = append(p.Skipped, source+": "+fmt.Sprintf(format, args...))
+}
+
+//(*Policy).addRule : append rule for every port combination and record blacklist entries
+func (p *Policy) addRule(source string, r Rule, sports, dports []string) {
+	// a deny with a single source address and nothing else is a blacklist entry
+	if r.Action == "deny" && r.Zone != "outbound" && r.FromIP != "any" && !strings.Contains(r.FromIP, "/") &&
+		r.ToIP == "any" && len(sports) == 1 && sports[0] == "any" && len(dports) == 1 && dports[0] == "any" {
+		for _, ip := range p.Blacklist {
+			if ip == r.FromIP {
+				return
+			}
+		}
+		p.Blacklist = append(p.Blacklist, r.FromIP)
+		return
+	}
+	for _, sport := range sports {
+		for _, dport := range dports {
+			rule := r
+			rule.FromPort, rule.ToPort = sport, dport
+			if rule.FromIP == "any" && rule.FromPort == "any" && rule.ToIP == "any" && rule.ToPort == "any" {
+				p.skip(source, "rule matching all packets is not supported")
+				continue
+			}
+			if !p.hasRule(rule) {
+				p.Rules = append(p.Rules, rule)
+			}
+		}
+	}
+}
+
+//(*Policy).hasRule : check if identical rule was already collected
+func (p *Policy) hasRule(rule Rule) bool {
+	for _, r := range p.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+//(*Policy).Translate : return the rules that decide packets differently from the given defaults
+// rules are imported along with their action, which goaway decides first-match like ufw/iptables
+// (rules without an action are ANDed under a deny default), so a rule agreeing with the default of
+// its direction is redundant unless a later rule of an overlapping direction has another action
+// goaway accepts packets no rule matched whatever the default, so every deny default ends in a deny rule
+// matching all (ipv4) sources since rules must not be "any" at once
+func (p *Policy) Translate(inbound, outbound, forward string) []Rule {
+	var rules []Rule
+	for n, r := range p.Rules {
+		switch {
+		case p.shadows(n):
+			rules = append(rules, r)
+		case r.Zone == "inbound" && r.Action == inbound:
+			p.skip("rules", "%s %s rule is redundant with the inbound default", r.Action, r.String())
+		case r.Zone == "outbound" && r.Action == outbound:
+			p.skip("rules", "%s %s rule is redundant with the outbound default", r.Action, r.String())
+		case r.Zone == "any" && r.Action == inbound && r.Action == outbound:
+			p.skip("rules", "%s %s rule is redundant with both defaults", r.Action, r.String())
+		default:
+			rules = append(rules, r)
+		}
+	}
+	for _, d := range []struct{ zone, action string }{{"inbound", inbound}, {"outbound", outbound}, {"forward", forward}} {
+		if d.action == "deny" {
+			rules = append(rules, Rule{Action: "deny", Zone: d.zone, FromIP: "0.0.0.0/0", FromPort: "any", ToIP: "any", ToPort: "any"})
+		}
+	}
+	return rules
+}
+
+//(*Policy).shadows : check if the rule at the given index takes precedence over a later rule
+// of an overlapping direction with another action
+func (p *Policy) shadows(index int) bool {
+	r := p.Rules[index]
+	for _, later := range p.Rules[index+1:] {
+		if later.Action != r.Action && (later.Zone == r.Zone || later.Zone == "any" || r.Zone == "any") {
+			return true
+		}
+	}
+	return false
+}
+
+//(Rule).String : describe the rule
+func (r Rule) String() string {
+	return fmt.Sprintf("zone=%s src=%s:%s dst=%s:%s", r.Zone, r.FromIP, r.FromPort, r.ToIP, r.ToPort)
+}
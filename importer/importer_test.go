@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/***Functions***/
+
+//parseFixture : parse the given fixture file with the given parser
+func parseFixture(t *testing.T, parse func(io.Reader, string, *Policy) error, path string, p *Policy) {
+	f, err := os.Open(filepath.Join("testdata", path))
+	if err != nil {
+		t.Fatalf("Unable to open fixture: %s\n", err.Error())
+	}
+	defer f.Close()
+	if err = parse(f, path, p); err != nil {
+		t.Fatalf("Unable to parse fixture %q: %s\n", path, err.Error())
+	}
+}
+
+//hasSkipped : check if skipped construct was reported for the given source
+func hasSkipped(p *Policy, source, message string) bool {
+	for _, s := range p.Skipped {
+		if strings.HasPrefix(s, source+": ") && strings.Contains(s, message) {
+			return true
+		}
+	}
+	return false
+}
+
+/***Unit-Tests***/
+
+func TestImportUFW(t *testing.T) {
+	p := new(Policy)
+	parseFixture(t, ParseUFWDefaults, "ufw/ufw", p)
+	parseFixture(t, ParseUFWRules, "ufw/user.rules", p)
+	parseFixture(t, ParseUFWRules, "ufw/user6.rules", p)
+	// check defaults
+	if p.Inbound != "deny" || p.Outbound != "allow" || p.Forward != "deny" {
+		t.Fatalf("Unexpected defaults: inbound=%q outbound=%q forward=%q\n", p.Inbound, p.Outbound, p.Forward)
+	}
+	// check rules
+	expected := []Rule{
+		{Action: "allow", Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22"},
+		{Action: "allow", Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "80"},
+		{Action: "allow", Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "443"},
+		{Action: "allow", Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "2222"},
+		{Action: "deny", Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "25"},
+	}
+	if !reflect.DeepEqual(p.Rules, expected) {
+		t.Fatalf("Unexpected rules:\n%v\nexpected:\n%v\n", p.Rules, expected)
+	}
+	if !reflect.DeepEqual(p.Blacklist, []string{"203.0.113.7"}) {
+		t.Fatalf("Unexpected blacklist: %v\n", p.Blacklist)
+	}
+	// check untranslatable constructs are reported
+	for _, skip := range []struct{ source, message string }{
+		{"ufw/user.rules:20", "protocol \"tcp\""},
+		{"ufw/user.rules:29", "rate limit"},
+		{"ufw/user.rules:34", "interface specific direction \"in_eth0\""},
+		{"ufw/user.rules:40", "routed rule"},
+		{"ufw/user6.rules:9", "ipv6 address \"2001:db8::1\""},
+	} {
+		if !hasSkipped(p, skip.source, skip.message) {
+			t.Fatalf("Missing skipped construct %s: %s\n%v\n", skip.source, skip.message, p.Skipped)
+		}
+	}
+	// check rules agreeing with the defaults are reported as redundant and deny defaults end in a deny rule
+	if rules := p.Translate(p.Inbound, p.Outbound, p.Forward); len(rules) != 7 || rules[5].Zone != "inbound" || rules[6].Zone != "forward" {
+		t.Fatalf("Unexpected translated rules: %v\n", rules)
+	}
+	if rules := p.Translate("allow", p.Outbound, "allow"); len(rules) != 1 {
+		t.Fatalf("Unexpected translated rules: %v\n", rules)
+	}
+	if !hasSkipped(p, "rules", "redundant with the inbound default") {
+		t.Fatalf("Missing redundant rule report: %v\n", p.Skipped)
+	}
+}
+
+func TestImportIPTablesSave(t *testing.T) {
+	p := new(Policy)
+	parseFixture(t, ParseIPTablesSave, "iptables.rules", p)
+	// check defaults
+	if p.Inbound != "deny" || p.Outbound != "allow" || p.Forward != "deny" {
+		t.Fatalf("Unexpected defaults: inbound=%q outbound=%q forward=%q\n", p.Inbound, p.Outbound, p.Forward)
+	}
+	// check rules
+	expected := []Rule{
+		{Action: "allow", Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22"},
+		{Action: "allow", Zone: "inbound", FromIP: "192.168.1.0/24", FromPort: "any", ToIP: "any", ToPort: "80"},
+		{Action: "allow", Zone: "inbound", FromIP: "192.168.1.0/24", FromPort: "any", ToIP: "any", ToPort: "443"},
+		{Action: "deny", Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "192.0.2.0/24", ToPort: "5000-5010"},
+	}
+	if !reflect.DeepEqual(p.Rules, expected) {
+		t.Fatalf("Unexpected rules:\n%v\nexpected:\n%v\n", p.Rules, expected)
+	}
+	if !reflect.DeepEqual(p.Blacklist, []string{"198.51.100.23"}) {
+		t.Fatalf("Unexpected blacklist: %v\n", p.Blacklist)
+	}
+	// check untranslatable constructs are reported
+	for _, skip := range []struct{ source, message string }{
+		{"iptables.rules:7", "table \"nat\""},
+		{"iptables.rules:16", "match extension \"conntrack\""},
+		{"iptables.rules:20", "argument \"-i\""},
+		{"iptables.rules:21", "target \"LOGDROP\""},
+		{"iptables.rules:22", "reject translated to deny"},
+		{"iptables.rules:23", "chain \"LOGDROP\""},
+	} {
+		if !hasSkipped(p, skip.source, skip.message) {
+			t.Fatalf("Missing skipped construct %s: %s\n%v\n", skip.source, skip.message, p.Skipped)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/***Functions***/
+
+//splitArgs : split iptables-save rule into arguments while respecting quotes
+func splitArgs(line string) (args []string) {
+	var (
+		arg    []rune
+		quoted bool
+	)
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if len(arg) > 0 {
+				args = append(args, string(arg))
+				arg = arg[:0]
+			}
+		default:
+			arg = append(arg, r)
+		}
+	}
+	if len(arg) > 0 {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+//ParseIPTablesSave : parse iptables-save dump into policy defaults and rules
+func ParseIPTablesSave(r io.Reader, name string, p *Policy) error {
+	var table string
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		source := fmt.Sprintf("%s:%d", name, lineno)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || line == "COMMIT":
+			continue
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+		case table != "filter":
+			p.skip(source, "table %q is not supported", table)
+		case strings.HasPrefix(line, ":"):
+			parseIPTablesChain(source, strings.Fields(line[1:]), p)
+		case strings.HasPrefix(line, "-A "):
+			parseIPTablesRule(source, splitArgs(line[3:]), p)
+		default:
+			p.skip(source, "unknown statement %q", line)
+		}
+	}
+	return scanner.Err()
+}
+
+//parseIPTablesChain : parse chain declaration and collect defaults from builtin chains
+func parseIPTablesChain(source string, fields []string, p *Policy) {
+	if len(fields) < 2 || fields[1] == "-" {
+		return
+	}
+	dfault, err := convertUFWPolicy(fields[1])
+	if err != nil {
+		p.skip(source, "%s", err)
+		return
+	}
+	switch fields[0] {
+	case "INPUT":
+		p.Inbound = dfault
+	case "OUTPUT":
+		p.Outbound = dfault
+	case "FORWARD":
+		p.Forward = dfault
+	default:
+		if dfault != "deny" {
+			p.skip(source, "policy of chain %q is not supported", fields[0])
+		}
+	}
+}
+
+//parseIPTablesRule : parse rule arguments appended to a chain
+func parseIPTablesRule(source string, args []string, p *Policy) {
+	rule := Rule{}
+	// collect zone from the chain
+	switch args[0] {
+	case "INPUT":
+		rule.Zone = "inbound"
+	case "OUTPUT":
+		rule.Zone = "outbound"
+	default:
+		p.skip(source, "rule in chain %q is not supported", args[0])
+		return
+	}
+	var (
+		err            error
+		proto          string
+		sports, dports = "any", "any"
+		src, dst       = "any", "any"
+	)
+	for i := 1; i < len(args); i++ {
+		// collect the value following the argument
+		value := ""
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		switch args[i] {
+		case "-s", "--source":
+			src, i = value, i+1
+		case "-d", "--destination":
+			dst, i = value, i+1
+		case "-p", "--protocol":
+			proto, i = value, i+1
+		case "--sport", "--source-port", "--sports", "--source-ports":
+			sports, i = value, i+1
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			dports, i = value, i+1
+		case "-m", "--match":
+			switch value {
+			case "tcp", "udp", "multiport", "comment":
+			default:
+				p.skip(source, "match extension %q is not supported", value)
+				return
+			}
+			i++
+		case "--comment", "--reject-with":
+			i++
+		case "-j", "--jump":
+			switch value {
+			case "ACCEPT":
+				rule.Action = "allow"
+			case "DROP":
+				rule.Action = "deny"
+			case "REJECT":
+				p.skip(source, "reject translated to deny")
+				rule.Action = "deny"
+			default:
+				p.skip(source, "target %q is not supported", value)
+				return
+			}
+			i++
+		default:
+			p.skip(source, "argument %q is not supported", args[i])
+			return
+		}
+	}
+	if rule.Action == "" {
+		p.skip(source, "rule without a target is not supported")
+		return
+	}
+	// collect addresses
+	if rule.FromIP, err = convertAddr(src); err != nil {
+		p.skip(source, "%s", err)
+		return
+	}
+	if rule.ToIP, err = convertAddr(dst); err != nil {
+		p.skip(source, "%s", err)
+		return
+	}
+	if proto != "" && proto != "all" {
+		p.skip(source, "protocol %q restriction is not supported, rule applies to all protocols", proto)
+	}
+	p.addRule(source, rule, convertPorts(sports), convertPorts(dports))
+}
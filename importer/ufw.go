@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/***Variables***/
+
+//ufwTuplePrefix : prefix of the comment ufw uses to store rules in user.rules/user6.rules
+const ufwTuplePrefix = "### tuple ### "
+
+/***Functions***/
+
+//convertUFWPolicy : convert ufw/iptables policy to goaway default
+func convertUFWPolicy(policy string) (string, error) {
+	switch strings.ToUpper(policy) {
+	case "ACCEPT":
+		return "allow", nil
+	case "DROP", "REJECT":
+		return "deny", nil
+	default:
+		return "", fmt.Errorf("unknown policy %q", policy)
+	}
+}
+
+//ParseUFWDefaults : parse ufw defaults file (/etc/default/ufw) into policy defaults
+func ParseUFWDefaults(r io.Reader, name string, p *Policy) error {
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, "=") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		value := strings.Trim(kv[1], `"'`)
+		source := fmt.Sprintf("%s:%d", name, lineno)
+		switch kv[0] {
+		case "DEFAULT_INPUT_POLICY":
+			dfault, err := convertUFWPolicy(value)
+			if err != nil {
+				p.skip(source, "%s", err)
+				continue
+			}
+			p.Inbound = dfault
+		case "DEFAULT_OUTPUT_POLICY":
+			dfault, err := convertUFWPolicy(value)
+			if err != nil {
+				p.skip(source, "%s", err)
+				continue
+			}
+			p.Outbound = dfault
+		case "DEFAULT_FORWARD_POLICY":
+			dfault, err := convertUFWPolicy(value)
+			if err != nil {
+				p.skip(source, "%s", err)
+				continue
+			}
+			p.Forward = dfault
+		}
+	}
+	return scanner.Err()
+}
+
+//ParseUFWRules : parse ufw rules file (/etc/ufw/user.rules or user6.rules) into policy rules
+func ParseUFWRules(r io.Reader, name string, p *Policy) error {
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, ufwTuplePrefix) {
+			continue
+		}
+		parseUFWTuple(fmt.Sprintf("%s:%d", name, lineno), strings.Fields(line[len(ufwTuplePrefix):]), p)
+	}
+	return scanner.Err()
+}
+
+//parseUFWTuple : parse ufw rule tuple
+// tuples are formatted as: action proto dport dst sport src [dapp sapp] direction
+func parseUFWTuple(source string, fields []string, p *Policy) {
+	if len(fields) != 7 && len(fields) != 9 {
+		p.skip(source, "unknown tuple format %q", strings.Join(fields, " "))
+		return
+	}
+	action, proto, dport, dst, sport, src := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+	direction := fields[len(fields)-1]
+	// collect action
+	if strings.HasPrefix(action, "route:") {
+		p.skip(source, "routed rule %q is not supported", action)
+		return
+	}
+	if i := strings.Index(action, "_log"); i >= 0 {
+		p.skip(source, "logging of %s rule is not supported", action[:i])
+		action = action[:i]
+	}
+	rule := Rule{}
+	switch action {
+	case "allow":
+		rule.Action = "allow"
+	case "deny":
+		rule.Action = "deny"
+	case "reject":
+		p.skip(source, "reject translated to deny")
+		rule.Action = "deny"
+	case "limit":
+		p.skip(source, "rate limit translated to allow")
+		rule.Action = "allow"
+	default:
+		p.skip(source, "unknown action %q", action)
+		return
+	}
+	// collect direction
+	switch direction {
+	case "in":
+		rule.Zone = "inbound"
+	case "out":
+		rule.Zone = "outbound"
+	default:
+		p.skip(source, "interface specific direction %q is not supported", direction)
+		return
+	}
+	// collect addresses
+	var err error
+	if rule.FromIP, err = convertAddr(src); err != nil {
+		p.skip(source, "%s", err)
+		return
+	}
+	if rule.ToIP, err = convertAddr(dst); err != nil {
+		p.skip(source, "%s", err)
+		return
+	}
+	if proto != "any" {
+		p.skip(source, "protocol %q restriction is not supported, rule applies to all protocols", proto)
+	}
+	p.addRule(source, rule, convertPorts(sport), convertPorts(dport))
+}
@@ -1,11 +1,30 @@
 #!/usr/bin/env bash
 
-# NetFilterQueue Rules
+# NetFilterQueue Rules (see README.md for the queues and marks)
+# reset connections (0x6762) are rejected, new packets go to queue 0, dns answers to queue 5
+sudo iptables -A INPUT -m mark --mark 0x6762 -j ACCEPT
+sudo iptables -A INPUT -p tcp -m connmark --mark 0x6762 -j REJECT --reject-with tcp-reset
 sudo iptables -A INPUT -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=0
+sudo iptables -A INPUT -p udp --sport 53 -m conntrack --ctstate ESTABLISHED -j NFQUEUE --queue-num=5
 sudo iptables -A INPUT -m conntrack --ctstate ESTABLISHED -j ACCEPT
 
-sudo iptables -A OUTPUT -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=0
+# tarpit SYN-ACKs (0x6761) and resets (0x6762) pass, new packets go to queue 1, flow data to queue 6
+sudo iptables -A OUTPUT -m mark --mark 0x6761 -j ACCEPT
+sudo iptables -A OUTPUT -m mark --mark 0x6762 -j CONNMARK --set-mark 0x6762
+sudo iptables -A OUTPUT -m mark --mark 0x6762 -j ACCEPT
+sudo iptables -A OUTPUT -p tcp -m connmark --mark 0x6762 -j REJECT --reject-with tcp-reset
+sudo iptables -A OUTPUT -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=1
+sudo iptables -A OUTPUT -p tcp -m conntrack --ctstate ESTABLISHED --ctdir ORIGINAL -m connbytes --connbytes 2:20 --connbytes-dir original --connbytes-mode packets -j NFQUEUE --queue-num=6
 sudo iptables -A OUTPUT -m conntrack --ctstate ESTABLISHED -j ACCEPT
 
-sudo iptables -A FORWARD -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=0
-sudo iptables -A FORWARD -m conntrack --ctstate ESTABLISHED -j ACCEPT
\ No newline at end of file
+# new packets go to queue 2, flow data to queue 7
+sudo iptables -A FORWARD -p tcp -m connmark --mark 0x6762 -j REJECT --reject-with tcp-reset
+sudo iptables -A FORWARD -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=2
+sudo iptables -A FORWARD -p tcp -m conntrack --ctstate ESTABLISHED --ctdir ORIGINAL -m connbytes --connbytes 2:20 --connbytes-dir original --connbytes-mode packets -j NFQUEUE --queue-num=7
+sudo iptables -A FORWARD -m conntrack --ctstate ESTABLISHED -j ACCEPT
+
+# Interface Zones (optional, queue 3 with InIface: "eth1")
+# sudo iptables -I INPUT -i eth1 -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=3
+# Router Mode (optional, queue 4 with InIface: "eth1" and OutIface: "eth0")
+# sudo sysctl -w net.ipv4.ip_forward=1
+# sudo iptables -I FORWARD -i eth1 -o eth0 -m conntrack --ctstate NEW,RELATED,INVALID -j NFQUEUE --queue-num=4
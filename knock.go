@@ -0,0 +1,302 @@
+package goaway2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/***Variables***/
+
+//knock limits
+const (
+	minKnockSteps  = 2
+	maxKnockSteps  = 16
+	knockSweepTime = time.Minute // minimum time between sweeps of expired progress and openings
+)
+
+//KnockPort : port and protocol (tcp/udp) knocked or opened by a knock profile
+type KnockPort struct {
+	Port     int64
+	Protocol string // lowercase tcp/udp
+}
+
+//KnockProfile : sequence of ports that opens a target port for the source knocking them in order
+type KnockProfile struct {
+	Name     string
+	Sequence []KnockPort
+	Window   time.Duration // time the whole sequence must be knocked within
+	Target   KnockPort
+	Duration time.Duration // time the target port stays open for new connections of the source
+}
+
+//KnockResult : effect of an inbound packet on the knock and spa profiles
+type KnockResult struct {
+	Guard     string // profile guarding the destination port of the packet (blank if unguarded)
+	Open      bool   // the port was opened for the source by a knock sequence or spa packet
+	Completed string // profile whose sequence the packet completed or whose spa packet it is (blank if none)
+	Rejected  string // reason a spa packet was rejected (blank if accepted or no spa packet)
+}
+
+//Knocker : tracks the knock progress of every source and the ports opened by completed sequences
+// and single packet authorizations
+type Knocker struct {
+	profiles []KnockProfile
+	spas     []SPAProfile
+
+	lock     sync.Mutex
+	progress map[knockSource]*knockProgress
+	open     map[knockGrant]time.Time // expiry of the ports opened for a source
+	nonces   map[string]time.Time     // expiry of the accepted spa nonces
+	swept    time.Time
+}
+
+//knockGrant : port opened for a source-ip
+type knockGrant struct {
+	ip   string
+	port KnockPort
+}
+
+//knockSource : knocking source-ip and the index of the profile it is knocking
+type knockSource struct {
+	ip      string
+	profile int
+}
+
+//knockProgress : sequence steps a source knocked and when it knocked the first one
+type knockProgress struct {
+	step    int
+	started time.Time
+}
+
+/***Functions***/
+
+//NewKnocker : create knocker for the given knock and spa profiles without any progress
+func NewKnocker(profiles []KnockProfile, spas []SPAProfile) *Knocker {
+	return &Knocker{
+		profiles: profiles,
+		spas:     spas,
+		progress: make(map[knockSource]*knockProgress),
+		open:     make(map[knockGrant]time.Time),
+		nonces:   make(map[string]time.Time),
+	}
+}
+
+//ParseKnockPort : convert port/protocol (22/tcp) into a knock port
+func ParseKnockPort(value string) (KnockPort, error) {
+	fields := strings.Split(value, "/")
+	if len(fields) != 2 {
+		return KnockPort{}, fmt.Errorf("invalid knock port: %q (e.g. 22/tcp)", value)
+	}
+	port, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || port < 1 || port > 65535 {
+		return KnockPort{}, fmt.Errorf("invalid knock port: %q (e.g. 22/tcp)", value)
+	}
+	protocol := strings.ToLower(fields[1])
+	if protocol != "tcp" && protocol != "udp" {
+		return KnockPort{}, fmt.Errorf("invalid knock protocol: %q (tcp/udp)", fields[1])
+	}
+	return KnockPort{Port: port, Protocol: protocol}, nil
+}
+
+//ParseKnockSequence : convert comma separated knock ports (7000/tcp,8000/udp,9000/tcp) into a sequence
+func ParseKnockSequence(value string) ([]KnockPort, error) {
+	sequence, err := ParseKnockAccess(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(sequence) < minKnockSteps || len(sequence) > maxKnockSteps {
+		return nil, fmt.Errorf("knock sequence must have %d to %d ports, got %d", minKnockSteps, maxKnockSteps, len(sequence))
+	}
+	return sequence, nil
+}
+
+//ParseKnockAccess : convert comma separated ports (22/tcp,443/tcp) a spa profile grants access to
+func ParseKnockAccess(value string) ([]KnockPort, error) {
+	var access []KnockPort
+	for _, field := range strings.Split(value, ",") {
+		port, err := ParseKnockPort(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		access = append(access, port)
+	}
+	return access, nil
+}
+
+//FormatKnockPorts : describe knock sequence/spa access as comma separated knock ports
+func FormatKnockPorts(sequence []KnockPort) string {
+	ports := make([]string, len(sequence))
+	for i, p := range sequence {
+		ports[i] = p.String()
+	}
+	return strings.Join(ports, ",")
+}
+
+/***Methods***/
+
+//(KnockPort).String : describe knock port as port/protocol
+func (p KnockPort) String() string {
+	return strconv.FormatInt(p.Port, 10) + "/" + p.Protocol
+}
+
+//(KnockPort).matches : check if packet is sent to the knock port
+func (p KnockPort) matches(pkt *PacketData) bool {
+	return pkt.DstPort == p.Port && strings.EqualFold(pkt.Protocol, p.Protocol)
+}
+
+//(*Knocker).Observe : advance the knock progress of the packets source, verify spa packets and
+// report whether the destination port is guarded by a profile and opened for the source
+// steps must be knocked in order, a repeated (retransmitted) knock of the last step is ignored
+// and knocking any other step of the sequence starts over
+func (k *Knocker) Observe(pkt *PacketData, now time.Time) (r KnockResult) {
+	if k == nil || len(k.profiles)+len(k.spas) == 0 {
+		return r
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.sweep(now)
+	// spa ports stay closed, valid packets open the requested port
+	for i := range k.spas {
+		if spa := &k.spas[i]; pkt.DstPort == spa.Port && strings.EqualFold(pkt.Protocol, "udp") {
+			r.Guard = spa.Name
+			if err := k.authorize(spa, pkt, now); err != nil {
+				r.Rejected = err.Error()
+			} else {
+				r.Completed = spa.Name
+			}
+			return r
+		}
+	}
+	if guard, ok := k.guard(pkt); ok {
+		expires, ok := k.open[knockGrant{ip: pkt.SrcIP, port: guard}]
+		r.Open = ok && now.Before(expires)
+		r.Guard = k.guardName(guard)
+		return r
+	}
+	for i, profile := range k.profiles {
+		src := knockSource{ip: pkt.SrcIP, profile: i}
+		p := k.progress[src]
+		if p != nil && now.Sub(p.started) > profile.Window {
+			delete(k.progress, src)
+			p = nil
+		}
+		step := 0
+		if p != nil {
+			step = p.step
+		}
+		switch {
+		case profile.Sequence[step].matches(pkt):
+			if p == nil {
+				p = &knockProgress{started: now}
+				k.progress[src] = p
+			}
+			p.step++
+			if p.step == len(profile.Sequence) {
+				delete(k.progress, src)
+				k.open[knockGrant{ip: pkt.SrcIP, port: profile.Target}] = now.Add(profile.Duration)
+				r.Completed = profile.Name
+			}
+		case step > 0 && profile.Sequence[step-1].matches(pkt):
+		case profile.Sequence[0].matches(pkt):
+			k.progress[src] = &knockProgress{step: 1, started: now}
+		default:
+			for _, port := range profile.Sequence {
+				if port.matches(pkt) {
+					delete(k.progress, src)
+					break
+				}
+			}
+		}
+	}
+	return r
+}
+
+//(*Knocker).guard : return the port guarded by a knock/spa profile the packet is sent to (false if unguarded)
+func (k *Knocker) guard(pkt *PacketData) (KnockPort, bool) {
+	for _, profile := range k.profiles {
+		if profile.Target.matches(pkt) {
+			return profile.Target, true
+		}
+	}
+	for _, spa := range k.spas {
+		for _, port := range spa.Access {
+			if port.matches(pkt) {
+				return port, true
+			}
+		}
+	}
+	return KnockPort{}, false
+}
+
+//(*Knocker).guardName : return the names of the profiles guarding a port
+func (k *Knocker) guardName(port KnockPort) string {
+	var names []string
+	for _, profile := range k.profiles {
+		if profile.Target == port {
+			names = append(names, profile.Name)
+		}
+	}
+	for _, spa := range k.spas {
+		for _, access := range spa.Access {
+			if access == port {
+				names = append(names, spa.Name)
+			}
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+//(*Knocker).authorize : verify spa packet and open the requested port (lock must be held)
+// each nonce is accepted once while its timestamp is within the maximum age
+func (k *Knocker) authorize(spa *SPAProfile, pkt *PacketData, now time.Time) error {
+	req, err := DecodeSPA(spa.Key, pkt.Data)
+	if err != nil {
+		return err
+	}
+	if age := now.Sub(req.Timestamp); age > spa.MaxAge || age < -spa.MaxAge {
+		return fmt.Errorf("timestamp is %s off", age.Round(time.Second))
+	}
+	if _, ok := k.nonces[req.Nonce]; ok {
+		return fmt.Errorf("replayed nonce")
+	}
+	allowed := false
+	for _, port := range spa.Access {
+		allowed = allowed || port == req.Access
+	}
+	if !allowed {
+		return fmt.Errorf("access to %s is not allowed", req.Access)
+	}
+	k.nonces[req.Nonce] = req.Timestamp.Add(spa.MaxAge)
+	ip := req.AllowIP
+	if ip == "" {
+		ip = pkt.SrcIP
+	}
+	k.open[knockGrant{ip: ip, port: req.Access}] = now.Add(spa.Duration)
+	return nil
+}
+
+//(*Knocker).sweep : drop expired progress and openings (lock must be held)
+func (k *Knocker) sweep(now time.Time) {
+	if now.Sub(k.swept) < knockSweepTime {
+		return
+	}
+	k.swept = now
+	for src, p := range k.progress {
+		if now.Sub(p.started) > k.profiles[src.profile].Window {
+			delete(k.progress, src)
+		}
+	}
+	for grant, expires := range k.open {
+		if !now.Before(expires) {
+			delete(k.open, grant)
+		}
+	}
+	for nonce, expires := range k.nonces {
+		if now.After(expires) {
+			delete(k.nonces, nonce)
+		}
+	}
+}
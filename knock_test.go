@@ -0,0 +1,136 @@
+package goaway2
+
+import (
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Variables***/
+
+//knockStep : packet of a replayed sequence and the expected knock result
+type knockStep struct {
+	src    string
+	port   int64
+	proto  string
+	after  time.Duration // time since the start of the replay
+	result KnockResult
+}
+
+/***Functions***/
+
+//replayKnocks : send the steps to the knocker and ensure each has the expected result
+func replayKnocks(t *testing.T, k *Knocker, steps []knockStep) {
+	start := time.Now()
+	for n, step := range steps {
+		pkt := &PacketData{SrcIP: step.src, SrcPort: 40000 + int64(n), DstIP: "192.168.200.114", DstPort: step.port, Protocol: step.proto, Hook: HookInput}
+		if r := k.Observe(pkt, start.Add(step.after)); r != step.result {
+			t.Fatalf("Unexpected result of step %d (%s -> %d/%s): %+v\n", n, step.src, step.port, step.proto, r)
+		}
+	}
+}
+
+/***Unit-Tests***/
+
+func TestKnockSequence(t *testing.T) {
+	sequence, err := ParseKnockSequence("7000/tcp,8000/UDP,9000/tcp")
+	if err != nil {
+		t.Fatalf("Unable to parse knock sequence: %s\n", err.Error())
+	}
+	if s := FormatKnockPorts(sequence); s != "7000/tcp,8000/udp,9000/tcp" {
+		t.Fatalf("Unexpected knock sequence: %s\n", s)
+	}
+	for _, bad := range []string{"7000/tcp", "7000/tcp,8000", "7000/icmp,8000/tcp", "0/tcp,8000/tcp", "7000/tcp,,8000/tcp"} {
+		if _, err = ParseKnockSequence(bad); err == nil {
+			t.Fatalf("Able to parse invalid knock sequence: %q\n", bad)
+		}
+	}
+	k := NewKnocker([]KnockProfile{{
+		Name:     "ssh",
+		Sequence: sequence,
+		Window:   10 * time.Second,
+		Target:   KnockPort{Port: 22, Protocol: "tcp"},
+		Duration: 5 * time.Minute,
+	}}, nil)
+	closed, open := KnockResult{Guard: "ssh"}, KnockResult{Guard: "ssh", Open: true}
+	const a, b = "198.51.100.7", "203.0.113.5"
+	replayKnocks(t, k, []knockStep{
+		{a, 22, "TCP", 0, closed},
+		// unrelated ports, protocols and sources do not disturb the sequence
+		{a, 7000, "TCP", time.Second, KnockResult{}},
+		{a, 443, "TCP", time.Second, KnockResult{}},
+		{a, 8000, "TCP", time.Second, KnockResult{}},
+		{b, 8000, "UDP", time.Second, KnockResult{}},
+		{a, 8000, "UDP", 2 * time.Second, KnockResult{}},
+		// retransmitted knocks are ignored
+		{a, 8000, "UDP", 3 * time.Second, KnockResult{}},
+		{a, 9000, "TCP", 4 * time.Second, KnockResult{Completed: "ssh"}},
+		{a, 22, "TCP", 5 * time.Second, open},
+		{b, 22, "TCP", 5 * time.Second, closed},
+		{a, 22, "UDP", 5 * time.Second, KnockResult{}},
+		// openings expire after their duration
+		{a, 22, "TCP", 5*time.Minute + 4*time.Second, closed},
+		// knocks out of order start over
+		{b, 7000, "TCP", 6 * time.Minute, KnockResult{}},
+		{b, 9000, "TCP", 6 * time.Minute, KnockResult{}},
+		{b, 8000, "UDP", 6 * time.Minute, KnockResult{}},
+		{b, 22, "TCP", 6 * time.Minute, closed},
+		// knocking the first port again restarts the sequence
+		{b, 7000, "TCP", 7 * time.Minute, KnockResult{}},
+		{b, 7000, "TCP", 7*time.Minute + time.Second, KnockResult{}},
+		{b, 8000, "UDP", 7*time.Minute + time.Second, KnockResult{}},
+		{b, 9000, "TCP", 7*time.Minute + 2*time.Second, KnockResult{Completed: "ssh"}},
+		{b, 22, "TCP", 7*time.Minute + 3*time.Second, open},
+		// sequences have to be completed within the window
+		{a, 7000, "TCP", 8 * time.Minute, KnockResult{}},
+		{a, 8000, "UDP", 8*time.Minute + 5*time.Second, KnockResult{}},
+		{a, 9000, "TCP", 8*time.Minute + 11*time.Second, KnockResult{}},
+		{a, 22, "TCP", 8*time.Minute + 12*time.Second, closed},
+	})
+	var none *Knocker
+	if r := none.Observe(&PacketData{SrcIP: a, DstPort: 22, Protocol: "TCP"}, time.Now()); r != (KnockResult{}) {
+		t.Fatalf("Unexpected result without knocker: %+v\n", r)
+	}
+}
+
+func TestFirewallKnock(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	// the inbound default allows everything but the guarded port
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AddKnock(store.Knock{Name: "ssh", Sequence: "7000/tcp,8000/udp,9000/tcp", Window: 10, Target: "22/tcp", Duration: 300})
+	st.AddKnock(store.Knock{Name: "broken", Sequence: "7000/tcp", Window: 10, Target: "23/tcp", Duration: 300})
+	fw := newFirewall(st, profiles.NewSet())
+	kv := NewRedBlackKV()
+	decide := func(src string, port int64, proto, hook string) Decision {
+		return fw.Decide(kv, &PacketData{SrcIP: src, SrcPort: 40000, DstIP: "192.168.200.114", DstPort: port, Protocol: proto, Hook: hook})
+	}
+	if d := decide("198.51.100.7", 22, "TCP", HookInput); d.Verdict != netfilter.NF_DROP || d.Reason != "knock" || d.Knock != "ssh" {
+		t.Fatalf("Guarded port was not closed: %+v\n", d)
+	}
+	// outbound packets and unparsable profiles are not guarded
+	if d := decide("198.51.100.7", 22, "TCP", HookOutput); d.Reason == "knock" {
+		t.Fatalf("Outbound packet was guarded: %+v\n", d)
+	}
+	if d := decide("198.51.100.7", 23, "TCP", HookInput); d.Reason == "knock" {
+		t.Fatalf("Port of an invalid profile was guarded: %+v\n", d)
+	}
+	decide("198.51.100.7", 7000, "TCP", HookInput)
+	decide("198.51.100.7", 8000, "UDP", HookInput)
+	if d := decide("198.51.100.7", 9000, "TCP", HookInput); d.Knocked != "ssh" || d.Reason != "default" {
+		t.Fatalf("Unexpected decision of the last knock: %+v\n", d)
+	}
+	if d := decide("198.51.100.7", 22, "TCP", HookInput); d.Verdict != netfilter.NF_ACCEPT || d.Reason != "knock" {
+		t.Fatalf("Knocked port was not opened: %+v\n", d)
+	}
+	if d := decide("203.0.113.5", 22, "TCP", HookInput); d.Verdict != netfilter.NF_DROP || d.Reason != "knock" {
+		t.Fatalf("Knocked port was opened for another source: %+v\n", d)
+	}
+}
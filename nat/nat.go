@@ -0,0 +1,113 @@
+package nat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"goaway2/store"
+)
+
+/***Variables***/
+
+const (
+	// PreroutingChain : nat chain holding the port forwards (jumped to from PREROUTING)
+	PreroutingChain = "GOAWAY-PREROUTING"
+	// PostroutingChain : nat chain holding the masquerades (jumped to from POSTROUTING)
+	PostroutingChain = "GOAWAY-POSTROUTING"
+)
+
+//Backend : installs the nat configuration into the kernel
+type Backend interface {
+	Install(n store.NAT) error
+}
+
+//IPTables : backend installing the nat configuration using iptables-restore
+// (works with both the legacy and the nft variant of iptables)
+type IPTables struct {
+	Restore string // iptables-restore binary (default: iptables-restore)
+	Command string // iptables binary (default: iptables)
+	// run executes the given binary with stdin (replaced within tests)
+	run func(stdin io.Reader, name string, args ...string) error
+}
+
+/***Functions***/
+
+//Apply : install the nat configuration stored within the database using the given backend
+func Apply(st *store.Store, b Backend) error {
+	n, err := st.NAT()
+	if err != nil {
+		return err
+	}
+	return b.Install(n)
+}
+
+//Script : return iptables-restore input that replaces the contents of goaway's nat chains
+func Script(n store.NAT) string {
+	var buf bytes.Buffer
+	buf.WriteString("*nat\n")
+	// declaring the chains flushes them when restoring with --noflush
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", PreroutingChain)
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", PostroutingChain)
+	for _, f := range n.Forwards {
+		to := f.ToIP
+		if f.ToPort != "" {
+			to += ":" + f.ToPort
+		}
+		fmt.Fprintf(
+			&buf, "-A %s -i %s -p %s -m %s --dport %s -j DNAT --to-destination %s\n",
+			PreroutingChain, f.Interface, f.Protocol, f.Protocol, strings.Replace(f.Port, "-", ":", 1), to,
+		)
+	}
+	for _, m := range n.Masquerades {
+		if m.Source == "any" || m.Source == "" {
+			fmt.Fprintf(&buf, "-A %s -o %s -j MASQUERADE\n", PostroutingChain, m.Interface)
+		} else {
+			fmt.Fprintf(&buf, "-A %s -o %s -s %s -j MASQUERADE\n", PostroutingChain, m.Interface, m.Source)
+		}
+	}
+	buf.WriteString("COMMIT\n")
+	return buf.String()
+}
+
+//runCommand : run binary with the given stdin and return its output within the error
+func runCommand(stdin io.Reader, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(bytes.TrimSpace(out)) > 0 {
+		return fmt.Errorf("%s: %s (%s)", name, err.Error(), bytes.TrimSpace(out))
+	}
+	return err
+}
+
+/***Methods***/
+
+//(*IPTables).Install : replace goaway's nat chains and make sure the builtin chains jump to them
+func (t *IPTables) Install(n store.NAT) error {
+	restore, command, run := t.Restore, t.Command, t.run
+	if restore == "" {
+		restore = "iptables-restore"
+	}
+	if command == "" {
+		command = "iptables"
+	}
+	if run == nil {
+		run = runCommand
+	}
+	if err := run(strings.NewReader(Script(n)), restore, "--noflush"); err != nil {
+		return err
+	}
+	for builtin, chain := range map[string]string{"PREROUTING": PreroutingChain, "POSTROUTING": PostroutingChain} {
+		// only insert the jump when it is not installed yet
+		if run(nil, command, "-t", "nat", "-C", builtin, "-j", chain) == nil {
+			continue
+		}
+		if err := run(nil, command, "-t", "nat", "-I", builtin, "-j", chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package nat
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"goaway2/store"
+)
+
+/***Unit-Tests***/
+
+func TestNATScript(t *testing.T) {
+	script := Script(store.NAT{
+		Forwards: []store.PortForward{
+			{Interface: "eth0", Protocol: "tcp", Port: "8080", ToIP: "10.0.1.5", ToPort: "80"},
+			{Interface: "eth0", Protocol: "udp", Port: "5000-5010", ToIP: "10.0.1.6"},
+		},
+		Masquerades: []store.Masquerade{
+			{Interface: "eth0", Source: "10.0.1.0/24"},
+			{Interface: "wg0", Source: "any"},
+		},
+	})
+	expected := `*nat
+:GOAWAY-PREROUTING - [0:0]
+:GOAWAY-POSTROUTING - [0:0]
+-A GOAWAY-PREROUTING -i eth0 -p tcp -m tcp --dport 8080 -j DNAT --to-destination 10.0.1.5:80
+-A GOAWAY-PREROUTING -i eth0 -p udp -m udp --dport 5000:5010 -j DNAT --to-destination 10.0.1.6
+-A GOAWAY-POSTROUTING -o eth0 -s 10.0.1.0/24 -j MASQUERADE
+-A GOAWAY-POSTROUTING -o wg0 -j MASQUERADE
+COMMIT
+`
+	if script != expected {
+		t.Fatalf("Unexpected script:\n%s\nexpected:\n%s\n", script, expected)
+	}
+}
+
+func TestNATInstall(t *testing.T) {
+	var calls []string
+	ipt := &IPTables{run: func(stdin io.Reader, name string, args ...string) error {
+		call := name + " " + strings.Join(args, " ")
+		if stdin != nil {
+			data, _ := ioutil.ReadAll(stdin)
+			call += " <" + strings.SplitN(string(data), "\n", 2)[0]
+		}
+		calls = append(calls, call)
+		// pretend the jump to the prerouting chain is already installed
+		if strings.Contains(call, "-C POSTROUTING") {
+			return errors.New("no such rule")
+		}
+		return nil
+	}}
+	if err := ipt.Install(store.NAT{}); err != nil {
+		t.Fatalf("Unable to install nat: %s\n", err.Error())
+	}
+	joined := strings.Join(calls, "\n")
+	if calls[0] != "iptables-restore --noflush <*nat" || len(calls) != 4 ||
+		!strings.Contains(joined, "iptables -t nat -I POSTROUTING -j GOAWAY-POSTROUTING") ||
+		strings.Contains(joined, "-I PREROUTING") {
+		t.Fatalf("Unexpected commands:\n%s\n", joined)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -21,6 +22,19 @@ type NetFilterQueue struct {
 	QueueNum     uint16
 	LogAllErrors bool
 	Logger       *log.Logger
+	// interfaces the queue is bound to by iptables (-i/-o) since go-netfilter-queue
+	// does not expose the nfqueue in/out device metadata (blank resolves from local addresses)
+	InIface  string
+	OutIface string
+	// netfilter hook (INPUT/OUTPUT/FORWARD) the queue is bound to by iptables, used as the
+	// direction of its packets (blank detects direction from local addresses)
+	Hook string
+	// dns cache learning the addresses of hostname rules from queued dns answers (nil to ignore them)
+	DNS *DNSCache
+	// procfs tree local packets are attributed to the user/process owning their socket with when
+	// an owner rule or a prompt needs it (blank skips the lookup, go-netfilter-queue does not expose
+	// the nfqueue UID/GID attributes)
+	Owners ProcFS
 
 	// queue handler objects
 	nfq      *netfilter.NFQueue
@@ -126,6 +140,17 @@ func (q *NetFilterQueue) parsePacket(packetin gopacket.Packet, packetout *Packet
 		tcp, _ := tcpLayer.(*layers.TCP)
 		packetout.SrcPort = int64(tcp.SrcPort)
 		packetout.DstPort = int64(tcp.DstPort)
+		packetout.Syn, packetout.Seq, packetout.Ack = tcp.SYN && !tcp.ACK, tcp.Seq, tcp.Ack
+		//get tls server name or http host from the first data packets
+		parsePayload(tcp.Payload, packetout)
+	}
+	//get src and dst from udp ports
+	udpLayer := packetin.Layer(layers.LayerTypeUDP)
+	if udpLayer != nil {
+		udp, _ := udpLayer.(*layers.UDP)
+		packetout.SrcPort = int64(udp.SrcPort)
+		packetout.DstPort = int64(udp.DstPort)
+		packetout.Data = udp.Payload
 	}
 }
 
@@ -136,8 +161,15 @@ func (q *NetFilterQueue) handlePacket(p netfilter.NFPacket) error {
 		dataPacket PacketData           //Reused parsed packet data as struct
 		redBlackKV            = &RBKV{} //Reused key/value pair for red black tree caches
 	)
+	// learn addresses from dns answers before judging packets to them
+	if q.DNS != nil {
+		q.DNS.Observe(p.Packet, time.Now())
+	}
 	// parse packet for required information
 	q.parsePacket(p.Packet, &dataPacket)
+	dataPacket.InIface, dataPacket.OutIface, dataPacket.Hook = q.InIface, q.OutIface, q.Hook
+	dataPacket.ResolveInterfaces()
+	dataPacket.owners = q.Owners
 	// complete logic go get verdict on packet and set verdict
 	p.SetVerdict(
 		q.Handler(q.Logger, redBlackKV, &dataPacket),
@@ -0,0 +1,202 @@
+package goaway2
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/***Variables***/
+
+//Owner : local user/process a packet was attributed to through the socket sending/receiving it
+type Owner struct {
+	UID int64  // user id the socket belongs to
+	GID int64  // effective group id of the owning process (-1 if no process was found)
+	PID int    // process holding the socket (0 if none was found)
+	Exe string // executable of the owning process (blank if no process was found)
+}
+
+//ProcFS : procfs tree sockets are attributed to their owners with
+// (go-netfilter-queue does not expose the nfqueue UID/GID attributes)
+type ProcFS string
+
+//SystemProcFS : procfs of the running system
+const SystemProcFS ProcFS = "/proc"
+
+//procInodesMax : socket inodes whose process is remembered at once (forgotten all at once when full)
+const procInodesMax = 4096
+
+//procInodes : processes found holding socket inodes, checked again on use since inodes are reused
+var procInodes = struct {
+	sync.Mutex
+	pids map[string]int
+}{pids: make(map[string]int)}
+
+/***Functions***/
+
+//parseProcAddr : parse hex address:port of /proc/net/{tcp,udp}[6] (addresses are in host byte-order words)
+func parseProcAddr(raw string) (string, int64, bool) {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	addr, err := hex.DecodeString(parts[0])
+	if err != nil || (len(addr) != net.IPv4len && len(addr) != net.IPv6len) {
+		return "", 0, false
+	}
+	port, err := strconv.ParseInt(parts[1], 16, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	// reverse each 32-bit word written in little-endian host order
+	for i := 0; i < len(addr); i += 4 {
+		addr[i], addr[i+1], addr[i+2], addr[i+3] = addr[i+3], addr[i+2], addr[i+1], addr[i]
+	}
+	return net.IP(addr).String(), port, true
+}
+
+//procAddrMatch : match socket address to packet address (unspecified socket addresses match any)
+func procAddrMatch(ip string, port int64, pktIP string, pktPort int64) bool {
+	return (ip == pktIP || ip == "0.0.0.0" || ip == "::") && (port == pktPort || port == 0)
+}
+
+/***Methods***/
+
+//(ProcFS).Owner : attribute local packet to the user/process owning its socket (nil if none was found)
+func (fs ProcFS) Owner(pkt *PacketData) *Owner {
+	var table string
+	switch pkt.Protocol {
+	case "TCP":
+		table = "tcp"
+	case "UDP":
+		table = "udp"
+	default:
+		return nil
+	}
+	// the local end of the socket is the source of outbound packets and the destination of inbound ones
+	localIP, localPort, remoteIP, remotePort := pkt.DstIP, pkt.DstPort, pkt.SrcIP, pkt.SrcPort
+	switch pkt.Direction() {
+	case "outbound":
+		localIP, localPort, remoteIP, remotePort = pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort
+	case "forward":
+		return nil
+	}
+	uid, inode, ok := fs.socket(table, localIP, localPort, remoteIP, remotePort)
+	if !ok {
+		return nil
+	}
+	owner := &Owner{UID: uid, GID: -1}
+	if pid, ok := fs.process(inode); ok {
+		owner.PID = pid
+		owner.GID = fs.gid(pid)
+		if exe, err := os.Readlink(filepath.Join(string(fs), strconv.Itoa(pid), "exe")); err == nil {
+			owner.Exe = strings.TrimSuffix(exe, " (deleted)")
+		}
+	}
+	return owner
+}
+
+//(ProcFS).socket : find the uid and inode of the socket bound to the local and remote address
+// preferring connected sockets over listening/unconnected ones
+func (fs ProcFS) socket(table, localIP string, localPort int64, remoteIP string, remotePort int64) (uid int64, inode string, ok bool) {
+	for _, name := range []string{table, table + "6"} {
+		f, err := os.Open(filepath.Join(string(fs), "net", name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // skip header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 {
+				continue
+			}
+			lip, lport, lok := parseProcAddr(fields[1])
+			rip, rport, rok := parseProcAddr(fields[2])
+			if !lok || !rok || !procAddrMatch(lip, lport, localIP, localPort) ||
+				!procAddrMatch(rip, rport, remoteIP, remotePort) || fields[9] == "0" {
+				continue
+			}
+			id, err := strconv.ParseInt(fields[7], 10, 64)
+			if err != nil {
+				continue
+			}
+			uid, inode, ok = id, fields[9], true
+			if rip == remoteIP && rport == remotePort {
+				f.Close()
+				return uid, inode, ok
+			}
+		}
+		f.Close()
+	}
+	return uid, inode, ok
+}
+
+//(ProcFS).process : find the process holding a file descriptor of the socket inode
+// (the process found last time is checked first, scanning every process is expensive)
+func (fs ProcFS) process(inode string) (int, bool) {
+	key, target := string(fs)+":"+inode, "socket:["+inode+"]"
+	procInodes.Lock()
+	pid, ok := procInodes.pids[key]
+	procInodes.Unlock()
+	if ok && fs.holds(strconv.Itoa(pid), target) {
+		return pid, true
+	}
+	entries, err := ioutil.ReadDir(string(fs))
+	if err != nil {
+		return 0, false
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() || !fs.holds(entry.Name(), target) {
+			continue
+		}
+		procInodes.Lock()
+		if len(procInodes.pids) >= procInodesMax {
+			procInodes.pids = make(map[string]int)
+		}
+		procInodes.pids[key] = pid
+		procInodes.Unlock()
+		return pid, true
+	}
+	return 0, false
+}
+
+//(ProcFS).holds : check if the process has a file descriptor linked to the target
+func (fs ProcFS) holds(pid, target string) bool {
+	fdDir := filepath.Join(string(fs), pid, "fd")
+	fds, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && link == target {
+			return true
+		}
+	}
+	return false
+}
+
+//(ProcFS).gid : return the effective group id of a process (-1 if unknown)
+func (fs ProcFS) gid(pid int) int64 {
+	data, err := ioutil.ReadFile(filepath.Join(string(fs), strconv.Itoa(pid), "status"))
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Gid: real effective saved filesystem
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "Gid:" {
+			if gid, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+				return gid
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,174 @@
+package goaway2
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Functions***/
+
+//fakeProcFS : build a procfs tree with a curl process connected to 93.184.216.34:443 and a
+// www-data daemon listening on udp port 53 (local address 192.168.200.114)
+func fakeProcFS(t *testing.T) ProcFS {
+	root := t.TempDir()
+	files := map[string]string{
+		"net/tcp": "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+			"   0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0\n" +
+			"   1: 72C8A8C0:9C40 22D8B85D:01BB 01 00000000:00000000 00:00000000 00000000  1000        0 2002 1 0000000000000000 20 4 30 10 -1\n",
+		"net/tcp6": "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+			"   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 3003 1 0000000000000000 100 0 0 10 0\n",
+		"net/udp": "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+			"   0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000    33        0 4004 2 0000000000000000 0\n",
+		"42/status": "Name:\tcurl\nUid:\t1000\t1000\t1000\t1000\nGid:\t100\t1000\t1000\t1000\n",
+		"77/status": "Name:\tdnsd\nUid:\t33\t33\t33\t33\nGid:\t33\t33\t33\t33\n",
+		"self/stat": "",
+		"net/udp6":  "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write fake procfs: %s\n", err.Error())
+		}
+	}
+	links := map[string]string{
+		"42/exe":   "/usr/bin/curl",
+		"42/fd/0":  "/dev/null",
+		"42/fd/3":  "socket:[2002]",
+		"77/exe":   "/usr/sbin/dnsd (deleted)",
+		"77/fd/5":  "socket:[4004]",
+		"77/fd/6":  "pipe:[9009]",
+		"self/exe": "/usr/bin/goaway",
+	}
+	for name, target := range links {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatalf("Unable to link fake procfs: %s\n", err.Error())
+		}
+	}
+	return ProcFS(root)
+}
+
+/***Unit-Tests***/
+
+func TestParseProcAddr(t *testing.T) {
+	for raw, expected := range map[string]struct {
+		ip   string
+		port int64
+	}{
+		"0100007F:0277":                         {"127.0.0.1", 631},
+		"72C8A8C0:9C40":                         {"192.168.200.114", 40000},
+		"0000000000000000FFFF00000100007F:0035": {"127.0.0.1", 53},
+		"00000000000000000000000000000000:0016": {"::", 22},
+	} {
+		if ip, port, ok := parseProcAddr(raw); !ok || ip != expected.ip || port != expected.port {
+			t.Fatalf("Unexpected address of %q: %s:%d (%t)\n", raw, ip, port, ok)
+		}
+	}
+	for _, raw := range []string{"", "0100007F", "0100007:0277", "0100007F:XYZ", "0100007F00:0277"} {
+		if _, _, ok := parseProcAddr(raw); ok {
+			t.Fatalf("Able to parse invalid address: %q\n", raw)
+		}
+	}
+}
+
+func TestProcFSOwner(t *testing.T) {
+	fs := fakeProcFS(t)
+	for _, check := range []struct {
+		pkt   PacketData
+		owner *Owner
+	}{
+		// connected tcp socket of curl
+		{PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookOutput},
+			&Owner{UID: 1000, GID: 1000, PID: 42, Exe: "/usr/bin/curl"}},
+		// unconnected udp socket bound to any address
+		{PacketData{SrcIP: "192.168.200.114", SrcPort: 53, DstIP: "8.8.8.8", DstPort: 53, Protocol: "UDP", Hook: HookOutput},
+			&Owner{UID: 33, GID: 33, PID: 77, Exe: "/usr/sbin/dnsd"}},
+		// dual-stack listening socket without a process holding it
+		{PacketData{SrcIP: "203.0.113.1", SrcPort: 50000, DstIP: "192.168.200.114", DstPort: 22, Protocol: "TCP", Hook: HookInput},
+			&Owner{UID: 0, GID: -1}},
+		// no socket bound to the port
+		{PacketData{SrcIP: "192.168.200.114", SrcPort: 40001, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookOutput}, nil},
+		// forwarded packets have no local owner
+		{PacketData{SrcIP: "10.0.1.2", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookForward}, nil},
+		{PacketData{SrcIP: "192.168.200.114", DstIP: "8.8.8.8", Protocol: "ICMP", Hook: HookOutput}, nil},
+	} {
+		owner := fs.Owner(&check.pkt)
+		if (owner == nil) != (check.owner == nil) || (owner != nil && *owner != *check.owner) {
+			t.Fatalf("Unexpected owner of %s:%d -> %s:%d: %+v\n", check.pkt.SrcIP, check.pkt.SrcPort, check.pkt.DstIP, check.pkt.DstPort, owner)
+		}
+	}
+}
+
+func TestProcFSInodeCache(t *testing.T) {
+	fs := fakeProcFS(t)
+	curl := PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookOutput}
+	if owner := fs.Owner(&curl); owner == nil || owner.PID != 42 {
+		t.Fatalf("Unexpected owner: %+v\n", owner)
+	}
+	procInodes.Lock()
+	pid := procInodes.pids[string(fs)+":2002"]
+	procInodes.Unlock()
+	if pid != 42 {
+		t.Fatalf("Process of the socket inode was not remembered: %d\n", pid)
+	}
+	// check the remembered process is checked again once it no longer holds the socket
+	root := string(fs)
+	for _, link := range []string{"43/fd/3", "42/fd/3"} {
+		os.Remove(filepath.Join(root, link))
+	}
+	os.MkdirAll(filepath.Join(root, "43", "fd"), 0755)
+	if err := os.Symlink("socket:[2002]", filepath.Join(root, "43", "fd", "3")); err != nil {
+		t.Fatalf("Unable to link fake procfs: %s\n", err.Error())
+	}
+	if owner := fs.Owner(&curl); owner == nil || owner.PID != 43 {
+		t.Fatalf("Unexpected owner after the socket moved: %+v\n", owner)
+	}
+}
+
+func TestPacketLocalOwner(t *testing.T) {
+	fs := fakeProcFS(t)
+	pkt := &PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookOutput, owners: fs}
+	// check rules without an owner do not look up the owner
+	rule := *exampleRule
+	rule.DstIP, rule.DstPort = convertIPs("93.184.216.34"), convertPorts("443")
+	fw := &Firewall{rules: []*fwRule{&rule}, defaults: &dfaults{inbound: "allow", outbound: "allow"}}
+	if fw.checkRules(pkt) != netfilter.NF_DROP || pkt.ownerLooked || pkt.Owner != nil {
+		t.Fatalf("Owner was looked up without an owner rule: %+v\n", pkt.Owner)
+	}
+	rule.Owner = owner{exe: "/usr/bin/curl"}
+	fw.checkRules(pkt)
+	if !pkt.ownerLooked || pkt.Owner == nil || pkt.Owner.PID != 42 {
+		t.Fatalf("Owner was not looked up for an owner rule: %+v\n", pkt.Owner)
+	}
+}
+
+func TestFirewallOwnerRule(t *testing.T) {
+	curl := &Owner{UID: 1000, GID: 1000, PID: 42, Exe: "/usr/bin/curl"}
+	for _, check := range []struct {
+		rule  owner
+		owner *Owner
+		match bool
+	}{
+		{owner{}, nil, true},
+		{owner{exe: "/usr/bin/curl"}, curl, true},
+		{owner{exe: "/usr/bin/wget"}, curl, false},
+		{owner{uid: "1000", gid: "1000"}, curl, true},
+		{owner{uid: "33"}, curl, false},
+		{owner{gid: "1000"}, &Owner{UID: 1000, GID: -1}, false},
+		{owner{uid: "1000"}, nil, false},
+	} {
+		rule := *exampleRule
+		rule.Owner = check.rule
+		pkt := *examplePktData
+		pkt.Owner = check.owner
+		if match := rule.Validate(&pkt); match != check.match {
+			t.Fatalf("Unexpected match of %+v for %+v: %t\n", check.rule, check.owner, match)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package goaway2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+)
+
+/***Variables***/
+
+//tls record/handshake/extension types used to find the server name of a ClientHello
+const (
+	tlsRecordHandshake   = 0x16
+	tlsClientHello       = 0x01
+	tlsExtServerName     = 0x0000
+	tlsServerNameHost    = 0x00
+	tlsRandomLength      = 32
+	tlsMaxRecord         = 16384 // largest record a ClientHello may be sent in
+	maxHTTPRequestHeader = 8192
+)
+
+//states of the application data read from the first data packets of a flow
+const (
+	payloadNone    = iota // no ClientHello/request (or no data yet)
+	payloadDone           // complete ClientHello/request
+	payloadPartial        // start of a ClientHello/request continued by later packets
+	payloadInvalid        // malformed/oversized ClientHello (sni/host rules fail closed)
+)
+
+//httpMethods : request methods recognized as the start of an HTTP/1.x request
+var httpMethods = []string{"GET ", "POST ", "PUT ", "HEAD ", "DELETE ", "OPTIONS ", "PATCH ", "CONNECT ", "TRACE "}
+
+/***Functions***/
+
+//parsePayload : collect the tls server name or http host from the first data packet of a flow
+// (ClientHellos/requests continued by later packets are reassembled by the Flows of the firewall)
+func parsePayload(payload []byte, pkt *PacketData) {
+	if len(payload) == 0 {
+		return
+	}
+	pkt.Payload, pkt.Data = true, payload
+	readPayload(payload, pkt)
+}
+
+//readPayload : set the server name/host of the application data and whether it is partial or invalid
+func readPayload(data []byte, pkt *PacketData) int {
+	pkt.SNI, pkt.Host, pkt.Partial, pkt.Invalid = "", "", false, false
+	sni, state := readClientHello(data)
+	if state == payloadNone {
+		pkt.Host, state = readHTTPRequest(data)
+	}
+	pkt.SNI, pkt.Partial, pkt.Invalid = sni, state == payloadPartial, state == payloadInvalid
+	return state
+}
+
+//readVector : split length prefixed vector (1-3 byte big-endian length) from data
+func readVector(data []byte, size int) (vector, rest []byte, ok bool) {
+	if len(data) < size {
+		return nil, nil, false
+	}
+	var length int
+	for _, b := range data[:size] {
+		length = length<<8 | int(b)
+	}
+	data = data[size:]
+	if len(data) < length {
+		return nil, nil, false
+	}
+	return data[:length], data[length:], true
+}
+
+//parseClientHelloSNI : return the server name of a tls ClientHello contained in the first record
+func parseClientHelloSNI(data []byte) (string, bool) {
+	sni, state := readClientHello(data)
+	return sni, state == payloadDone && sni != ""
+}
+
+//readClientHello : read the server name of a tls ClientHello (blank if it has none) along with the
+// state of the data, ClientHellos fragmented across several records are invalid
+func readClientHello(data []byte) (string, int) {
+	// record header: type, version, length
+	if len(data) == 0 || data[0] != tlsRecordHandshake || (len(data) > 1 && data[1] != 0x03) {
+		return "", payloadNone
+	}
+	if len(data) < 5 {
+		return "", payloadPartial
+	}
+	length := int(data[3])<<8 | int(data[4])
+	if length > tlsMaxRecord {
+		return "", payloadInvalid
+	}
+	if len(data) < 5+length {
+		return "", payloadPartial
+	}
+	record := data[5 : 5+length]
+	// handshake header: type, length
+	if len(record) < 4 {
+		return "", payloadInvalid
+	}
+	if record[0] != tlsClientHello {
+		return "", payloadNone
+	}
+	hello, _, ok := readVector(record[1:], 3)
+	if !ok || len(hello) < 2+tlsRandomLength {
+		return "", payloadInvalid
+	}
+	// skip client version and random, session id, cipher suites and compression methods
+	rest := hello[2+tlsRandomLength:]
+	for _, size := range []int{1, 2, 1} {
+		if _, rest, ok = readVector(rest, size); !ok {
+			return "", payloadInvalid
+		}
+	}
+	if len(rest) == 0 {
+		// ClientHello without extensions
+		return "", payloadDone
+	}
+	extensions, _, ok := readVector(rest, 2)
+	if !ok {
+		return "", payloadInvalid
+	}
+	for len(extensions) >= 4 {
+		extType := binary.BigEndian.Uint16(extensions)
+		var ext []byte
+		if ext, extensions, ok = readVector(extensions[2:], 2); !ok {
+			return "", payloadInvalid
+		}
+		if extType != tlsExtServerName {
+			continue
+		}
+		names, _, ok := readVector(ext, 2)
+		if !ok {
+			return "", payloadInvalid
+		}
+		for len(names) >= 3 {
+			nameType := names[0]
+			var name []byte
+			if name, names, ok = readVector(names[1:], 2); !ok {
+				return "", payloadInvalid
+			}
+			if nameType == tlsServerNameHost && IsHostPattern(string(name)) && name[0] != '*' {
+				return canonicalHost(string(name)), payloadDone
+			}
+		}
+		return "", payloadDone
+	}
+	return "", payloadDone
+}
+
+//readHTTPRequest : read the host header of an HTTP/1.x request along with the state of the data
+// (requests whose header is continued by later packets are partial)
+func readHTTPRequest(data []byte) (string, int) {
+	if host, ok := parseHTTPHost(data); ok {
+		return host, payloadDone
+	}
+	for _, method := range httpMethods {
+		if len(data) < len(method) && bytes.HasPrefix([]byte(method), data) {
+			return "", payloadPartial
+		}
+		if !bytes.HasPrefix(data, []byte(method)) {
+			continue
+		}
+		// the header ends with an empty line (or is cut off at its maximum size)
+		if len(data) < maxHTTPRequestHeader && !bytes.Contains(data, []byte("\n\r\n")) && !bytes.Contains(data, []byte("\n\n")) {
+			return "", payloadPartial
+		}
+		return "", payloadDone
+	}
+	return "", payloadNone
+}
+
+//parseHTTPHost : return the host header (without port) of an HTTP/1.x request
+func parseHTTPHost(data []byte) (string, bool) {
+	isRequest := false
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(data, []byte(method)) {
+			isRequest = true
+			break
+		}
+	}
+	if !isRequest {
+		return "", false
+	}
+	if len(data) > maxHTTPRequestHeader {
+		data = data[:maxHTTPRequestHeader]
+	}
+	lines := strings.Split(string(data), "\n")
+	if !strings.Contains(lines[0], " HTTP/1.") {
+		return "", false
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 || !strings.EqualFold(strings.TrimSpace(line[:colon]), "host") {
+			continue
+		}
+		host := strings.TrimSpace(line[colon+1:])
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !IsHostPattern(host) || strings.HasPrefix(host, "*.") {
+			return "", false
+		}
+		return canonicalHost(host), true
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package goaway2
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+)
+
+/***Functions***/
+
+//clientHello : record the first tls record a client sends when connecting to the given server name
+func clientHello(t testing.TB, name string) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go tls.Client(client, &tls.Config{ServerName: name}).Handshake()
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(server, header); err != nil {
+		t.Fatalf("Unable to read record header: %s\n", err.Error())
+	}
+	record := make([]byte, int(header[3])<<8|int(header[4]))
+	if _, err := io.ReadFull(server, record); err != nil {
+		t.Fatalf("Unable to read record: %s\n", err.Error())
+	}
+	client.Close()
+	return append(header, record...)
+}
+
+/***Unit-Tests***/
+
+func TestParseClientHelloSNI(t *testing.T) {
+	hello := clientHello(t, "API.GitHub.com")
+	if sni, ok := parseClientHelloSNI(hello); !ok || sni != "api.github.com" {
+		t.Fatalf("Unexpected server name: %q (%t)\n", sni, ok)
+	}
+	// check truncated and foreign records are rejected
+	for _, data := range [][]byte{hello[:len(hello)/2], hello[:5], []byte("GET / HTTP/1.1\r\n"), nil} {
+		if sni, ok := parseClientHelloSNI(data); ok {
+			t.Fatalf("Unexpected server name of invalid record: %q\n", sni)
+		}
+	}
+	// check ClientHello without a server name
+	if sni, ok := parseClientHelloSNI(clientHello(t, "10.0.0.1")); ok {
+		t.Fatalf("Unexpected server name of ip connection: %q\n", sni)
+	}
+}
+
+func TestReadClientHello(t *testing.T) {
+	hello := clientHello(t, "example.com")
+	// check every prefix of the ClientHello is partial
+	for n := 1; n < len(hello); n++ {
+		if sni, state := readClientHello(hello[:n]); state != payloadPartial || sni != "" {
+			t.Fatalf("Unexpected state of %d/%d bytes: %d %q\n", n, len(hello), state, sni)
+		}
+	}
+	if sni, state := readClientHello(hello); state != payloadDone || sni != "example.com" {
+		t.Fatalf("Unexpected state of complete ClientHello: %d %q\n", state, sni)
+	}
+	// check oversized records and ClientHellos fragmented across records are invalid
+	oversized := append([]byte(nil), hello...)
+	oversized[3], oversized[4] = 0x40, 0x01
+	fragmented := append([]byte(nil), hello[:5+100]...)
+	fragmented[3], fragmented[4] = 0, 100
+	for _, data := range [][]byte{oversized, fragmented} {
+		if _, state := readClientHello(data); state != payloadInvalid {
+			t.Fatalf("Unexpected state of invalid ClientHello: %d\n", state)
+		}
+	}
+	if _, state := readClientHello([]byte("GET / HTTP/1.1\r\n")); state != payloadNone {
+		t.Fatalf("Unexpected state of http request: %d\n", state)
+	}
+}
+
+func TestReadHTTPRequest(t *testing.T) {
+	for request, expected := range map[string]int{
+		"GE":                                payloadPartial,
+		"GET / HTTP/1.1\r\nAccept: */*\r\n": payloadPartial,
+		"GET / HTTP/1.1\r\nHost: example.com\r\n": payloadDone,
+		"GET / HTTP/1.1\r\nAccept: */*\r\n\r\n":   payloadDone,
+		"SSH-2.0-OpenSSH_9.6\r\n":                 payloadNone,
+	} {
+		if _, state := readHTTPRequest([]byte(request)); state != expected {
+			t.Fatalf("Unexpected state of %q: %d\n", request, state)
+		}
+	}
+}
+
+func TestParseHTTPHost(t *testing.T) {
+	for request, expected := range map[string]string{
+		"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n":                     "example.com",
+		"POST /api HTTP/1.1\r\nAccept: */*\r\nhost: Example.com:8080\r\n": "example.com",
+		"GET / HTTP/1.1\r\nAccept: */*\r\n\r\nHost: example.com\r\n":      "",
+		"GET / HTTP/1.1\r\nHost: 10.0.0.1\r\n\r\n":                        "",
+		"SSH-2.0-OpenSSH_9.6\r\n":                                         "",
+		"GET /\r\nHost: example.com\r\n":                                  "",
+	} {
+		host, ok := parseHTTPHost([]byte(request))
+		if host != expected || ok != (expected != "") {
+			t.Fatalf("Unexpected host of %q: %q (%t)\n", request, host, ok)
+		}
+	}
+}
+
+/***Fuzz-Tests***/
+
+func FuzzParseClientHelloSNI(f *testing.F) {
+	f.Add(clientHello(f, "example.com"))
+	f.Add([]byte{0x16, 0x03, 0x01, 0x00, 0x00})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if sni, ok := parseClientHelloSNI(data); ok && !IsHostPattern(sni) {
+			t.Fatalf("Invalid server name parsed: %q\n", sni)
+		}
+	})
+}
+
+func FuzzParseHTTPHost(f *testing.F) {
+	f.Add([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	f.Add([]byte("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if host, ok := parseHTTPHost(data); ok && !IsHostPattern(host) {
+			t.Fatalf("Invalid host parsed: %q\n", host)
+		}
+	})
+}
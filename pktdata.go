@@ -1,9 +1,14 @@
 package goaway2
 
-import "net"
-
 /***Variables***/
 
+//netfilter hooks packets are queued from (see iptables.sh)
+const (
+	HookInput   = "INPUT"
+	HookOutput  = "OUTPUT"
+	HookForward = "FORWARD"
+)
+
 //PacketData : packet data containing relevant data from gopacket
 type PacketData struct {
 	SrcIP    string
@@ -11,31 +16,79 @@ type PacketData struct {
 	SrcPort  int64
 	DstPort  int64
 	Protocol string
+	// tcp packet opening a connection (SYN without ACK) and its sequence/acknowledgment number
+	Syn bool
+	Seq uint32
+	Ack uint32
+	// interfaces the packet was received on/is sent out of (blank if unknown)
+	InIface  string
+	OutIface string
+	// netfilter hook the packet was queued from (blank if unknown)
+	Hook string
+	// application data of the first data packets of a flow
+	Payload bool   // packet carries application data
+	SNI     string // server name of a tls ClientHello (blank if none)
+	Host    string // host header of an HTTP/1.x request (blank if none)
+	Partial bool   // the ClientHello/request is continued by later packets (sni/host are unknown yet)
+	Invalid bool   // the ClientHello/request can not be read (sni/host rules fail closed)
+	Data    []byte // tcp/udp payload (read by spa profiles and reassembled by Flows)
+	// local user/process the packet belongs to (nil if unknown, see LocalOwner)
+	Owner *Owner
+	// procfs the owner is looked up in on first use (blank skips the lookup)
+	owners      ProcFS
+	ownerLooked bool
 }
 
-//localIPs : a hashmap of local ip-addresses
-var localIPs = func() map[string]struct{} {
-	// create binary tree for lookup
-	ips := make(map[string]struct{})
-	// get ip-addresses from interfaces
-	ifaces, _ := net.Interfaces()
-	for _, i := range ifaces {
-		addrs, _ := i.Addrs()
-		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.To4() != nil {
-					ips[ipnet.IP.String()] = struct{}{}
-				}
-			}
-		}
-	}
-	return ips
-}()
-
 /***Methods***/
 
+//(*PacketData).Direction : determine direction of packet (inbound/outbound/forward) from its hook
+// falling back to whether the source is a local address when the hook is unknown
+func (p *PacketData) Direction() string {
+	switch p.Hook {
+	case HookInput:
+		return "inbound"
+	case HookOutput:
+		return "outbound"
+	case HookForward:
+		return "forward"
+	}
+	if _, ok := localAddrs()[p.SrcIP]; ok {
+		return "outbound"
+	}
+	return "inbound"
+}
+
 //(*PacketData).IsInbound : determine if packet is inbound
 func (p *PacketData) IsInbound() bool {
-	_, ok := localIPs[p.SrcIP]
-	return !ok
+	return p.Direction() == "inbound"
+}
+
+//(*PacketData).ResolveInterfaces : fill in unknown interfaces using the local address of the packet
+func (p *PacketData) ResolveInterfaces() {
+	addrs := localAddrs()
+	if p.InIface == "" {
+		p.InIface = addrs[p.DstIP]
+	}
+	if p.OutIface == "" {
+		p.OutIface = addrs[p.SrcIP]
+	}
+}
+
+//(*PacketData).LocalOwner : return the owner of the packet, looking it up in the procfs of the queue
+// on first use (only owner rules and prompts need it, the lookup scans the processes of the system)
+func (p *PacketData) LocalOwner() *Owner {
+	if p.Owner == nil && p.owners != "" && !p.ownerLooked {
+		p.ownerLooked = true
+		p.Owner = p.owners.Owner(p)
+	}
+	return p.Owner
+}
+
+//(*PacketData).Iface : return the interface that decides the zone of the packet
+// (forwarded packets belong to the zone they were received from)
+func (p *PacketData) Iface(direction string) string {
+	if direction == "outbound" {
+		return p.OutIface
+	}
+	return p.InIface
 }
@@ -0,0 +1,97 @@
+package profiles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/***Functions***/
+
+//parsePorts : parse ufw style port list ("80,443/tcp", "60000:61000/udp", "53|853/tcp") into ports
+func parsePorts(value string) ([]Port, error) {
+	var ports []Port
+	for _, group := range strings.Split(value, "|") {
+		proto := "any"
+		if i := strings.Index(group, "/"); i >= 0 {
+			proto = strings.ToLower(group[i+1:])
+			group = group[:i]
+			if proto != "tcp" && proto != "udp" {
+				return nil, fmt.Errorf("invalid protocol %q", proto)
+			}
+		}
+		for _, raw := range strings.Split(group, ",") {
+			bounds := strings.Split(strings.TrimSpace(raw), ":")
+			if len(bounds) > 2 {
+				return nil, fmt.Errorf("invalid port %q", raw)
+			}
+			for _, b := range bounds {
+				if _, err := strconv.ParseUint(b, 10, 16); err != nil {
+					return nil, fmt.Errorf("invalid port %q", raw)
+				}
+			}
+			ports = append(ports, Port{Ports: strings.Join(bounds, "-"), Protocol: proto})
+		}
+	}
+	return ports, nil
+}
+
+//ParseApps : parse application profile file (ufw applications.d format) into application profiles
+// every profile is a section (e.g. "[Nginx Full]") containing title, description and ports keys
+func ParseApps(r io.Reader, name string, set *Set) error {
+	var p *Profile
+	// ensure the previous profile was complete
+	finish := func(lineno int) error {
+		if p != nil && len(p.Ports) == 0 {
+			return fmt.Errorf("%s:%d: profile %q has no ports", name, lineno, p.Name)
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	lineno := 1
+	for ; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			if err := finish(lineno); err != nil {
+				return err
+			}
+			p = &Profile{Name: strings.TrimSpace(line[1 : len(line)-1])}
+			if _, ok := set.apps[p.Name]; ok {
+				return fmt.Errorf("%s:%d: duplicate profile %q", name, lineno, p.Name)
+			}
+			set.apps[p.Name] = p
+		case p == nil:
+			return fmt.Errorf("%s:%d: statement outside of a profile", name, lineno)
+		case strings.Contains(line, "="):
+			kv := strings.SplitN(line, "=", 2)
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			switch key {
+			case "title":
+				p.Title = value
+			case "description":
+				p.Description = value
+			case "ports":
+				ports, err := parsePorts(value)
+				if err != nil {
+					return fmt.Errorf("%s:%d: %s", name, lineno, err.Error())
+				}
+				for _, port := range ports {
+					p.addPort(port)
+				}
+			default:
+				return fmt.Errorf("%s:%d: unknown key %q", name, lineno, key)
+			}
+		default:
+			return fmt.Errorf("%s:%d: unknown statement %q", name, lineno, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return finish(lineno)
+}
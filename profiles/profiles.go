@@ -0,0 +1,168 @@
+package profiles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/***Variables***/
+
+const (
+	// ServicesFile : system service name database
+	ServicesFile = "/etc/services"
+	// AppsDir : directory of user-defined application profiles
+	AppsDir = "/etc/goaway/applications.d"
+)
+
+//reference prefixes stored within rules to refer to a profile
+const (
+	servicePrefix = "service:"
+	appPrefix     = "app:"
+)
+
+//Port : port/port-range of a profile in goaway's port notation
+type Port struct {
+	Ports    string // 00/00-00
+	Protocol string // tcp/udp/any
+}
+
+//Profile : named collection of ports a rule can refer to
+type Profile struct {
+	Name        string
+	Title       string
+	Description string
+	Ports       []Port
+}
+
+//Set : services and application profiles available to rules
+type Set struct {
+	services map[string]*Profile
+	apps     map[string]*Profile
+}
+
+/***Functions***/
+
+//NewSet : create empty profile set
+func NewSet() *Set {
+	return &Set{services: make(map[string]*Profile), apps: make(map[string]*Profile)}
+}
+
+//Load : load services file and application profile directory (missing paths are ignored)
+func Load(servicesPath, appsDir string) (*Set, error) {
+	set := NewSet()
+	// load services
+	f, err := os.Open(servicesPath)
+	switch {
+	case err == nil:
+		err = ParseServices(f, servicesPath, set)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	// load application profiles
+	files, err := ioutil.ReadDir(appsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return set, nil
+		}
+		return nil, err
+	}
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(appsDir, fi.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = ParseApps(f, path, set)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+//ServiceRef : return reference to the named service stored within rules
+func ServiceRef(name string) string {
+	return servicePrefix + name
+}
+
+//AppRef : return reference to the named application profile stored within rules
+func AppRef(name string) string {
+	return appPrefix + name
+}
+
+//CheckRef : verify validity of value as a profile reference
+func CheckRef(ref string) bool {
+	return (strings.HasPrefix(ref, servicePrefix) && len(ref) > len(servicePrefix)) ||
+		(strings.HasPrefix(ref, appPrefix) && len(ref) > len(appPrefix))
+}
+
+/***Methods***/
+
+//(*Set).Lookup : return profile the reference refers to
+func (s *Set) Lookup(ref string) (*Profile, error) {
+	var (
+		p  *Profile
+		ok bool
+	)
+	switch {
+	case strings.HasPrefix(ref, servicePrefix):
+		p, ok = s.services[strings.TrimPrefix(ref, servicePrefix)]
+	case strings.HasPrefix(ref, appPrefix):
+		p, ok = s.apps[strings.TrimPrefix(ref, appPrefix)]
+	default:
+		return nil, fmt.Errorf("invalid profile reference %q", ref)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", ref)
+	}
+	return p, nil
+}
+
+//(*Set).Apps : return all application profiles ordered by name
+func (s *Set) Apps() []*Profile {
+	apps := make([]*Profile, 0, len(s.apps))
+	for _, p := range s.apps {
+		apps = append(apps, p)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps
+}
+
+//(*Profile).addPort : add port to the profile unless already present
+func (p *Profile) addPort(port Port) {
+	for _, existing := range p.Ports {
+		if existing == port {
+			return
+		}
+	}
+	p.Ports = append(p.Ports, port)
+}
+
+//(*Profile).String : describe the ports of the profile
+func (p *Profile) String() string {
+	ports := make([]string, len(p.Ports))
+	for n, port := range p.Ports {
+		ports[n] = port.String()
+	}
+	return strings.Join(ports, ",")
+}
+
+//(Port).String : describe port and protocol
+func (p Port) String() string {
+	if p.Protocol == "any" {
+		return p.Ports
+	}
+	return p.Ports + "/" + p.Protocol
+}
@@ -0,0 +1,87 @@
+package profiles
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/***Functions***/
+
+//loadFixture : load services and application profiles from the fixtures
+func loadFixture(t *testing.T) *Set {
+	set, err := Load(filepath.Join("testdata", "services"), filepath.Join("testdata", "applications.d"))
+	if err != nil {
+		t.Fatalf("Unable to load fixtures: %s\n", err.Error())
+	}
+	return set
+}
+
+/***Unit-Tests***/
+
+func TestProfileServices(t *testing.T) {
+	set := loadFixture(t)
+	for _, check := range []struct {
+		ref      string
+		expected []Port
+	}{
+		{ServiceRef("ssh"), []Port{{"22", "tcp"}}},
+		{ServiceRef("domain"), []Port{{"53", "tcp"}, {"53", "udp"}}},
+		{ServiceRef("www"), []Port{{"80", "tcp"}}},
+		{ServiceRef("https"), []Port{{"443", "tcp"}, {"443", "udp"}}},
+	} {
+		p, err := set.Lookup(check.ref)
+		if err != nil {
+			t.Fatalf("Unable to lookup %q: %s\n", check.ref, err.Error())
+		}
+		if !reflect.DeepEqual(p.Ports, check.expected) {
+			t.Fatalf("Unexpected ports of %q: %v\n", check.ref, p.Ports)
+		}
+	}
+	if _, err := set.Lookup(ServiceRef("sctp-only")); err == nil {
+		t.Fatalf("Able to lookup service without tcp/udp ports\n")
+	}
+}
+
+func TestProfileApps(t *testing.T) {
+	set := loadFixture(t)
+	p, err := set.Lookup(AppRef("Nginx Full"))
+	if err != nil {
+		t.Fatalf("Unable to lookup profile: %s\n", err.Error())
+	}
+	if p.String() != "80/tcp,443/tcp" || p.Title != "Web Server (Nginx, HTTP + HTTPS)" {
+		t.Fatalf("Unexpected profile: %+v\n", p)
+	}
+	if p, _ = set.Lookup(AppRef("Mosh")); p == nil || p.String() != "22/tcp,60000-61000/udp" {
+		t.Fatalf("Unexpected profile: %+v\n", p)
+	}
+	var names []string
+	for _, app := range set.Apps() {
+		names = append(names, app.Name)
+	}
+	if strings.Join(names, ",") != "Mosh,Nginx Full,Nginx HTTP,OpenSSH" {
+		t.Fatalf("Unexpected profiles: %v\n", names)
+	}
+	// check services and applications do not share a namespace
+	if _, err = set.Lookup(ServiceRef("OpenSSH")); err == nil {
+		t.Fatalf("Able to lookup application as a service\n")
+	}
+}
+
+func TestProfileInvalid(t *testing.T) {
+	for _, content := range []string{
+		"title=outside\n",
+		"[Broken]\ntitle=no ports\n",
+		"[Broken]\nports=80/sctp\n",
+		"[Broken]\nports=1-2\n",
+		"[Broken]\nports=80\n[Broken]\nports=81\n",
+	} {
+		if err := ParseApps(strings.NewReader(content), "broken", NewSet()); err == nil {
+			t.Fatalf("Able to parse invalid profile:\n%s\n", content)
+		}
+	}
+	if !CheckRef(AppRef("x")) || CheckRef("app:") || CheckRef("ssh") {
+		t.Fatalf("Unexpected profile reference validation\n")
+	}
+}
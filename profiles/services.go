@@ -0,0 +1,49 @@
+package profiles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/***Functions***/
+
+//ParseServices : parse services database (/etc/services) into service profiles
+// entries are formatted as: name port/protocol [aliases...] [# comment]
+func ParseServices(r io.Reader, name string, set *Set) error {
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("%s:%d: missing port of service %q", name, lineno, fields[0])
+		}
+		split := strings.SplitN(fields[1], "/", 2)
+		if _, err := strconv.ParseUint(split[0], 10, 16); err != nil || len(split) != 2 {
+			return fmt.Errorf("%s:%d: invalid port %q", name, lineno, fields[1])
+		}
+		// protocols other than tcp/udp (sctp, ddp, ...) never reach the firewall
+		proto := strings.ToLower(split[1])
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		// register service under its name and all aliases
+		for _, alias := range append(fields[:1], fields[2:]...) {
+			p, ok := set.services[alias]
+			if !ok {
+				p = &Profile{Name: alias, Title: fields[0]}
+				set.services[alias] = p
+			}
+			p.addPort(Port{Ports: split[0], Protocol: proto})
+		}
+	}
+	return scanner.Err()
+}
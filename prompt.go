@@ -0,0 +1,293 @@
+package goaway2
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Variables***/
+
+//prompt timing defaults
+const (
+	defaultAskTimeout = 30 * time.Second // time a packet is held waiting for an answer
+	maxPromptWait     = 30 * time.Second // longest a prompt client may wait for new prompts
+	onceFlowIdle      = 5 * time.Minute  // time a flow answered once keeps its answer after its last packet
+)
+
+//prompt answer scopes
+const (
+	ScopeOnce     = "once"     // decide only the flow of the prompt
+	ScopeDuration = "duration" // decide matching connections until the duration passed
+	ScopeForever  = "forever"  // decide matching connections by writing an answer (see Store.AddAnswer)
+)
+
+//Prompt : outbound connection no rule decided that waits for an answer
+type Prompt struct {
+	ID       int64     `json:"id"`
+	Protocol string    `json:"proto"`
+	SrcIP    string    `json:"src_ip"`
+	SrcPort  int64     `json:"src_port"`
+	DstIP    string    `json:"dst_ip"`
+	DstPort  int64     `json:"dst_port"`
+	UID      int64     `json:"uid"` // -1 if the owner is unknown
+	PID      int       `json:"pid,omitempty"`
+	Exe      string    `json:"exe,omitempty"`
+	Deadline time.Time `json:"deadline"`
+}
+
+//PromptAnswer : decision of the user for a prompt
+type PromptAnswer struct {
+	ID       int64  `json:"id"`
+	Verdict  string `json:"verdict"`            // allow/deny
+	Scope    string `json:"scope"`              // once/duration/forever
+	Duration int64  `json:"duration,omitempty"` // seconds the answer is remembered for (duration scope)
+}
+
+//Prompter : holds outbound packets until a prompt client answers or the prompt times out
+type Prompter struct {
+	Timeout time.Duration     // time a packet is held waiting for an answer
+	Verdict netfilter.Verdict // verdict of unanswered prompts
+	// persists answers of the forever scope (nil to only remember them until restart)
+	Remember func(p Prompt, a PromptAnswer) error
+
+	lock       sync.Mutex
+	nextID     int64
+	pending    map[string]*heldPrompt
+	remembered map[string]rememberedAnswer
+	flows      map[string]rememberedAnswer // flows answered once
+	lastPoll   time.Time
+	notify     chan struct{} // closed when a new prompt is published
+}
+
+//heldPrompt : published prompt along with the packets waiting for it
+type heldPrompt struct {
+	prompt  Prompt
+	verdict netfilter.Verdict
+	done    chan struct{}
+}
+
+//rememberedAnswer : verdict of connections answered for a duration or forever (or of a flow answered once)
+type rememberedAnswer struct {
+	verdict netfilter.Verdict
+	expires time.Time // zero for forever
+}
+
+/***Functions***/
+
+//NewPrompter : create prompter holding packets for timeout before applying the given verdict
+func NewPrompter(timeout time.Duration, verdict netfilter.Verdict) *Prompter {
+	return &Prompter{
+		Timeout:    timeout,
+		Verdict:    verdict,
+		pending:    make(map[string]*heldPrompt),
+		remembered: make(map[string]rememberedAnswer),
+		flows:      make(map[string]rememberedAnswer),
+		notify:     make(chan struct{}),
+	}
+}
+
+//promptKey : identify the connections a prompt answer applies to (owner and destination)
+func promptKey(pkt *PacketData) string {
+	owner := "?"
+	if own := pkt.LocalOwner(); own != nil {
+		owner = strconv.FormatInt(own.UID, 10) + ":" + own.Exe
+	}
+	return fmt.Sprintf("%s|%s|%s|%d", owner, pkt.Protocol, pkt.DstIP, pkt.DstPort)
+}
+
+//promptFlowKey : identify the flow a once answer applies to
+func promptFlowKey(proto, srcIP string, srcPort int64, dstIP string, dstPort int64) string {
+	return fmt.Sprintf("%s|%s:%d|%s:%d", strings.ToUpper(proto), srcIP, srcPort, dstIP, dstPort)
+}
+
+//parseVerdict : convert allow/deny into a netfilter verdict
+func parseVerdict(verdict string) (netfilter.Verdict, error) {
+	switch verdict {
+	case "allow":
+		return netfilter.NF_ACCEPT, nil
+	case "deny":
+		return netfilter.NF_DROP, nil
+	default:
+		return netfilter.NF_DROP, fmt.Errorf("invalid verdict: %q (allow/deny)", verdict)
+	}
+}
+
+/***Methods***/
+
+//(*Prompter).Ask : hold packet until its prompt is answered and return the verdict
+// packets are not held while no prompt client is polling and only the first packet of a
+// prompt is held (later packets of a pending prompt are dropped at once), only new
+// connections are prompted for, later packets of a flow get the answer of its handshake
+func (p *Prompter) Ask(pkt *PacketData, now time.Time) netfilter.Verdict {
+	key, flow := promptKey(pkt), promptFlowKey(pkt.Protocol, pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort)
+	p.lock.Lock()
+	if r, ok := p.flows[flow]; ok {
+		if now.Before(r.expires) {
+			p.flows[flow] = rememberedAnswer{verdict: r.verdict, expires: now.Add(onceFlowIdle)}
+			p.lock.Unlock()
+			return r.verdict
+		}
+		delete(p.flows, flow)
+	}
+	if r, ok := p.remembered[key]; ok {
+		if r.expires.IsZero() || now.Before(r.expires) {
+			p.lock.Unlock()
+			return r.verdict
+		}
+		delete(p.remembered, key)
+	}
+	// tcp packets without SYN belong to flows whose handshake was decided (or never seen) before
+	if strings.EqualFold(pkt.Protocol, "tcp") && !pkt.Syn {
+		p.lock.Unlock()
+		return p.Verdict
+	}
+	if p.lastPoll.IsZero() || now.Sub(p.lastPoll) > p.Timeout+maxPromptWait {
+		p.lock.Unlock()
+		return p.Verdict
+	}
+	// only the first packet waits for the prompt, retransmissions and further connections with
+	// the same key are dropped without holding another queue slot (the sender retries them)
+	if _, ok := p.pending[key]; ok {
+		p.lock.Unlock()
+		return netfilter.NF_DROP
+	}
+	p.nextID++
+	held := &heldPrompt{
+		prompt: Prompt{
+			ID: p.nextID, Protocol: pkt.Protocol, SrcIP: pkt.SrcIP, SrcPort: pkt.SrcPort,
+			DstIP: pkt.DstIP, DstPort: pkt.DstPort, UID: -1, Deadline: now.Add(p.Timeout),
+		},
+		done: make(chan struct{}),
+	}
+	if own := pkt.LocalOwner(); own != nil {
+		held.prompt.UID, held.prompt.PID, held.prompt.Exe = own.UID, own.PID, own.Exe
+	}
+	p.pending[key] = held
+	close(p.notify)
+	p.notify = make(chan struct{})
+	deadline := held.prompt.Deadline
+	p.lock.Unlock()
+	timer := time.NewTimer(deadline.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-held.done:
+	case <-timer.C:
+		p.lock.Lock()
+		if p.pending[key] == held {
+			delete(p.pending, key)
+			held.verdict = p.Verdict
+			close(held.done)
+		}
+		p.lock.Unlock()
+	}
+	<-held.done
+	return held.verdict
+}
+
+//(*Prompter).Pending : return the pending prompts waiting up to wait for one to be published
+func (p *Prompter) Pending(wait time.Duration) []Prompt {
+	if wait > maxPromptWait {
+		wait = maxPromptWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		p.lock.Lock()
+		p.lastPoll = time.Now()
+		prompts := make([]Prompt, 0, len(p.pending))
+		for _, held := range p.pending {
+			prompts = append(prompts, held.prompt)
+		}
+		notify := p.notify
+		p.lock.Unlock()
+		if len(prompts) > 0 {
+			sort.Slice(prompts, func(i, j int) bool { return prompts[i].ID < prompts[j].ID })
+			return prompts
+		}
+		select {
+		case <-notify:
+		case <-timer.C:
+			return prompts
+		}
+	}
+}
+
+//(*Prompter).Answer : release the packets held by a prompt and remember the answer for its scope
+func (p *Prompter) Answer(a PromptAnswer, now time.Time) error {
+	verdict, err := parseVerdict(a.Verdict)
+	if err != nil {
+		return err
+	}
+	if a.Scope == ScopeDuration && a.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if a.Scope != ScopeOnce && a.Scope != ScopeDuration && a.Scope != ScopeForever {
+		return fmt.Errorf("invalid scope: %q (once/duration/forever)", a.Scope)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var (
+		key  string
+		held *heldPrompt
+	)
+	for k, h := range p.pending {
+		if h.prompt.ID == a.ID {
+			key, held = k, h
+		}
+	}
+	if held == nil {
+		return fmt.Errorf("prompt #%d is no longer pending", a.ID)
+	}
+	switch a.Scope {
+	case ScopeOnce:
+		for k, r := range p.flows {
+			if !now.Before(r.expires) {
+				delete(p.flows, k)
+			}
+		}
+		pr := held.prompt
+		p.flows[promptFlowKey(pr.Protocol, pr.SrcIP, pr.SrcPort, pr.DstIP, pr.DstPort)] = rememberedAnswer{verdict: verdict, expires: now.Add(onceFlowIdle)}
+	case ScopeDuration:
+		p.remembered[key] = rememberedAnswer{verdict: verdict, expires: now.Add(time.Duration(a.Duration) * time.Second)}
+	case ScopeForever:
+		if p.Remember != nil {
+			if err = p.Remember(held.prompt, a); err != nil {
+				return err
+			}
+		}
+		p.remembered[key] = rememberedAnswer{verdict: verdict}
+	}
+	delete(p.pending, key)
+	held.verdict = verdict
+	close(held.done)
+	return nil
+}
+
+//(*Prompter).HandleControl : serve the prompts/answer commands used by prompt clients
+func (p *Prompter) HandleControl(s *ControlServer) {
+	s.Handle("prompts", func(args json.RawMessage) (interface{}, error) {
+		var req struct {
+			Wait int64 `json:"wait"` // seconds to wait for a prompt
+		}
+		if args != nil {
+			if err := json.Unmarshal(args, &req); err != nil {
+				return nil, err
+			}
+		}
+		return p.Pending(time.Duration(req.Wait) * time.Second), nil
+	})
+	s.Handle("answer", func(args json.RawMessage) (interface{}, error) {
+		var a PromptAnswer
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		return nil, p.Answer(a, time.Now())
+	})
+}
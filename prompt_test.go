@@ -0,0 +1,215 @@
+package goaway2
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Variables***/
+
+var examplePromptPkt = &PacketData{
+	SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "93.184.216.34", DstPort: 443, Protocol: "TCP", Hook: HookOutput, Syn: true,
+	Owner: &Owner{UID: 1000, GID: 1000, PID: 42, Exe: "/usr/bin/curl"},
+}
+
+/***Functions***/
+
+//askAsync : ask prompter for a verdict in the background
+func askAsync(p *Prompter, pkt *PacketData) <-chan netfilter.Verdict {
+	verdict := make(chan netfilter.Verdict, 1)
+	go func() {
+		verdict <- p.Ask(pkt, time.Now())
+	}()
+	return verdict
+}
+
+/***Unit-Tests***/
+
+func TestPrompterWithoutClient(t *testing.T) {
+	p := NewPrompter(time.Hour, netfilter.NF_DROP)
+	// check packets are not held while no prompt client is polling
+	if v := p.Ask(examplePromptPkt, time.Now()); v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict without client: %d\n", v)
+	}
+	if prompts := p.Pending(0); len(prompts) != 0 {
+		t.Fatalf("Unexpected prompts: %+v\n", prompts)
+	}
+}
+
+func TestPrompterAnswer(t *testing.T) {
+	p := NewPrompter(time.Minute, netfilter.NF_DROP)
+	p.Pending(0)
+	first := askAsync(p, examplePromptPkt)
+	prompts := p.Pending(time.Second)
+	if len(prompts) != 1 || prompts[0].Exe != "/usr/bin/curl" || prompts[0].DstPort != 443 {
+		t.Fatalf("Unexpected prompts: %+v\n", prompts)
+	}
+	// check retransmissions are dropped at once instead of waiting for the same prompt
+	retransmit := *examplePromptPkt
+	if v := p.Ask(&retransmit, time.Now()); v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict of retransmission: %d\n", v)
+	}
+	if prompts = p.Pending(0); len(prompts) != 1 {
+		t.Fatalf("Unexpected prompts after retransmission: %+v\n", prompts)
+	}
+	if err := p.Answer(PromptAnswer{ID: prompts[0].ID, Verdict: "allow", Scope: "bogus"}, time.Now()); err == nil {
+		t.Fatalf("Able to answer with unknown scope\n")
+	}
+	now := time.Now()
+	if err := p.Answer(PromptAnswer{ID: prompts[0].ID, Verdict: "allow", Scope: ScopeDuration, Duration: 60}, now); err != nil {
+		t.Fatalf("Unable to answer prompt: %s\n", err.Error())
+	}
+	if v := <-first; v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict of held packet: %d\n", v)
+	}
+	// check answer is remembered for its duration only
+	if v := p.Ask(examplePromptPkt, now.Add(30*time.Second)); v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict within duration: %d\n", v)
+	}
+	if v := p.Ask(examplePromptPkt, now.Add(time.Hour)); v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict after duration: %d\n", v)
+	}
+	if err := p.Answer(PromptAnswer{ID: prompts[0].ID, Verdict: "deny", Scope: ScopeOnce}, now); err == nil {
+		t.Fatalf("Able to answer prompt twice\n")
+	}
+}
+
+func TestPrompterOnce(t *testing.T) {
+	p := NewPrompter(time.Minute, netfilter.NF_DROP)
+	p.Pending(0)
+	first := askAsync(p, examplePromptPkt)
+	prompts := p.Pending(time.Second)
+	if len(prompts) != 1 {
+		t.Fatalf("Unexpected prompts: %+v\n", prompts)
+	}
+	now := time.Now()
+	if err := p.Answer(PromptAnswer{ID: prompts[0].ID, Verdict: "allow", Scope: ScopeOnce}, now); err != nil {
+		t.Fatalf("Unable to answer prompt: %s\n", err.Error())
+	}
+	if v := <-first; v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict of held packet: %d\n", v)
+	}
+	// check later packets of the answered flow keep its answer without prompting again
+	data := *examplePromptPkt
+	data.Syn, data.Payload = false, true
+	if v := p.Ask(&data, now.Add(time.Minute)); v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict of flow packet: %d\n", v)
+	}
+	// check packets of other flows without SYN are not prompted for
+	other := data
+	other.SrcPort++
+	if v := p.Ask(&other, now.Add(time.Minute)); v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict of unknown flow packet: %d\n", v)
+	}
+	if prompts = p.Pending(0); len(prompts) != 0 {
+		t.Fatalf("Unexpected prompts for flow packets: %+v\n", prompts)
+	}
+	// check the flow forgets its answer once it went idle
+	if v := p.Ask(&data, now.Add(time.Minute+onceFlowIdle)); v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict of idle flow packet: %d\n", v)
+	}
+}
+
+func TestPrompterTimeout(t *testing.T) {
+	p := NewPrompter(20*time.Millisecond, netfilter.NF_ACCEPT)
+	p.Pending(0)
+	if v := p.Ask(examplePromptPkt, time.Now()); v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict of unanswered prompt: %d\n", v)
+	}
+	if prompts := p.Pending(0); len(prompts) != 0 {
+		t.Fatalf("Unexpected prompts after timeout: %+v\n", prompts)
+	}
+}
+
+func TestFirewallAsk(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "ask", Forward: "deny", AskTimeout: 5, AskVerdict: "deny"})
+	st.AppendRule(store.Rule{Zone: "outbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "53", Action: "allow"})
+	fw := newFirewall(st, profiles.NewSet())
+	if fw.prompts.Timeout != 5*time.Second || fw.prompts.Verdict != netfilter.NF_DROP {
+		t.Fatalf("Unexpected prompter: %+v\n", fw.prompts)
+	}
+	kv := NewRedBlackKV()
+	// check packets no rule decided are asked about
+	if d := fw.Decide(kv, examplePromptPkt); d.Reason != "ask" || d.Verdict != netfilter.NF_DROP || d.Default != "ask" {
+		t.Fatalf("Unexpected decision without a rule: %+v\n", d)
+	}
+	dns := *examplePromptPkt
+	dns.DstPort = 53
+	if d := fw.Decide(kv, &dns); d.Reason != "rule" || d.Verdict != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected decision of allowed packet: %+v\n", d)
+	}
+	// check forever answers are written as answers outside the rule chain and its history
+	fw.Prompter().Pending(0)
+	verdict := askAsync(fw.Prompter(), examplePromptPkt)
+	prompts := fw.Prompter().Pending(time.Second)
+	if len(prompts) != 1 {
+		t.Fatalf("Unexpected prompts: %+v\n", prompts)
+	}
+	if err = fw.Prompter().Answer(PromptAnswer{ID: prompts[0].ID, Verdict: "deny", Scope: ScopeForever}, time.Now()); err != nil {
+		t.Fatalf("Unable to answer prompt: %s\n", err.Error())
+	}
+	if v := <-verdict; v != netfilter.NF_DROP {
+		t.Fatalf("Unexpected verdict of denied packet: %d\n", v)
+	}
+	answers, err := st.Answers()
+	if err != nil || len(answers) != 1 || answers[0].Action != "deny" || answers[0].Exe != "/usr/bin/curl" || answers[0].ToIP != "93.184.216.34" || answers[0].ToPort != "443" {
+		t.Fatalf("Unexpected answers: %+v (%v)\n", answers, err)
+	}
+	if rules, _ := st.Rules(); len(rules) != 1 {
+		t.Fatalf("Unexpected rules after answer: %+v\n", rules)
+	}
+	if changes, _ := st.History(10); len(changes) != 2 || changes[0].Table != "rules" {
+		t.Fatalf("Answer was recorded within the history: %+v\n", changes)
+	}
+	if d := newFirewall(st, profiles.NewSet()).Decide(kv, examplePromptPkt); d.Reason != "answer" || d.Verdict != netfilter.NF_DROP || d.RuleNum != answers[0].RuleNum {
+		t.Fatalf("Unexpected decision after answer: %+v\n", d)
+	}
+}
+
+func TestPrompterControl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goaway-prompt")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s\n", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "goaway.sock")
+	s := NewControlServer(path, log.New(ioutil.Discard, "", 0))
+	p := NewPrompter(time.Minute, netfilter.NF_DROP)
+	p.HandleControl(s)
+	go s.Serve()
+	for i := 0; i < 200; i++ {
+		if _, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// check prompt clients receive prompts and answer them through the control api
+	var prompts []Prompt
+	if err = ControlCall(path, "prompts", map[string]int64{"wait": 0}, &prompts); err != nil || len(prompts) != 0 {
+		t.Fatalf("Unexpected prompts: %+v (%v)\n", prompts, err)
+	}
+	verdict := askAsync(p, examplePromptPkt)
+	if err = ControlCall(path, "prompts", map[string]int64{"wait": 1}, &prompts); err != nil || len(prompts) != 1 {
+		t.Fatalf("Unexpected prompts: %+v (%v)\n", prompts, err)
+	}
+	if err = ControlCall(path, "answer", PromptAnswer{ID: prompts[0].ID, Verdict: "allow", Scope: ScopeOnce}, nil); err != nil {
+		t.Fatalf("Unable to answer prompt: %s\n", err.Error())
+	}
+	if v := <-verdict; v != netfilter.NF_ACCEPT {
+		t.Fatalf("Unexpected verdict of answered packet: %d\n", v)
+	}
+}
@@ -0,0 +1,45 @@
+package goaway2
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+/***Variables***/
+
+//RejectMark : firewall mark of the RSTs resetting flows whose data was dropped, iptables.sh copies
+// it to the connection (connmark) and rejects its later packets with a tcp-reset
+const RejectMark = 0x6762
+
+//Rejecter : resets tcp flows after one of their data packets was dropped, the retransmission of
+// the packet passes the connbytes window of iptables.sh without being queued otherwise
+type Rejecter struct {
+	Sender TarpitSender // sender of the RSTs (raw ip socket marked with RejectMark if nil)
+
+	lock sync.Mutex
+}
+
+/***Methods***/
+
+//(*Rejecter).Reject : reset the flow of the dropped data packet towards its destination (false if
+// the packet is no tcp data packet), the RST takes the place of the dropped segment
+func (r *Rejecter) Reject(pkt *PacketData) (bool, error) {
+	if r == nil || !pkt.Payload || pkt.Syn || !strings.EqualFold(pkt.Protocol, "tcp") {
+		return false, nil
+	}
+	r.lock.Lock()
+	if r.Sender == nil {
+		r.Sender = &rawSender{mark: RejectMark}
+	}
+	sender := r.Sender
+	r.lock.Unlock()
+	packet, err := tcpPacket(pkt.SrcIP, pkt.DstIP, pkt.SrcPort, pkt.DstPort, pkt.Seq, pkt.Ack, 0x14) // RST|ACK
+	if err != nil {
+		return false, err
+	}
+	if err = sender.Send(net.ParseIP(pkt.DstIP), packet); err != nil {
+		return false, err
+	}
+	return true, nil
+}
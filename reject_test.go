@@ -0,0 +1,32 @@
+package goaway2
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+/***Unit-Tests***/
+
+func TestRejecter(t *testing.T) {
+	sender := &fakeSender{}
+	r := &Rejecter{Sender: sender}
+	pkt := flowPacket(40000, 1000, []byte("GET / HTTP/1.1\r\n"))
+	pkt.Ack = 5000
+	// check handshake packets and other protocols are not reset
+	for _, other := range []PacketData{{SrcIP: pkt.SrcIP, DstIP: pkt.DstIP, Protocol: "TCP"}, {SrcIP: pkt.SrcIP, DstIP: pkt.DstIP, Protocol: "UDP", Payload: true}} {
+		if ok, err := r.Reject(&other); ok || err != nil || sender.count() != 0 {
+			t.Fatalf("Reset packet that is no tcp data packet: %+v\n", other)
+		}
+	}
+	if ok, err := r.Reject(pkt); !ok || err != nil || sender.count() != 1 {
+		t.Fatalf("Unable to reset flow: %v\n", err)
+	}
+	// check the RST takes the place of the dropped segment towards the destination
+	tcp := sender.packets[0][ipv4HeaderSize:]
+	if binary.BigEndian.Uint16(tcp[0:]) != 40000 || binary.BigEndian.Uint16(tcp[2:]) != 443 {
+		t.Fatalf("Unexpected ports: % x\n", tcp)
+	}
+	if binary.BigEndian.Uint32(tcp[4:]) != 1000 || binary.BigEndian.Uint32(tcp[8:]) != 5000 || tcp[13] != 0x14 {
+		t.Fatalf("Unexpected sequence numbers/flags: % x\n", tcp)
+	}
+}
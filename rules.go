@@ -1,21 +1,21 @@
 package goaway2
 
 import (
+	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"time"
+
+	"goaway2/geoip"
+	"goaway2/profiles"
+	"goaway2/store"
 )
 
 /***Types***/
 
-//fwRaw : used to extract raw data via sql-table for rules
-type fwRaw struct {
-	Zone     string
-	FromIP   string
-	FromPort string
-	ToIP     string
-	ToPort   string
-}
+//countryPrefix : prefix of blacklist entries blocking every address of a country
+const countryPrefix = "country:"
 
 //strValidator : interface to allow for validation of different objects
 type strValidator interface {
@@ -34,29 +34,105 @@ type fwRule struct {
 	SrcPort intValidator
 	DstIP   strValidator
 	DstPort intValidator
+	// interfaces the packet is received on/sent out of
+	InIface  strValidator
+	OutIface strValidator
+	// tls server name/http host the flow requests (blank for any)
+	SNI  hostname
+	Host hostname
+	// user/group/executable owning the local socket
+	Owner owner
+	// countries the source/destination address is located in
+	SrcCountry country
+	DstCountry country
+	// ports of the profile the rule refers to (nil without a profile)
+	Profile services
+	// allow/deny/tarpit matching packets regardless of the default (blank to follow the default)
+	Action string
+	// audit-only rules never drop packets
+	Audit bool
+	// raw rule data used to describe rule
+	raw store.Rule
 }
 
 //dfaults : contains variables relating to firewall options/defaults
 type dfaults struct {
 	inbound  string
 	outbound string
+	forward  string
+	// outbound prompts time out to askVerdict after askTimeout
+	askTimeout time.Duration
+	askVerdict string
+	// blacklisted sources are dropped or tarpitted (drop/tarpit) in at most tarpitFlows flows
+	blacklistAction string
+	tarpitFlows     int
+	// audit mode of the whole firewall and the time audit entries are kept (0 forever)
+	audit          bool
+	auditRetention time.Duration
+}
+
+//fwZone : rules and defaults of a named zone that interfaces are bound to
+type fwZone struct {
+	name     string
+	rules    []*fwRule
+	defaults *dfaults
 }
 
-//zone : validator for rule zone (inbound/outbound/any)
+//zone : validator for rule zone (inbound/outbound/forward/any)
 type zone string
 
+//hostname : validator of hostname/wildcard hostname requested by a flow (blank for any)
+type hostname string
+
+//owner : validator of the user/group/executable owning the local socket of a packet (blank for any)
+type owner struct {
+	uid string
+	gid string
+	exe string
+}
+
+//country : validator of the country an ip-address is located in (blank for any)
+type country struct {
+	code string
+	geo  *geoip.DB
+}
+
+//iface : validator of network interface for rules (blank for any)
+type iface string
+
 //ip : valiator of single ip for rules
 type ip string
 
+//host : validator of hostname/wildcard hostname for rules using the addresses learned by the dns cache
+type host struct {
+	pattern string
+	dns     *DNSCache
+}
+
+//asn : validator of the autonomous system an ip-address is announced by
+type asn struct {
+	number string
+	db     *geoip.DB
+}
+
 //ipRange : validator of ip-range for rules
 type ipRange struct {
 	net.IPNet
 }
 
+//service : validator of a single profile port restricted to a protocol
+type service struct {
+	ports    intValidator
+	protocol string // blank for any
+}
+
+//services : validator of profile ports for rules
+type services []service
+
 //port : validator of single port for rules
 type port int64
 
-//portRange : validator of port-range for rules
+//portRange : validator of port-range for rules (both bounds included like ufw/iptables ranges)
 type portRange struct {
 	start int64
 	end   int64
@@ -73,6 +149,17 @@ func convertIPs(rawips string) strValidator {
 	}
 }
 
+//convertAddrs : convert ip/ip-range/hostname/autonomous system to validator for rules
+func convertAddrs(raw string, dns *DNSCache, asns *geoip.DB) strValidator {
+	if number, err := geoip.ParseASN(raw); err == nil {
+		return asn{number: number, db: asns}
+	}
+	if IsHostPattern(raw) {
+		return host{pattern: canonicalHost(raw), dns: dns}
+	}
+	return convertIPs(raw)
+}
+
 //convertPorts : convert port/port-range to validator for rules
 func convertPorts(rawports string) intValidator {
 	switch {
@@ -93,34 +180,159 @@ func convertPorts(rawports string) intValidator {
 	}
 }
 
+//convertProfile : convert profile ports to validator for rules
+func convertProfile(p *profiles.Profile) services {
+	svcs := make(services, 0, len(p.Ports))
+	for _, port := range p.Ports {
+		svc := service{ports: convertPorts(port.Ports)}
+		if port.Protocol != "any" {
+			svc.protocol = strings.ToUpper(port.Protocol)
+		}
+		svcs = append(svcs, svc)
+	}
+	return svcs
+}
+
+//ifaceName : describe interface of rule (blank for any)
+func ifaceName(name string) string {
+	if name == "" {
+		return "any"
+	}
+	return name
+}
+
+//countryName : describe country of rule (blank for any)
+func countryName(code string) string {
+	if code == "" {
+		return "any"
+	}
+	return code
+}
+
+//CountryEntry : return the blacklist entry of a country code ("" for an unknown country)
+func CountryEntry(code string) string {
+	if code == "" {
+		return ""
+	}
+	return countryPrefix + code
+}
+
+//CountryOfEntry : return country code of a blacklist country entry ("" for ip-address entries)
+func CountryOfEntry(entry string) string {
+	if !strings.HasPrefix(entry, countryPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(entry, countryPrefix)
+}
+
 /***Methods***/
 
 //(*fwRule).Validate : validate if packet data matches rule data validators
 func (r *fwRule) Validate(pkt *PacketData) bool {
-	if r.Zone.Validate(pkt.SrcIP) &&
+	return r.validateFlow(pkt) && r.SNI.Validate(pkt.SNI) && r.Host.Validate(pkt.Host)
+}
+
+//(*fwRule).validateFlow : validate if packet data matches every rule data validator but the sni/host
+func (r *fwRule) validateFlow(pkt *PacketData) bool {
+	if (r.Profile == nil || r.Profile.Validate(pkt)) && r.Zone.Validate(pkt.Direction()) &&
 		r.SrcIP.Validate(pkt.SrcIP) && r.SrcPort.Validate(pkt.SrcPort) &&
-		r.DstIP.Validate(pkt.DstIP) && r.DstPort.Validate(pkt.DstPort) {
+		r.DstIP.Validate(pkt.DstIP) && r.DstPort.Validate(pkt.DstPort) &&
+		r.InIface.Validate(pkt.InIface) && r.OutIface.Validate(pkt.OutIface) &&
+		r.SrcCountry.Validate(pkt.SrcIP) && r.DstCountry.Validate(pkt.DstIP) &&
+		// the owner is looked up last and only for rules with an owner
+		(r.Owner == owner{} || r.Owner.Validate(pkt.LocalOwner())) {
 		return true
 	}
 	return false
 }
 
-//(zone).Validate : match ip-address to direction of zone (inbound/outbound/any)
-func (z zone) Validate(srcip string) bool {
-	switch z {
-	case "inbound":
-		if _, ok := localIPs[srcip]; ok {
-			return false
-		}
-		return true
+//(*fwRule).needsPayload : check if rule can only be evaluated on the first data packets of a flow
+func (r *fwRule) needsPayload() bool {
+	return r.SNI != "" || r.Host != ""
+}
+
+//(*fwRule).String : describe rule using the raw data it was built from
+func (r *fwRule) String() string {
+	desc := fmt.Sprintf(
+		"zone=%s src=%s:%s dst=%s:%s",
+		r.raw.Zone, r.raw.FromIP, r.raw.FromPort, r.raw.ToIP, r.raw.ToPort,
+	)
+	if r.raw.InIface != "" || r.raw.OutIface != "" {
+		desc += fmt.Sprintf(" iface=%s>%s", ifaceName(r.raw.InIface), ifaceName(r.raw.OutIface))
+	}
+	if r.raw.SNI != "" {
+		desc += " sni=" + r.raw.SNI
+	}
+	if r.raw.Host != "" {
+		desc += " host=" + r.raw.Host
+	}
+	if r.raw.UID != "" {
+		desc += " uid=" + r.raw.UID
+	}
+	if r.raw.GID != "" {
+		desc += " gid=" + r.raw.GID
+	}
+	if r.raw.Exe != "" {
+		desc += " exe=" + r.raw.Exe
+	}
+	if r.raw.SrcCountry != "" || r.raw.DstCountry != "" {
+		desc += fmt.Sprintf(" country=%s>%s", countryName(r.raw.SrcCountry), countryName(r.raw.DstCountry))
+	}
+	if r.raw.Action != "" {
+		desc += " action=" + r.raw.Action
+	}
+	if r.raw.Profile != "" {
+		desc += " profile=" + r.raw.Profile
+	}
+	if r.Audit {
+		desc += " (audit)"
+	}
+	return desc
+}
+
+//(*dfaults).direction : return default for the given packet direction
+func (d *dfaults) direction(direction string) string {
+	switch direction {
 	case "outbound":
-		if _, ok := localIPs[srcip]; ok {
-			return true
-		}
-		return false
+		return d.outbound
+	case "forward":
+		return d.forward
 	default:
+		return d.inbound
+	}
+}
+
+//(zone).Validate : match packet direction to direction of zone (inbound/outbound/forward/any)
+func (z zone) Validate(direction string) bool {
+	return z == "any" || string(z) == direction
+}
+
+//(hostname).Validate : match requested hostname to hostname/wildcard hostname
+func (h hostname) Validate(name string) bool {
+	return h == "" || matchHost(string(h), name)
+}
+
+//(owner).Validate : match owner of the packets socket (unknown owners only match rules without an owner)
+func (o owner) Validate(own *Owner) bool {
+	if o == (owner{}) {
 		return true
 	}
+	if own == nil {
+		return false
+	}
+	return (o.uid == "" || o.uid == strconv.FormatInt(own.UID, 10)) &&
+		(o.gid == "" || o.gid == strconv.FormatInt(own.GID, 10)) &&
+		(o.exe == "" || o.exe == own.Exe)
+}
+
+//(country).Validate : match country of ip-address (addresses of unknown countries only match rules without a country)
+func (c country) Validate(ip string) bool {
+	return c.code == "" || c.geo.Lookup(ip) == c.code
+}
+
+//(iface).Validate : match interface name to other interface name
+func (i iface) Validate(name string) bool {
+	return i == "" || string(i) == name
 }
 
 //(ip).Validate : match ip-address to other ip-address
@@ -135,17 +347,37 @@ func (a ip) Validate(ip string) bool {
 	}
 }
 
+//(host).Validate : match ip-address to the addresses currently known for the hostname
+func (h host) Validate(ip string) bool {
+	return h.dns.Match(h.pattern, ip, time.Now())
+}
+
+//(asn).Validate : match autonomous system of ip-address to the autonomous system of the rule
+func (a asn) Validate(ip string) bool {
+	return a.db.Lookup(ip) == a.number
+}
+
 //(ipRange).Validate : match ip-range to other ip-address
 func (a ipRange) Validate(ip string) bool {
 	return a.Contains(net.ParseIP(ip))
 }
 
+//(services).Validate : match packet destination port and protocol to any of the profile ports
+func (s services) Validate(pkt *PacketData) bool {
+	for _, svc := range s {
+		if (svc.protocol == "" || svc.protocol == pkt.Protocol) && svc.ports.Validate(pkt.DstPort) {
+			return true
+		}
+	}
+	return false
+}
+
 //(port).Validate : match port number to other port number
 func (p port) Validate(portnum int64) bool {
 	return int64(p) == portnum
 }
 
-//(portRange).Validate : match port to see if its within the port range
+//(portRange).Validate : match port to see if its within the port range (bounds included)
 func (p portRange) Validate(port int64) bool {
-	return p.start < port && port < p.end
+	return p.start <= port && port <= p.end
 }
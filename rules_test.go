@@ -5,11 +5,13 @@ import "testing"
 /***Variables***/
 
 var exampleRule = &fwRule{
-	Zone:    zone("any"),
-	SrcIP:   convertIPs("192.168.200.114"),
-	SrcPort: convertPorts("any"),
-	DstIP:   convertIPs("8.8.8.8"),
-	DstPort: convertPorts("53"),
+	Zone:     zone("any"),
+	SrcIP:    convertIPs("192.168.200.114"),
+	SrcPort:  convertPorts("any"),
+	DstIP:    convertIPs("8.8.8.8"),
+	DstPort:  convertPorts("53"),
+	InIface:  iface(""),
+	OutIface: iface(""),
 }
 var examplePktData = &PacketData{
 	SrcIP:   "192.168.200.114",
@@ -51,3 +53,23 @@ func TestRuleVerification(t *testing.T) {
 		t.Fatalf("Unable to validate packet against rule!\n")
 	}
 }
+
+func TestPortRange(t *testing.T) {
+	for _, check := range []struct {
+		ports string
+		port  int64
+		match bool
+	}{
+		// ranges include both bounds like the ufw/iptables ranges they are imported from
+		{"5000-5010", 5000, true},
+		{"5000-5010", 5010, true},
+		{"5000-5010", 4999, false},
+		{"5000-5010", 5011, false},
+		{"any", 0, true},
+		{"any", 65535, true},
+	} {
+		if match := convertPorts(check.ports).Validate(check.port); match != check.match {
+			t.Fatalf("Unexpected match of %d against %s: %t\n", check.port, check.ports, match)
+		}
+	}
+}
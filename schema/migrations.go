@@ -0,0 +1,337 @@
+package schema
+
+import "database/sql"
+
+/***Variables***/
+
+//migrations : all database migrations in the order they must be applied
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "base layout",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS rules (
+				  RuleNum INT NOT NULL,
+				  Zone TEXT NOT NULL,
+				  FromIP TEXT NOT NULL,
+				  FromPort TEXT NOT NULL,
+				  ToIP TEXT NOT NULL,
+				  ToPort TEXT NOT NULL
+				);`,
+				`CREATE TABLE IF NOT EXISTS ruleopts (
+				  Inbound TEXT NOT NULL,
+				  Outbound TEXT NOT NULL
+				);`,
+				`INSERT INTO ruleopts SELECT 'deny', 'allow' WHERE NOT EXISTS (SELECT 1 FROM ruleopts);`,
+				`CREATE TABLE IF NOT EXISTS whitelist (
+				  IPAddress TEXT NOT NULL,
+				  EntryDate TEXT NOT NULL,
+				  Reason TEXT NOT NULL,
+				  LogicalDelete INT NOT NULL
+				);`,
+				`CREATE INDEX IF NOT EXISTS whitelist_1 ON whitelist (LogicalDelete, IPAddress);`,
+				`CREATE TABLE IF NOT EXISTS blacklist (
+				  IPAddress TEXT NOT NULL,
+				  EntryDate TEXT NOT NULL,
+				  LastSeen TEXT NOT NULL,
+				  Reason TEXT NOT NULL,
+				  LogicalDelete INT NOT NULL
+				);`,
+				`CREATE INDEX IF NOT EXISTS blacklist_1 ON blacklist (LogicalDelete, IPAddress);`,
+			)
+		},
+	},
+	{
+		version: 2,
+		name:    "rule audit mode",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "rules", "Audit", "INT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS auditlog (
+				  EntryDate TEXT NOT NULL,
+				  Protocol TEXT NOT NULL,
+				  FromIP TEXT NOT NULL,
+				  FromPort INT NOT NULL,
+				  ToIP TEXT NOT NULL,
+				  ToPort INT NOT NULL,
+				  Reason TEXT NOT NULL,
+				  RuleNum INT NOT NULL,
+				  Rule TEXT NOT NULL
+				);`,
+			)
+		},
+	},
+	{
+		version: 3,
+		name:    "change history",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS history (
+				  Batch INT NOT NULL,
+				  EntryDate TEXT NOT NULL,
+				  User TEXT NOT NULL,
+				  TableName TEXT NOT NULL,
+				  Action TEXT NOT NULL,
+				  OldValue TEXT NOT NULL,
+				  NewValue TEXT NOT NULL,
+				  Undone INT NOT NULL DEFAULT 0
+				);`,
+				`CREATE INDEX IF NOT EXISTS history_1 ON history (Batch, Undone);`,
+			)
+		},
+	},
+	{
+		version: 4,
+		name:    "provisional changes",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS pending (
+				  Batch INT PRIMARY KEY NOT NULL,
+				  Deadline INT NOT NULL
+				);`,
+			)
+		},
+	},
+	{
+		version: 5,
+		name:    "rule profiles",
+		up: func(tx *sql.Tx) error {
+			return addColumn(tx, "rules", "Profile", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 6,
+		name:    "interface zones",
+		up: func(tx *sql.Tx) error {
+			if err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS zones (
+				  Name TEXT PRIMARY KEY NOT NULL,
+				  Inbound TEXT NOT NULL,
+				  Outbound TEXT NOT NULL
+				);`,
+				`CREATE TABLE IF NOT EXISTS zoneifaces (
+				  Interface TEXT PRIMARY KEY NOT NULL,
+				  NetZone TEXT NOT NULL
+				);`,
+				// predefined zones without any interfaces bound to them
+				`INSERT OR IGNORE INTO zones VALUES
+				  ('public','deny','allow'),
+				  ('internal','deny','allow'),
+				  ('trusted','allow','allow'),
+				  ('drop','deny','deny');`,
+			); err != nil {
+				return err
+			}
+			return addColumn(tx, "rules", "NetZone", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 7,
+		name:    "forwarding",
+		up: func(tx *sql.Tx) error {
+			// forwarding is denied until the host is configured as a router
+			if err := addColumn(tx, "ruleopts", "Forward", "TEXT NOT NULL DEFAULT 'deny'"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "rules", "InIface", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return addColumn(tx, "rules", "OutIface", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 8,
+		name:    "nat",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS portforwards (
+				  Interface TEXT NOT NULL,
+				  Protocol TEXT NOT NULL,
+				  Port TEXT NOT NULL,
+				  ToIP TEXT NOT NULL,
+				  ToPort TEXT NOT NULL,
+				  PRIMARY KEY (Interface, Protocol, Port)
+				);`,
+				`CREATE TABLE IF NOT EXISTS masquerades (
+				  Interface TEXT PRIMARY KEY NOT NULL,
+				  Source TEXT NOT NULL
+				);`,
+			)
+		},
+	},
+	{
+		version: 9,
+		name:    "application hosts",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "rules", "SNI", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return addColumn(tx, "rules", "Host", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 10,
+		name:    "socket owners",
+		up: func(tx *sql.Tx) error {
+			for _, column := range []string{"UID", "GID", "Exe"} {
+				if err := addColumn(tx, "rules", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 11,
+		name:    "prompts",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "ruleopts", "AskTimeout", "INTEGER NOT NULL DEFAULT 30"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "ruleopts", "AskVerdict", "TEXT NOT NULL DEFAULT 'deny'"); err != nil {
+				return err
+			}
+			return addColumn(tx, "rules", "Action", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 12,
+		name:    "countries",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "rules", "SrcCountry", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return addColumn(tx, "rules", "DstCountry", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 13,
+		name:    "feeds",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS feeds (
+				  Name TEXT PRIMARY KEY NOT NULL,
+				  Source TEXT NOT NULL,
+				  Interval INTEGER NOT NULL DEFAULT 3600,
+				  Entries INTEGER NOT NULL DEFAULT 0,
+				  LastRefresh TEXT NOT NULL DEFAULT '',
+				  LastError TEXT NOT NULL DEFAULT ''
+				);`,
+			)
+		},
+	},
+	{
+		version: 14,
+		name:    "knocks",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS knocks (
+				  Name TEXT PRIMARY KEY NOT NULL,
+				  Sequence TEXT NOT NULL,
+				  Window INTEGER NOT NULL DEFAULT 10,
+				  Target TEXT NOT NULL,
+				  Duration INTEGER NOT NULL DEFAULT 300
+				);`,
+			)
+		},
+	},
+	{
+		version: 15,
+		name:    "spa",
+		up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS spa (
+				  Name TEXT PRIMARY KEY NOT NULL,
+				  Port INTEGER NOT NULL,
+				  Key TEXT NOT NULL,
+				  Access TEXT NOT NULL,
+				  Duration INTEGER NOT NULL DEFAULT 300,
+				  MaxAge INTEGER NOT NULL DEFAULT 30
+				);`,
+			)
+		},
+	},
+	{
+		version: 16,
+		name:    "tarpit",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "ruleopts", "BlacklistAction", "TEXT NOT NULL DEFAULT 'drop'"); err != nil {
+				return err
+			}
+			return addColumn(tx, "ruleopts", "TarpitFlows", "INTEGER NOT NULL DEFAULT 1024")
+		},
+	},
+	{
+		version: 17,
+		name:    "honeypots",
+		up: func(tx *sql.Tx) error {
+			if err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS honeypots (
+				  Port INTEGER NOT NULL,
+				  Protocol TEXT NOT NULL,
+				  Expiry INTEGER NOT NULL DEFAULT 86400,
+				  PRIMARY KEY (Port, Protocol)
+				);`,
+			); err != nil {
+				return err
+			}
+			// blacklist entries of honeypots expire (blank never expires)
+			return addColumn(tx, "blacklist", "Expires", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version: 18,
+		name:    "global audit mode",
+		up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "ruleopts", "Audit", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			// audit entries are kept for 30 days by default (0 keeps them forever)
+			return addColumn(tx, "ruleopts", "AuditRetention", "INTEGER NOT NULL DEFAULT 2592000")
+		},
+	},
+	{
+		version: 19,
+		name:    "prompt answers",
+		up: func(tx *sql.Tx) error {
+			// outbound rules the daemon writes for prompts answered forever (kept out of the history)
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS answers (
+				  AnswerNum INTEGER PRIMARY KEY,
+				  ToIP TEXT NOT NULL,
+				  ToPort TEXT NOT NULL,
+				  UID TEXT NOT NULL DEFAULT '',
+				  Exe TEXT NOT NULL DEFAULT '',
+				  Action TEXT NOT NULL
+				);`,
+			)
+		},
+	},
+	{
+		version: 20,
+		name:    "honeypot verification",
+		up: func(tx *sql.Tx) error {
+			// honeypots trip on a verified tcp handshake unless tripping on the first packet is opted in
+			return addColumn(tx, "honeypots", "Unverified", "INTEGER NOT NULL DEFAULT 0")
+		},
+	},
+	{
+		version: 21,
+		name:    "inclusive port ranges",
+		up: func(tx *sql.Tx) error {
+			// port ranges include their bounds from now on, so stored ranges shrink by one port
+			// on either side to keep matching the ports they matched before
+			var statements []string
+			for _, column := range []string{"FromPort", "ToPort"} {
+				statements = append(statements, `UPDATE rules SET `+column+` =
+				  (CAST(substr(`+column+`, 1, instr(`+column+`, '-') - 1) AS INTEGER) + 1) || '-' ||
+				  (CAST(substr(`+column+`, instr(`+column+`, '-') + 1) AS INTEGER) - 1)
+				  WHERE `+column+` GLOB '[0-9]*-[0-9]*';`)
+			}
+			return execAll(tx, statements...)
+		},
+	},
+}
@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+/***Variables***/
+
+//migration : ordered up-migration of the database layout
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+/***Functions***/
+
+//Latest : return the schema version of the latest migration
+func Latest() int {
+	return migrations[len(migrations)-1].version
+}
+
+//Version : return the schema version of the given database
+func Version(db *sql.DB) (int, error) {
+	if _, err := db.Exec(
+		"CREATE TABLE IF NOT EXISTS schema_version (Version INT NOT NULL, AppliedDate TEXT NOT NULL);",
+	); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow("SELECT IFNULL(max(Version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+//Migrate : apply all migrations newer than the databases schema version
+func Migrate(db *sql.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return fmt.Errorf("unable to collect schema version: %s", err.Error())
+	}
+	if version > Latest() {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, Latest())
+	}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err = apply(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %s", m.version, m.name, err.Error())
+		}
+	}
+	return nil
+}
+
+//apply : run migration and record its version within a single transaction
+func apply(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = m.up(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.Exec("INSERT INTO schema_version VALUES (?, datetime('now'));", m.version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//execAll : execute all given statements in order
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//hasColumn : check if column exists within table
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query("PRAGMA table_info(" + table + ");")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var (
+		cid, notnull, pk int
+		name, ctype      string
+		dflt             sql.NullString
+	)
+	for rows.Next() {
+		if err = rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+//addColumn : add column to table unless it already exists
+// databases created before versioning may already contain the column
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition + ";")
+	return err
+}
@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3" //mysql-driver
+)
+
+/***Functions***/
+
+//openFixture : open a copy of the given database fixture
+func openFixture(t *testing.T, name string) (*sql.DB, func()) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Unable to read fixture: %s\n", err.Error())
+	}
+	dir, err := ioutil.TempDir("", "goaway-schema")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s\n", err.Error())
+	}
+	path := filepath.Join(dir, name)
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Unable to copy fixture: %s\n", err.Error())
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Unable to open fixture: %s\n", err.Error())
+	}
+	db.SetMaxOpenConns(1)
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+//checkLatest : verify database was migrated to the latest layout
+func checkLatest(t *testing.T, db *sql.DB) {
+	version, err := Version(db)
+	if err != nil {
+		t.Fatalf("Unable to collect version: %s\n", err.Error())
+	}
+	if version != Latest() {
+		t.Fatalf("Database at version %d, expected %d\n", version, Latest())
+	}
+	if _, err = db.Exec("INSERT INTO rules (RuleNum,Zone,FromIP,FromPort,ToIP,ToPort,Audit) VALUES (9,'any','any','any','any','22',1);"); err != nil {
+		t.Fatalf("Unable to insert audited rule: %s\n", err.Error())
+	}
+	if _, err = db.Exec("SELECT 1 FROM auditlog;"); err != nil {
+		t.Fatalf("Missing auditlog table: %s\n", err.Error())
+	}
+}
+
+/***Unit-Tests***/
+
+func TestMigrateFixture(t *testing.T) {
+	db, cleanup := openFixture(t, "database-v0.db")
+	defer cleanup()
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Unable to migrate fixture: %s\n", err.Error())
+	}
+	checkLatest(t, db)
+	// check existing data survived the migration
+	var rules int
+	var inbound string
+	db.QueryRow("SELECT count(*) FROM rules WHERE Audit=0").Scan(&rules)
+	db.QueryRow("SELECT Inbound FROM ruleopts").Scan(&inbound)
+	if rules != 2 || inbound != "deny" {
+		t.Fatalf("Unexpected data after migration: rules=%d inbound=%q\n", rules, inbound)
+	}
+	// check migrating again is a no-op
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Unable to re-run migrations: %s\n", err.Error())
+	}
+	var applied int
+	db.QueryRow("SELECT count(*) FROM schema_version").Scan(&applied)
+	if applied != len(migrations) {
+		t.Fatalf("Unexpected applied migrations: %d\n", applied)
+	}
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open database: %s\n", err.Error())
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err = Migrate(db); err != nil {
+		t.Fatalf("Unable to migrate empty database: %s\n", err.Error())
+	}
+	checkLatest(t, db)
+	var inbound, outbound string
+	if err = db.QueryRow("SELECT Inbound, Outbound FROM ruleopts").Scan(&inbound, &outbound); err != nil {
+		t.Fatalf("Missing default rule options: %s\n", err.Error())
+	}
+	// check fresh databases get the defaults of the shipped database
+	if inbound != "deny" || outbound != "allow" {
+		t.Fatalf("Unexpected default rule options: inbound=%q outbound=%q\n", inbound, outbound)
+	}
+}
+
+func TestMigratePortRanges(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open database: %s\n", err.Error())
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err = Version(db); err != nil {
+		t.Fatalf("Unable to collect version: %s\n", err.Error())
+	}
+	for _, m := range migrations {
+		if m.version >= 21 {
+			break
+		}
+		if err = apply(db, m); err != nil {
+			t.Fatalf("Unable to apply migration %d: %s\n", m.version, err.Error())
+		}
+	}
+	if _, err = db.Exec("INSERT INTO rules (RuleNum,Zone,FromIP,FromPort,ToIP,ToPort) VALUES (1,'any','any','1000-2000','any','5000-5010'),(2,'any','any','any','any','22');"); err != nil {
+		t.Fatalf("Unable to insert rules: %s\n", err.Error())
+	}
+	if err = Migrate(db); err != nil {
+		t.Fatalf("Unable to migrate database: %s\n", err.Error())
+	}
+	// check stored ranges keep matching the ports they matched while bounds were excluded
+	for _, check := range []struct {
+		num              int
+		fromPort, toPort string
+	}{
+		{1, "1001-1999", "5001-5009"},
+		{2, "any", "22"},
+	} {
+		var fromPort, toPort string
+		db.QueryRow("SELECT FromPort, ToPort FROM rules WHERE RuleNum=?", check.num).Scan(&fromPort, &toPort)
+		if fromPort != check.fromPort || toPort != check.toPort {
+			t.Fatalf("Unexpected ports of rule #%d: %s -> %s\n", check.num, fromPort, toPort)
+		}
+	}
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	for n, m := range migrations {
+		if m.version != n+1 {
+			t.Fatalf("Migration %q has version %d, expected %d\n", m.name, m.version, n+1)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package goaway2
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/***Variables***/
+
+//single packet authorization limits
+const (
+	spaVersion     = "goaway-spa1"
+	spaNonceSize   = 16
+	minSPAKeySize  = 16
+	spaKeySize     = 32
+	maxSPAPayload  = 512
+	spaAnySourceIP = "-" // grant the access to the source of the packet (clients behind nat)
+)
+
+//SPAProfile : udp port receiving hmac signed packets that open the requested ports for their source
+type SPAProfile struct {
+	Name     string
+	Port     int64       // udp port the packets are sent to (never open itself)
+	Key      []byte      // hmac-sha256 key shared with the clients
+	Access   []KnockPort // ports that may be requested
+	Duration time.Duration
+	MaxAge   time.Duration // time the timestamp of a packet may differ from the local time
+}
+
+//SPARequest : access requested by a single packet authorization packet
+type SPARequest struct {
+	Timestamp time.Time
+	Nonce     string // random hex string, each nonce is accepted once
+	AllowIP   string // address the access is granted to (blank for the source of the packet)
+	Access    KnockPort
+}
+
+/***Functions***/
+
+//GenerateSPAKey : create random base64 encoded hmac key
+func GenerateSPAKey() (string, error) {
+	key := make([]byte, spaKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+//ParseSPAKey : decode base64 encoded hmac key
+func ParseSPAKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(key) < minSPAKeySize {
+		return nil, fmt.Errorf("invalid spa key: expected base64 encoded key of at least %d bytes", minSPAKeySize)
+	}
+	return key, nil
+}
+
+//NewSPARequest : create request with the current time and a random nonce
+func NewSPARequest(access KnockPort, allowIP string, now time.Time) (SPARequest, error) {
+	nonce := make([]byte, spaNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return SPARequest{}, err
+	}
+	return SPARequest{Timestamp: now, Nonce: hex.EncodeToString(nonce), AllowIP: allowIP, Access: access}, nil
+}
+
+//spaSign : return hex encoded hmac-sha256 of the message
+func spaSign(key []byte, message string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//EncodeSPA : build the signed payload of a request
+// "goaway-spa1 <unix-time> <nonce> <allow-ip|-> <port/proto> <hmac>" where the hmac signs all other fields
+func EncodeSPA(key []byte, r SPARequest) []byte {
+	allowIP := r.AllowIP
+	if allowIP == "" {
+		allowIP = spaAnySourceIP
+	}
+	message := strings.Join([]string{
+		spaVersion, strconv.FormatInt(r.Timestamp.Unix(), 10), r.Nonce, allowIP, r.Access.String(),
+	}, " ")
+	return []byte(message + " " + spaSign(key, message))
+}
+
+//DecodeSPA : verify the hmac of a payload and return the request it carries
+func DecodeSPA(key []byte, payload []byte) (SPARequest, error) {
+	var r SPARequest
+	if len(payload) > maxSPAPayload {
+		return r, fmt.Errorf("payload too large")
+	}
+	fields := strings.Split(string(payload), " ")
+	if len(fields) != 6 || fields[0] != spaVersion {
+		return r, fmt.Errorf("not a spa packet")
+	}
+	message := strings.Join(fields[:5], " ")
+	if !hmac.Equal([]byte(spaSign(key, message)), []byte(fields[5])) {
+		return r, fmt.Errorf("invalid hmac")
+	}
+	// fields are signed, errors below are only caused by broken clients
+	unix, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return r, fmt.Errorf("invalid timestamp: %q", fields[1])
+	}
+	if nonce, err := hex.DecodeString(fields[2]); err != nil || len(nonce) != spaNonceSize {
+		return r, fmt.Errorf("invalid nonce: %q", fields[2])
+	}
+	r.Timestamp, r.Nonce = time.Unix(unix, 0), fields[2]
+	if fields[3] != spaAnySourceIP {
+		if net.ParseIP(fields[3]).To4() == nil {
+			return r, fmt.Errorf("invalid allow ip: %q", fields[3])
+		}
+		r.AllowIP = fields[3]
+	}
+	if r.Access, err = ParseKnockPort(fields[4]); err != nil {
+		return r, err
+	}
+	return r, nil
+}
@@ -0,0 +1,161 @@
+package goaway2
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Variables***/
+
+//spaTestKey : base64 encoded key shared by the spa tests
+const spaTestKey = "c3BhLXRlc3Qta2V5LXNwYS10ZXN0LWtleS1zcGEtdGVzdA=="
+
+/***Functions***/
+
+//spaPacket : build spa packet of source requesting access signed with the given key
+func spaPacket(t *testing.T, key []byte, src string, access KnockPort, allowIP string, now time.Time) *PacketData {
+	req, err := NewSPARequest(access, allowIP, now)
+	if err != nil {
+		t.Fatalf("Unable to create spa request: %s\n", err.Error())
+	}
+	return &PacketData{SrcIP: src, SrcPort: 50000, DstIP: "192.168.200.114", DstPort: 62201, Protocol: "UDP", Hook: HookInput, Data: EncodeSPA(key, req)}
+}
+
+/***Unit-Tests***/
+
+func TestSPAPacket(t *testing.T) {
+	key, err := ParseSPAKey(spaTestKey)
+	if err != nil {
+		t.Fatalf("Unable to parse spa key: %s\n", err.Error())
+	}
+	ssh := KnockPort{Port: 22, Protocol: "tcp"}
+	now := time.Unix(1800000000, 0)
+	req, _ := NewSPARequest(ssh, "203.0.113.5", now)
+	payload := EncodeSPA(key, req)
+	decoded, err := DecodeSPA(key, payload)
+	if err != nil || decoded != req {
+		t.Fatalf("Unexpected decoded request: %+v (%v)\n", decoded, err)
+	}
+	// check tampered packets and packets of other keys are rejected
+	other, _ := GenerateSPAKey()
+	otherKey, _ := ParseSPAKey(other)
+	if _, err = DecodeSPA(otherKey, payload); err == nil {
+		t.Fatalf("Able to decode spa packet with another key\n")
+	}
+	for _, tamper := range [][]byte{
+		bytes.Replace(payload, []byte(" 22/tcp "), []byte(" 23/tcp "), 1),
+		bytes.Replace(payload, []byte(" 203.0.113.5 "), []byte(" 198.51.100.7 "), 1),
+		bytes.Replace(payload, []byte(" 1800000000 "), []byte(" 1800000100 "), 1),
+		payload[:len(payload)-1],
+		[]byte("GET / HTTP/1.1\r\n"),
+	} {
+		if _, err = DecodeSPA(key, tamper); err == nil {
+			t.Fatalf("Able to decode tampered spa packet: %q\n", tamper)
+		}
+	}
+	for _, bad := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err = ParseSPAKey(bad); err == nil {
+			t.Fatalf("Able to parse invalid spa key: %q\n", bad)
+		}
+	}
+}
+
+func TestKnockSPA(t *testing.T) {
+	key, _ := ParseSPAKey(spaTestKey)
+	ssh, https := KnockPort{Port: 22, Protocol: "tcp"}, KnockPort{Port: 443, Protocol: "tcp"}
+	k := NewKnocker(nil, []SPAProfile{{
+		Name: "spa", Port: 62201, Key: key, Access: []KnockPort{ssh}, Duration: 5 * time.Minute, MaxAge: 30 * time.Second,
+	}})
+	start := time.Now()
+	connect := func(src string, after time.Duration) KnockResult {
+		return k.Observe(&PacketData{SrcIP: src, SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 22, Protocol: "TCP", Hook: HookInput}, start.Add(after))
+	}
+	const a, b = "198.51.100.7", "203.0.113.5"
+	if r := connect(a, 0); r.Guard != "spa" || r.Open {
+		t.Fatalf("Guarded port was not closed: %+v\n", r)
+	}
+	pkt := spaPacket(t, key, a, ssh, "", start)
+	if r := k.Observe(pkt, start.Add(time.Second)); r.Completed != "spa" || r.Guard != "spa" || r.Rejected != "" {
+		t.Fatalf("Valid spa packet was not accepted: %+v\n", r)
+	}
+	if r := connect(a, 2*time.Second); !r.Open {
+		t.Fatalf("Requested port was not opened: %+v\n", r)
+	}
+	if r := connect(b, 2*time.Second); r.Open {
+		t.Fatalf("Requested port was opened for another source: %+v\n", r)
+	}
+	// check replayed packets are rejected even from another source
+	replay := *pkt
+	replay.SrcIP = b
+	if r := k.Observe(&replay, start.Add(3*time.Second)); r.Completed != "" || r.Rejected != "replayed nonce" {
+		t.Fatalf("Replayed spa packet was accepted: %+v\n", r)
+	}
+	if r := connect(b, 4*time.Second); r.Open {
+		t.Fatalf("Replayed spa packet opened the port: %+v\n", r)
+	}
+	for name, rejected := range map[string]*PacketData{
+		"stale":      spaPacket(t, key, b, ssh, "", start.Add(-time.Minute)),
+		"future":     spaPacket(t, key, b, ssh, "", start.Add(time.Minute)),
+		"not access": spaPacket(t, key, b, https, "", start),
+		"other key":  spaPacket(t, []byte("another-key-another-key"), b, ssh, "", start),
+	} {
+		if r := k.Observe(rejected, start.Add(5*time.Second)); r.Completed != "" || r.Rejected == "" {
+			t.Fatalf("Spa packet %s was accepted: %+v\n", name, r)
+		}
+	}
+	if r := connect(b, 6*time.Second); r.Open {
+		t.Fatalf("Rejected spa packet opened the port: %+v\n", r)
+	}
+	// check the access is granted to the signed address instead of the source
+	if r := k.Observe(spaPacket(t, key, a, ssh, b, start), start.Add(7*time.Second)); r.Completed != "spa" {
+		t.Fatalf("Spa packet with allow ip was not accepted: %+v\n", r)
+	}
+	if r := connect(b, 8*time.Second); !r.Open {
+		t.Fatalf("Requested port was not opened for the allow ip: %+v\n", r)
+	}
+	// check nonces are remembered as long as their timestamp is valid and openings expire
+	if r := k.Observe(&replay, start.Add(29*time.Second)); r.Rejected != "replayed nonce" {
+		t.Fatalf("Replayed spa packet was accepted: %+v\n", r)
+	}
+	if r := connect(a, 5*time.Minute+2*time.Second); r.Open {
+		t.Fatalf("Opened port did not expire: %+v\n", r)
+	}
+}
+
+func TestFirewallSPA(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow"})
+	st.AddSPA(store.SPA{Name: "spa", Port: 62201, Key: spaTestKey, Access: "22/tcp", Duration: 300, MaxAge: 30})
+	st.AddSPA(store.SPA{Name: "broken", Port: 62202, Key: "short", Access: "23/tcp", Duration: 300, MaxAge: 30})
+	fw := newFirewall(st, profiles.NewSet())
+	kv := NewRedBlackKV()
+	key, _ := ParseSPAKey(spaTestKey)
+	ssh := &PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 22, Protocol: "TCP", Hook: HookInput}
+	if d := fw.Decide(kv, ssh); d.Verdict != netfilter.NF_DROP || d.Reason != "knock" || d.Knock != "spa" {
+		t.Fatalf("Guarded port was not closed: %+v\n", d)
+	}
+	// the spa port itself stays closed
+	pkt := spaPacket(t, key, "198.51.100.7", KnockPort{Port: 22, Protocol: "tcp"}, "", time.Now())
+	if d := fw.Decide(kv, pkt); d.Verdict != netfilter.NF_DROP || d.Knocked != "spa" {
+		t.Fatalf("Unexpected decision of the spa packet: %+v\n", d)
+	}
+	if d := fw.Decide(kv, pkt); d.Verdict != netfilter.NF_DROP || d.Knocked != "" || d.Rejected == "" {
+		t.Fatalf("Unexpected decision of the replayed spa packet: %+v\n", d)
+	}
+	if d := fw.Decide(kv, ssh); d.Verdict != netfilter.NF_ACCEPT || d.Reason != "knock" {
+		t.Fatalf("Requested port was not opened: %+v\n", d)
+	}
+	if d := fw.Decide(kv, &PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 23, Protocol: "TCP", Hook: HookInput}); d.Reason == "knock" {
+		t.Fatalf("Port of an invalid profile was guarded: %+v\n", d)
+	}
+}
@@ -1,80 +1,241 @@
 package goaway2
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
 	"os"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3" //mysql-driver
+	"goaway2/geoip"
+	"goaway2/profiles"
+	"goaway2/store"
 )
 
-//TODO:50 need to change database location for when its compiled to something else
-
-/***Varaibles***/
-var db *sql.DB
-
 /***Functions***/
 
 //sqlLoadRules : load all firewall rules from database
-func sqlLoadRules() (fwRules []*fwRule) {
-	// do sql query
-	rows, err := db.Query("SELECT Zone,FromIP,FromPort,ToIP,ToPort FROM rules ORDER BY RuleNum")
+func sqlLoadRules(st *store.Store, set *profiles.Set, dns *DNSCache, geo, asns *geoip.DB) (fwRules []*fwRule, err error) {
+	rules, err := st.Rules()
 	if err != nil {
-		fmt.Printf("Unable to collect firewall Rules! SQL-Error: %s\n", err.Error())
-		os.Exit(1)
-	}
-	// fill rules with given data
-	var rec *fwRaw
-	for rows.Next() {
-		rec = new(fwRaw)
-		rows.Scan(&rec.Zone, &rec.FromIP, &rec.FromPort, &rec.ToIP, &rec.ToPort)
-		// build rule with types based on data from sql table
-		fwRules = append(fwRules, &fwRule{
-			Zone:    zone(rec.Zone),
-			SrcIP:   convertIPs(rec.FromIP),
-			SrcPort: convertPorts(rec.FromPort),
-			DstIP:   convertIPs(rec.ToIP),
-			DstPort: convertPorts(rec.ToPort),
-		})
-	}
-	rows.Close()
-	return fwRules
+		return nil, fmt.Errorf("Unable to collect firewall Rules! SQL-Error: %s", err.Error())
+	}
+	// build rules with types based on data from sql table
+	for _, r := range rules {
+		rule := sqlConvertRule(r, dns, geo, asns)
+		// resolve profile on every load so profile edits apply to all rules using it
+		// (a rule whose profile is gone refuses the load, skipping it could open what it denied)
+		if r.Profile != "" {
+			p, err := set.Lookup(r.Profile)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to load firewall Rule #%d! Profile-Error: %s", r.RuleNum, err.Error())
+			}
+			rule.Profile = convertProfile(p)
+		}
+		fwRules = append(fwRules, rule)
+	}
+	return fwRules, nil
 }
 
-//sqlLoadDefaults : load rule options into defaults
-func sqlLoadDefaults() *dfaults {
-	df := &dfaults{}
-	// do sql query and scan data
-	err := db.QueryRow("SELECT Inbound, OutBound FROM ruleopts LIMIT 1").Scan(&df.inbound, &df.outbound)
+//resolveHosts : resolve the hostnames of rules so they match before their first dns answer is observed
+func resolveHosts(rules []*fwRule, dns *DNSCache) {
+	for _, r := range rules {
+		for _, name := range []string{r.raw.FromIP, r.raw.ToIP} {
+			if !IsHostPattern(name) || strings.HasPrefix(name, "*.") {
+				continue
+			}
+			if err := dns.Resolve(name, time.Now()); err != nil {
+				fmt.Printf("Unable to resolve %q of firewall Rule #%d! DNS-Error: %s\n", name, r.raw.RuleNum, err.Error())
+			}
+		}
+	}
+}
+
+//sqlConvertRule : build rule with types based on data from sql table (without its profile)
+func sqlConvertRule(r store.Rule, dns *DNSCache, geo, asns *geoip.DB) *fwRule {
+	rule := &fwRule{
+		Zone:     zone(r.Zone),
+		SrcIP:    convertAddrs(r.FromIP, dns, asns),
+		SrcPort:  convertPorts(r.FromPort),
+		DstIP:    convertAddrs(r.ToIP, dns, asns),
+		DstPort:  convertPorts(r.ToPort),
+		InIface:  iface(r.InIface),
+		OutIface: iface(r.OutIface),
+		SNI:      hostname(canonicalHost(r.SNI)),
+		Host:     hostname(canonicalHost(r.Host)),
+		Owner:    owner{uid: r.UID, gid: r.GID, exe: r.Exe},
+		Action:   r.Action,
+		Audit:    r.Audit,
+		raw:      r,
+	}
+	rule.SrcCountry, rule.DstCountry = country{code: r.SrcCountry, geo: geo}, country{code: r.DstCountry, geo: geo}
+	return rule
+}
+
+//sqlLoadAnswers : load the outbound rules of prompts answered forever (consulted before prompting)
+func sqlLoadAnswers(st *store.Store, dns *DNSCache, geo, asns *geoip.DB) (fwRules []*fwRule, err error) {
+	answers, err := st.Answers()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to collect prompt Answers! SQL-Error: %s", err.Error())
+	}
+	for _, r := range answers {
+		fwRules = append(fwRules, sqlConvertRule(r, dns, geo, asns))
+	}
+	return fwRules, nil
+}
+
+//loadProfiles : load services and application profiles rules may refer to
+func loadProfiles() *profiles.Set {
+	set, err := profiles.Load(profiles.ServicesFile, profiles.AppsDir)
+	if err != nil {
+		fmt.Printf("Unable to load profiles! Profile-Error: %s\n", err.Error())
+		return profiles.NewSet()
+	}
+	return set
+}
+
+//loadGeoIP : load the country/asn database used by rules and blacklist entries (nil without one)
+func loadGeoIP(kind geoip.Kind, files []string) *geoip.DB {
+	db, err := geoip.Find(kind, files...)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Unable to load GeoIP database! GeoIP-Error: %s\n", err.Error())
+		}
+		return nil
+	}
+	return db
+}
+
+//sqlLoadZones : load zones and split rules into the global rule chain and the rules of each zone
+func sqlLoadZones(st *store.Store, rules []*fwRule) (global []*fwRule, ifaces map[string]*fwZone, err error) {
+	zones, err := st.NetZones()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to collect firewall zones! SQL-Error: %s", err.Error())
+	}
+	byName := make(map[string]*fwZone)
+	ifaces = make(map[string]*fwZone)
+	for _, z := range zones {
+		fz := &fwZone{name: z.Name, defaults: &dfaults{inbound: z.Inbound, outbound: z.Outbound}}
+		byName[z.Name] = fz
+		for _, iface := range z.Interfaces {
+			ifaces[iface] = fz
+		}
+	}
+	for _, r := range rules {
+		if r.raw.NetZone == "" {
+			global = append(global, r)
+			continue
+		}
+		fz, ok := byName[r.raw.NetZone]
+		if !ok {
+			fmt.Printf("Skipping firewall Rule #%d! Unknown zone: %q\n", r.raw.RuleNum, r.raw.NetZone)
+			continue
+		}
+		fz.rules = append(fz.rules, r)
+	}
+	return global, ifaces, nil
+}
+
+//sqlLoadKnocks : load knock and spa profiles guarding inbound ports
+func sqlLoadKnocks(st *store.Store) (*Knocker, error) {
+	knocks, err := st.Knocks()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to collect knock profiles! SQL-Error: %s", err.Error())
+	}
+	var profiles []KnockProfile
+	for _, k := range knocks {
+		profile := KnockProfile{
+			Name:     k.Name,
+			Window:   time.Duration(k.Window) * time.Second,
+			Duration: time.Duration(k.Duration) * time.Second,
+		}
+		if profile.Sequence, err = ParseKnockSequence(k.Sequence); err == nil {
+			profile.Target, err = ParseKnockPort(k.Target)
+		}
+		if err != nil {
+			fmt.Printf("Skipping knock profile %q! Knock-Error: %s\n", k.Name, err.Error())
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	spas, err := st.SPAs()
 	if err != nil {
-		fmt.Printf("Unable to collect firewall options! SQL-Error: %s\n", err.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("Unable to collect spa profiles! SQL-Error: %s", err.Error())
+	}
+	var spaProfiles []SPAProfile
+	for _, a := range spas {
+		profile := SPAProfile{
+			Name:     a.Name,
+			Port:     a.Port,
+			Duration: time.Duration(a.Duration) * time.Second,
+			MaxAge:   time.Duration(a.MaxAge) * time.Second,
+		}
+		if profile.Key, err = ParseSPAKey(a.Key); err == nil {
+			profile.Access, err = ParseKnockAccess(a.Access)
+		}
+		if err != nil {
+			fmt.Printf("Skipping spa profile %q! Knock-Error: %s\n", a.Name, err.Error())
+			continue
+		}
+		spaProfiles = append(spaProfiles, profile)
 	}
-	return df
+	return NewKnocker(profiles, spaProfiles), nil
 }
 
-//checkExists : check if given database exists
-func checkExists(db *sql.DB, table string) {
-	rows, err := db.Query("SELECT 1 FROM " + table)
+//sqlLoadHoneypots : load unused inbound ports blacklisting every source touching them
+func sqlLoadHoneypots(st *store.Store) (Honeypots, error) {
+	honeypots, err := st.Honeypots()
 	if err != nil {
-		log.Fatalf("Unable to access table: %q! Error: %s\n", table, err.Error())
+		return nil, fmt.Errorf("Unable to collect honeypots! SQL-Error: %s", err.Error())
+	}
+	h := make(Honeypots, len(honeypots))
+	for _, p := range honeypots {
+		h[KnockPort{Port: p.Port, Protocol: p.Protocol}] = Honeypot{Expiry: time.Duration(p.Expiry) * time.Second, Unverified: p.Unverified}
 	}
-	rows.Close()
+	return h, nil
 }
 
-func init() {
-	// open database instance
-	var err error
-	db, err = sql.Open("sqlite3", "db/database.db")
+//sqlLoadWhitelist : load whitelisted ip-addresses into a cache
+func sqlLoadWhitelist(st *store.Store) (*RedBlackTree, error) {
+	entries, err := st.Entries(store.Whitelist)
 	if err != nil {
-		log.Fatalf("Unable to launch SQLITE3: %s\n", err.Error())
-	}
-	// configure database connection
-	db.SetMaxOpenConns(1)
-	db.Exec("PRAGMA journal_mode=WAL;")
-	// check if required tables exist
-	checkExists(db, "rules")
-	checkExists(db, "whitelist")
-	checkExists(db, "blacklist")
+		return nil, fmt.Errorf("Unable to collect whitelist! SQL-Error: %s", err.Error())
+	}
+	cache, kv := NewRedBlackTree(), NewRedBlackKV()
+	for _, e := range entries {
+		cache.Set(kv, e.IPAddress, "")
+	}
+	return cache, nil
+}
+
+//sqlLoadDefaults : load rule options into defaults
+func sqlLoadDefaults(st *store.Store) (*dfaults, error) {
+	opts, err := st.Options()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to collect firewall options! SQL-Error: %s", err.Error())
+	}
+	d := &dfaults{inbound: opts.Inbound, outbound: opts.Outbound, forward: opts.Forward, askVerdict: opts.AskVerdict}
+	d.blacklistAction, d.tarpitFlows = opts.BlacklistAction, opts.TarpitFlows
+	d.audit, d.auditRetention = opts.Audit, time.Duration(opts.AuditRetention)*time.Second
+	d.askTimeout = time.Duration(opts.AskTimeout) * time.Second
+	if d.askTimeout <= 0 {
+		d.askTimeout = defaultAskTimeout
+	}
+	if d.askVerdict != "allow" {
+		d.askVerdict = "deny"
+	}
+	return d, nil
+}
+
+//sqlRecordAudit : store packet that would have been dropped within the auditlog
+func sqlRecordAudit(st *store.Store, pkt *PacketData, d *Decision) error {
+	return st.RecordAudit(store.AuditEntry{
+		Protocol: pkt.Protocol,
+		FromIP:   pkt.SrcIP,
+		FromPort: pkt.SrcPort,
+		ToIP:     pkt.DstIP,
+		ToPort:   pkt.DstPort,
+		Reason:   d.Reason,
+		RuleNum:  d.RuleNum,
+		Rule:     d.Rule,
+	})
 }
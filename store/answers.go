@@ -0,0 +1,42 @@
+package store
+
+/***Methods***/
+
+//(*Store).Answers : return the outbound rules of prompts answered forever ordered by answer-number
+// (RuleNum holds the answer-number)
+func (s *Store) Answers() ([]Rule, error) {
+	rows, err := s.q.Query("SELECT AnswerNum,ToIP,ToPort,UID,Exe,Action FROM answers ORDER BY AnswerNum")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var answers []Rule
+	for rows.Next() {
+		r := Rule{Zone: "outbound", FromIP: "any", FromPort: "any"}
+		if err = rows.Scan(&r.RuleNum, &r.ToIP, &r.ToPort, &r.UID, &r.Exe, &r.Action); err != nil {
+			return nil, err
+		}
+		answers = append(answers, r)
+	}
+	return answers, rows.Err()
+}
+
+//(*Store).AddAnswer : add the outbound rule of a prompt answered forever unless an identical one
+// exists (answers are written by the daemon and not recorded within the history)
+func (s *Store) AddAnswer(r Rule) error {
+	_, err := s.q.Exec(
+		"INSERT INTO answers (ToIP,ToPort,UID,Exe,Action) SELECT ?,?,?,?,? WHERE NOT EXISTS (SELECT 1 FROM answers WHERE ToIP=? AND ToPort=? AND UID=? AND Exe=? AND Action=?);",
+		r.ToIP, r.ToPort, r.UID, r.Exe, r.Action, r.ToIP, r.ToPort, r.UID, r.Exe, r.Action,
+	)
+	return err
+}
+
+//(*Store).RemoveAnswer : forget the answer with the given answer-number (false if it does not exist)
+func (s *Store) RemoveAnswer(num int) (bool, error) {
+	res, err := s.q.Exec("DELETE FROM answers WHERE AnswerNum=?;", num)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,65 @@
+package store
+
+import "time"
+
+/***Variables***/
+
+//AuditEntry : packet that would have been dropped stored within the auditlog table
+type AuditEntry struct {
+	EntryDate string
+	Protocol  string
+	FromIP    string
+	FromPort  int64
+	ToIP      string
+	ToPort    int64
+	Reason    string
+	RuleNum   int
+	Rule      string
+}
+
+/***Methods***/
+
+//(*Store).RecordAudit : store packet that would have been dropped
+func (s *Store) RecordAudit(a AuditEntry) error {
+	_, err := s.q.Exec(
+		"INSERT INTO auditlog VALUES (datetime('now'),?,?,?,?,?,?,?,?);",
+		a.Protocol, a.FromIP, a.FromPort, a.ToIP, a.ToPort, a.Reason, a.RuleNum, a.Rule,
+	)
+	return err
+}
+
+//(*Store).AuditLog : return the most recent audit entries
+func (s *Store) AuditLog(limit int) ([]AuditEntry, error) {
+	rows, err := s.q.Query(
+		"SELECT EntryDate,Protocol,FromIP,FromPort,ToIP,ToPort,Reason,RuleNum,Rule FROM auditlog ORDER BY rowid DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []AuditEntry
+	for rows.Next() {
+		var a AuditEntry
+		if err = rows.Scan(&a.EntryDate, &a.Protocol, &a.FromIP, &a.FromPort, &a.ToIP, &a.ToPort, &a.Reason, &a.RuleNum, &a.Rule); err != nil {
+			return nil, err
+		}
+		entries = append(entries, a)
+	}
+	return entries, rows.Err()
+}
+
+//(*Store).FlushAuditLog : remove all audit entries
+func (s *Store) FlushAuditLog() error {
+	_, err := s.q.Exec("DELETE FROM auditlog;")
+	return err
+}
+
+//(*Store).PruneAuditLog : remove audit entries recorded before the given time and return their number
+func (s *Store) PruneAuditLog(before time.Time) (int64, error) {
+	res, err := s.q.Exec("DELETE FROM auditlog WHERE EntryDate<?;", before.UTC().Format(DateLayout))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
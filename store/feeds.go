@@ -0,0 +1,115 @@
+package store
+
+import "database/sql"
+
+/***Variables***/
+
+//Feed : blocklist feed stored within the feeds table
+type Feed struct {
+	Name     string
+	Source   string // file, directory or http(s) url of a local mirror
+	Interval int64  // seconds between refreshes
+	// status of the last refresh
+	Entries     int64  // addresses/networks loaded by the last successful refresh
+	LastRefresh string // time of the last successful refresh (blank if never refreshed)
+	LastError   string // error of the last refresh (blank if it succeeded)
+}
+
+/***Methods***/
+
+//(*Store).Feeds : return all feeds ordered by name
+func (s *Store) Feeds() ([]Feed, error) {
+	rows, err := s.q.Query("SELECT Name,Source,Interval,Entries,LastRefresh,LastError FROM feeds ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var feeds []Feed
+	for rows.Next() {
+		var f Feed
+		if err = rows.Scan(&f.Name, &f.Source, &f.Interval, &f.Entries, &f.LastRefresh, &f.LastError); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+//(*Store).Feed : return the feed of the given name (false if it does not exist)
+func (s *Store) Feed(name string) (Feed, bool, error) {
+	f := Feed{Name: name}
+	err := s.q.QueryRow(
+		"SELECT Source,Interval,Entries,LastRefresh,LastError FROM feeds WHERE Name=?", name,
+	).Scan(&f.Source, &f.Interval, &f.Entries, &f.LastRefresh, &f.LastError)
+	if err == sql.ErrNoRows {
+		return f, false, nil
+	}
+	return f, err == nil, err
+}
+
+//(*Store).changeFeeds : run feed mutation and record the feeds before and after it
+func (s *Store) changeFeeds(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.Feeds()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.Feeds()
+		if err != nil {
+			return err
+		}
+		return tx.record("feeds", action, old, new)
+	})
+}
+
+//(*Store).writeFeeds : replace the feeds without recording the change
+func (s *Store) writeFeeds(feeds []Feed) error {
+	if _, err := s.q.Exec("DELETE FROM feeds;"); err != nil {
+		return err
+	}
+	for _, f := range feeds {
+		if err := s.writeFeed(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).writeFeed : insert or replace feed without recording the change
+func (s *Store) writeFeed(f Feed) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO feeds (Name,Source,Interval,Entries,LastRefresh,LastError) VALUES (?,?,?,?,?,?);",
+		f.Name, f.Source, f.Interval, f.Entries, f.LastRefresh, f.LastError,
+	)
+	return err
+}
+
+//(*Store).AddFeed : register feed (replacing an existing feed of the same name)
+func (s *Store) AddFeed(f Feed) error {
+	return s.changeFeeds("add", func(tx *Store) error {
+		return tx.writeFeed(f)
+	})
+}
+
+//(*Store).RemoveFeed : remove the feed of the given name
+func (s *Store) RemoveFeed(name string) error {
+	return s.changeFeeds("remove", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM feeds WHERE Name=?;", name)
+		return err
+	})
+}
+
+//(*Store).SetFeedStatus : record the result of a feed refresh (not recorded within the history)
+func (s *Store) SetFeedStatus(name string, entries int64, refreshErr error) error {
+	if refreshErr != nil {
+		_, err := s.q.Exec("UPDATE feeds SET LastError=? WHERE Name=?;", refreshErr.Error(), name)
+		return err
+	}
+	_, err := s.q.Exec(
+		"UPDATE feeds SET Entries=?, LastRefresh=datetime('now'), LastError='' WHERE Name=?;", entries, name,
+	)
+	return err
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/***Variables***/
+
+//Change : single change to the policy stored within the history table
+type Change struct {
+	Batch     int64
+	EntryDate string
+	User      string
+	Table     string
+	Action    string
+	OldValue  string // json encoded state before the change
+	NewValue  string // json encoded state after the change
+	Undone    bool
+}
+
+/***Methods***/
+
+//(*Store).record : store change made within the current transaction batch
+func (s *Store) record(table, action string, old, new interface{}) error {
+	oldValue, err := json.Marshal(old)
+	if err != nil {
+		return err
+	}
+	newValue, err := json.Marshal(new)
+	if err != nil {
+		return err
+	}
+	_, err = s.q.Exec(
+		"INSERT INTO history (Batch,EntryDate,User,TableName,Action,OldValue,NewValue) VALUES (?,datetime('now'),?,?,?,?,?);",
+		s.batch, s.user, table, action, string(oldValue), string(newValue),
+	)
+	return err
+}
+
+//(*Store).History : return changes of the most recent batches (newest first)
+func (s *Store) History(limit int) ([]Change, error) {
+	rows, err := s.q.Query(
+		"SELECT Batch,EntryDate,User,TableName,Action,OldValue,NewValue,Undone FROM history "+
+			"WHERE Batch IN (SELECT DISTINCT Batch FROM history ORDER BY Batch DESC LIMIT ?) ORDER BY rowid DESC",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err = rows.Scan(&c.Batch, &c.EntryDate, &c.User, &c.Table, &c.Action, &c.OldValue, &c.NewValue, &c.Undone); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+//(*Store).Undo : revert the last n batches that were not undone yet and return their changes
+func (s *Store) Undo(n int) ([]Change, error) {
+	var undone []Change
+	err := s.Transaction(func(tx *Store) error {
+		var batch int64
+		err := tx.q.QueryRow(
+			"SELECT IFNULL(min(Batch), 0) FROM (SELECT DISTINCT Batch FROM history WHERE Undone=0 ORDER BY Batch DESC LIMIT ?)",
+			n,
+		).Scan(&batch)
+		if err != nil || batch == 0 {
+			return err
+		}
+		undone, err = tx.undoFrom(batch)
+		return err
+	})
+	return undone, err
+}
+
+//(*Store).undoFrom : revert all batches starting at the given batch that were not undone yet
+func (s *Store) undoFrom(batch int64) ([]Change, error) {
+	changes, err := s.changesFrom(batch)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if err = s.revert(c); err != nil {
+			return nil, fmt.Errorf("unable to undo %s of %s (batch %d): %s", c.Action, c.Table, c.Batch, err.Error())
+		}
+	}
+	if _, err = s.q.Exec("UPDATE history SET Undone=1 WHERE Batch>=? AND Undone=0;", batch); err != nil {
+		return nil, err
+	}
+	// reverted changes no longer need to be confirmed
+	_, err = s.q.Exec("DELETE FROM pending WHERE Batch>=?;", batch)
+	return changes, err
+}
+
+//(*Store).changesFrom : return changes starting at the given batch that were not undone yet (newest first)
+func (s *Store) changesFrom(batch int64) ([]Change, error) {
+	rows, err := s.q.Query(
+		"SELECT Batch,EntryDate,User,TableName,Action,OldValue,NewValue FROM history WHERE Undone=0 AND Batch>=? ORDER BY rowid DESC",
+		batch,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err = rows.Scan(&c.Batch, &c.EntryDate, &c.User, &c.Table, &c.Action, &c.OldValue, &c.NewValue); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+//(*Store).revert : restore the state recorded before the change
+func (s *Store) revert(c Change) error {
+	switch c.Table {
+	case "rules":
+		var old, new, current []Rule
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		current, err := s.Rules()
+		if err != nil {
+			return err
+		}
+		if len(current) != len(new) || (len(new) > 0 && !reflect.DeepEqual(current, new)) {
+			return fmt.Errorf("rules were modified since the change")
+		}
+		return s.writeRules(old)
+	case "ruleopts":
+		var old, new Options
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeOptions(old)
+	case "zones":
+		var old, new []NetZone
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeNetZones(old)
+	case "nat":
+		var old, new NAT
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeNAT(old)
+	case "feeds":
+		var old, new []Feed
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeFeeds(old)
+	case "knocks":
+		var old, new []Knock
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeKnocks(old)
+	case "spa":
+		var old, new []SPA
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeSPAs(old)
+	case "honeypots":
+		var old, new []Honeypot
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		return s.writeHoneypots(old)
+	case Whitelist, Blacklist:
+		var old, new []Entry
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err
+		}
+		for _, e := range new {
+			if _, err := s.q.Exec("DELETE FROM "+c.Table+" WHERE IPAddress=?;", e.IPAddress); err != nil {
+				return err
+			}
+		}
+		for _, e := range old {
+			if err := s.writeEntry(c.Table, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown table: %q", c.Table)
+	}
+}
+
+//decodeChange : decode the old and new state recorded with the change
+func decodeChange(c Change, old, new interface{}) error {
+	if err := json.Unmarshal([]byte(c.OldValue), old); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(c.NewValue), new)
+}
+
+//(Change).Summary : describe the change in a single line
+func (c Change) Summary() string {
+	switch c.Table {
+	case "rules":
+		var old, new []Rule
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d rules -> %d rules", len(old), len(new))
+	case "ruleopts":
+		var old, new Options
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf(
+			"inbound=%s outbound=%s forward=%s -> inbound=%s outbound=%s forward=%s",
+			old.Inbound, old.Outbound, old.Forward, new.Inbound, new.Outbound, new.Forward,
+		)
+	case "zones":
+		var old, new []NetZone
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d zones -> %d zones", len(old), len(new))
+	case "nat":
+		var old, new NAT
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf(
+			"%d forwards %d masquerades -> %d forwards %d masquerades",
+			len(old.Forwards), len(old.Masquerades), len(new.Forwards), len(new.Masquerades),
+		)
+	case "feeds":
+		var old, new []Feed
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d feeds -> %d feeds", len(old), len(new))
+	case "knocks":
+		var old, new []Knock
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d knocks -> %d knocks", len(old), len(new))
+	case "spa":
+		var old, new []SPA
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d spa profiles -> %d spa profiles", len(old), len(new))
+	case "honeypots":
+		var old, new []Honeypot
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%d honeypots -> %d honeypots", len(old), len(new))
+	case Whitelist, Blacklist:
+		var old, new []Entry
+		if err := decodeChange(c, &old, &new); err != nil {
+			return err.Error()
+		}
+		var ips []string
+		for _, e := range new {
+			ips = append(ips, "+"+e.IPAddress)
+		}
+		for _, e := range old {
+			ips = append(ips, "-"+e.IPAddress)
+		}
+		return strings.Join(ips, " ")
+	default:
+		return ""
+	}
+}
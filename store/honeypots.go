@@ -0,0 +1,102 @@
+package store
+
+import "database/sql"
+
+/***Variables***/
+
+//Honeypot : unused port blacklisting every source touching it stored within the honeypots table
+type Honeypot struct {
+	Port     int64
+	Protocol string // tcp/udp
+	Expiry   int64  // seconds the blacklist entry of a source lasts (0 never expires)
+	// trip on the first packet instead of a completed tcp handshake (required by udp honeypots,
+	// the source address of the packet may be spoofed to blacklist any address)
+	Unverified bool
+}
+
+/***Methods***/
+
+//(*Store).Honeypots : return all honeypot ports ordered by port and protocol
+func (s *Store) Honeypots() ([]Honeypot, error) {
+	rows, err := s.q.Query("SELECT Port,Protocol,Expiry,Unverified FROM honeypots ORDER BY Port,Protocol")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var honeypots []Honeypot
+	for rows.Next() {
+		var h Honeypot
+		if err = rows.Scan(&h.Port, &h.Protocol, &h.Expiry, &h.Unverified); err != nil {
+			return nil, err
+		}
+		honeypots = append(honeypots, h)
+	}
+	return honeypots, rows.Err()
+}
+
+//(*Store).Honeypot : return the honeypot of the given port and protocol (false if it does not exist)
+func (s *Store) Honeypot(port int64, protocol string) (Honeypot, bool, error) {
+	h := Honeypot{Port: port, Protocol: protocol}
+	err := s.q.QueryRow(
+		"SELECT Expiry,Unverified FROM honeypots WHERE Port=? AND Protocol=?", port, protocol,
+	).Scan(&h.Expiry, &h.Unverified)
+	if err == sql.ErrNoRows {
+		return h, false, nil
+	}
+	return h, err == nil, err
+}
+
+//(*Store).changeHoneypots : run honeypot mutation and record the honeypots before and after it
+func (s *Store) changeHoneypots(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.Honeypots()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.Honeypots()
+		if err != nil {
+			return err
+		}
+		return tx.record("honeypots", action, old, new)
+	})
+}
+
+//(*Store).writeHoneypots : replace the honeypots without recording the change
+func (s *Store) writeHoneypots(honeypots []Honeypot) error {
+	if _, err := s.q.Exec("DELETE FROM honeypots;"); err != nil {
+		return err
+	}
+	for _, h := range honeypots {
+		if err := s.writeHoneypot(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).writeHoneypot : insert or replace honeypot without recording the change
+func (s *Store) writeHoneypot(h Honeypot) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO honeypots (Port,Protocol,Expiry,Unverified) VALUES (?,?,?,?);",
+		h.Port, h.Protocol, h.Expiry, h.Unverified,
+	)
+	return err
+}
+
+//(*Store).AddHoneypot : create honeypot (replacing an existing honeypot of the same port and protocol)
+func (s *Store) AddHoneypot(h Honeypot) error {
+	return s.changeHoneypots("add", func(tx *Store) error {
+		return tx.writeHoneypot(h)
+	})
+}
+
+//(*Store).RemoveHoneypot : remove the honeypot of the given port and protocol
+func (s *Store) RemoveHoneypot(port int64, protocol string) error {
+	return s.changeHoneypots("remove", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM honeypots WHERE Port=? AND Protocol=?;", port, protocol)
+		return err
+	})
+}
@@ -0,0 +1,101 @@
+package store
+
+import "database/sql"
+
+/***Variables***/
+
+//Knock : port knocking profile stored within the knocks table
+type Knock struct {
+	Name     string
+	Sequence string // ports knocked in order (7000/tcp,8000/udp,9000/tcp)
+	Window   int64  // seconds the whole sequence must be knocked within
+	Target   string // port opened for the knocking source (22/tcp)
+	Duration int64  // seconds the target port stays open
+}
+
+/***Methods***/
+
+//(*Store).Knocks : return all knock profiles ordered by name
+func (s *Store) Knocks() ([]Knock, error) {
+	rows, err := s.q.Query("SELECT Name,Sequence,Window,Target,Duration FROM knocks ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var knocks []Knock
+	for rows.Next() {
+		var k Knock
+		if err = rows.Scan(&k.Name, &k.Sequence, &k.Window, &k.Target, &k.Duration); err != nil {
+			return nil, err
+		}
+		knocks = append(knocks, k)
+	}
+	return knocks, rows.Err()
+}
+
+//(*Store).Knock : return the knock profile of the given name (false if it does not exist)
+func (s *Store) Knock(name string) (Knock, bool, error) {
+	k := Knock{Name: name}
+	err := s.q.QueryRow(
+		"SELECT Sequence,Window,Target,Duration FROM knocks WHERE Name=?", name,
+	).Scan(&k.Sequence, &k.Window, &k.Target, &k.Duration)
+	if err == sql.ErrNoRows {
+		return k, false, nil
+	}
+	return k, err == nil, err
+}
+
+//(*Store).changeKnocks : run knock mutation and record the knock profiles before and after it
+func (s *Store) changeKnocks(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.Knocks()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.Knocks()
+		if err != nil {
+			return err
+		}
+		return tx.record("knocks", action, old, new)
+	})
+}
+
+//(*Store).writeKnocks : replace the knock profiles without recording the change
+func (s *Store) writeKnocks(knocks []Knock) error {
+	if _, err := s.q.Exec("DELETE FROM knocks;"); err != nil {
+		return err
+	}
+	for _, k := range knocks {
+		if err := s.writeKnock(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).writeKnock : insert or replace knock profile without recording the change
+func (s *Store) writeKnock(k Knock) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO knocks (Name,Sequence,Window,Target,Duration) VALUES (?,?,?,?,?);",
+		k.Name, k.Sequence, k.Window, k.Target, k.Duration,
+	)
+	return err
+}
+
+//(*Store).AddKnock : create knock profile (replacing an existing profile of the same name)
+func (s *Store) AddKnock(k Knock) error {
+	return s.changeKnocks("add", func(tx *Store) error {
+		return tx.writeKnock(k)
+	})
+}
+
+//(*Store).RemoveKnock : remove the knock profile of the given name
+func (s *Store) RemoveKnock(name string) error {
+	return s.changeKnocks("remove", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM knocks WHERE Name=?;", name)
+		return err
+	})
+}
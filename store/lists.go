@@ -0,0 +1,209 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/***Variables***/
+
+const (
+	Whitelist = "whitelist"
+	Blacklist = "blacklist"
+)
+
+//DateLayout : layout of the dates sqlite stores with datetime (utc)
+const DateLayout = "2006-01-02 15:04:05"
+
+//activeEntry : condition excluding expired blacklist entries
+const activeEntry = "LogicalDelete=0 AND (Expires='' OR Expires>datetime('now'))"
+
+//Entry : ip-address entry stored within the whitelist/blacklist tables
+type Entry struct {
+	IPAddress string
+	Reason    string
+	EntryDate string
+	LastSeen  string // only used by the blacklist
+	Expires   string // blank never expires (only used by the blacklist)
+}
+
+/***Functions***/
+
+//checkList : ensure list is a known ip-address list
+func checkList(list string) error {
+	if list != Whitelist && list != Blacklist {
+		return fmt.Errorf("unknown list: %q", list)
+	}
+	return nil
+}
+
+/***Methods***/
+
+//(*Store).Entries : return all active entries of the given list
+func (s *Store) Entries(list string) ([]Entry, error) {
+	if err := checkList(list); err != nil {
+		return nil, err
+	}
+	query := "SELECT IPAddress,Reason,EntryDate,EntryDate,'' FROM whitelist WHERE LogicalDelete=0"
+	if list == Blacklist {
+		query = "SELECT IPAddress,Reason,EntryDate,LastSeen,Expires FROM blacklist WHERE " + activeEntry
+	}
+	rows, err := s.q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err = rows.Scan(&e.IPAddress, &e.Reason, &e.EntryDate, &e.LastSeen, &e.Expires); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+//(*Store).HasEntry : check if ip-address is already contained within the list (expired and deleted entries are not)
+func (s *Store) HasEntry(list, ip string) (bool, error) {
+	if err := checkList(list); err != nil {
+		return false, err
+	}
+	// logically deleted entries do not count as contained
+	query := "SELECT IFNULL((SELECT 1 FROM whitelist WHERE IPAddress=? AND LogicalDelete=0), 0)"
+	if list == Blacklist {
+		query = "SELECT IFNULL((SELECT 1 FROM blacklist WHERE IPAddress=? AND " + activeEntry + "), 0)"
+	}
+	var exists int
+	err := s.q.QueryRow(query, ip).Scan(&exists)
+	return exists == 1, err
+}
+
+//(*Store).AddEntry : add ip-address to the list (blank dates default to now)
+func (s *Store) AddEntry(list string, e Entry) error {
+	if err := checkList(list); err != nil {
+		return err
+	}
+	return s.Transaction(func(tx *Store) error {
+		if err := tx.writeEntry(list, e); err != nil {
+			return err
+		}
+		return tx.record(list, "add", []Entry{}, []Entry{e})
+	})
+}
+
+//(*Store).AddHoneypotEntry : blacklist the source of a honeypot unless it is blacklisted already (true if
+// the entry was added), packet handlers tripping at once add a single entry and the entries written by the
+// daemon are not recorded within the history (undo never reverts them)
+func (s *Store) AddHoneypotEntry(e Entry) (bool, error) {
+	res, err := s.q.Exec(
+		"INSERT INTO blacklist (IPAddress,EntryDate,LastSeen,Reason,LogicalDelete,Expires) "+
+			"SELECT ?,datetime('now'),datetime('now'),?,0,? WHERE NOT EXISTS (SELECT 1 FROM blacklist WHERE IPAddress=? AND "+activeEntry+");",
+		e.IPAddress, e.Reason, e.Expires, e.IPAddress,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+//(*Store).RemoveEntry : remove ip-address from the list
+func (s *Store) RemoveEntry(list, ip string) error {
+	return s.changeEntries(list, "remove", "DELETE FROM "+list+" WHERE IPAddress=?;", ip)
+}
+
+//(*Store).FlushEntries : remove all ip-addresses from the list
+func (s *Store) FlushEntries(list string) error {
+	return s.changeEntries(list, "flush", "DELETE FROM "+list+";")
+}
+
+//(*Store).changeEntries : run deletion against the list and record the removed entries
+func (s *Store) changeEntries(list, action, query string, args ...interface{}) error {
+	if err := checkList(list); err != nil {
+		return err
+	}
+	return s.Transaction(func(tx *Store) error {
+		entries, err := tx.Entries(list)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.q.Exec(query, args...); err != nil {
+			return err
+		}
+		// collect entries that no longer exist
+		var removed []Entry
+		for _, e := range entries {
+			exists, err := tx.HasEntry(list, e.IPAddress)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				removed = append(removed, e)
+			}
+		}
+		return tx.record(list, action, removed, []Entry{})
+	})
+}
+
+//(*Store).writeEntry : add ip-address to the list without recording the change
+func (s *Store) writeEntry(list string, e Entry) error {
+	var err error
+	switch list {
+	case Whitelist:
+		_, err = s.q.Exec(
+			"INSERT INTO whitelist VALUES (?,IFNULL(NULLIF(?,''),datetime('now')),?,0);",
+			e.IPAddress, e.EntryDate, e.Reason,
+		)
+	case Blacklist:
+		// an expired entry of the ip-address is replaced
+		if _, err = s.q.Exec("DELETE FROM blacklist WHERE IPAddress=? AND Expires<>'' AND Expires<=datetime('now');", e.IPAddress); err != nil {
+			return err
+		}
+		_, err = s.q.Exec(
+			"INSERT INTO blacklist (IPAddress,EntryDate,LastSeen,Reason,LogicalDelete,Expires) "+
+				"VALUES (?,IFNULL(NULLIF(?,''),datetime('now')),IFNULL(NULLIF(?,''),datetime('now')),?,0,?);",
+			e.IPAddress, e.EntryDate, e.LastSeen, e.Reason, e.Expires,
+		)
+	default:
+		err = checkList(list)
+	}
+	return err
+}
+
+//(*Store).Blacklisted : return whichever of the given entries (ip-addresses/countries) is blacklisted ("" if none)
+func (s *Store) Blacklisted(entries ...string) (string, error) {
+	blocked, _, err := s.BlacklistedUntil(entries...)
+	return blocked, err
+}
+
+//(*Store).BlacklistedUntil : return whichever of the given entries is blacklisted and when its entry expires
+// (zero if it never does)
+func (s *Store) BlacklistedUntil(entries ...string) (string, time.Time, error) {
+	var (
+		marks []string
+		args  []interface{}
+	)
+	for _, e := range entries {
+		if e != "" {
+			marks, args = append(marks, "?"), append(args, e)
+		}
+	}
+	if len(args) == 0 {
+		return "", time.Time{}, nil
+	}
+	var blocked, expires string
+	err := s.q.QueryRow(
+		"SELECT IPAddress,Expires FROM blacklist WHERE "+activeEntry+" AND IPAddress IN ("+strings.Join(marks, ",")+")", args...,
+	).Scan(&blocked, &expires)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil || expires == "" {
+		return blocked, time.Time{}, err
+	}
+	until, err := time.Parse(DateLayout, expires)
+	return blocked, until, err
+}
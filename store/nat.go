@@ -0,0 +1,151 @@
+package store
+
+/***Variables***/
+
+//PortForward : external port forwarded to an internal host stored within the portforwards table
+type PortForward struct {
+	Interface string // external interface the port is forwarded on
+	Protocol  string // tcp/udp
+	Port      string // external port/port-range
+	ToIP      string // internal host
+	ToPort    string // internal port/port-range (blank for the external port)
+}
+
+//Masquerade : source nat of packets leaving an interface stored within the masquerades table
+type Masquerade struct {
+	Interface string // outbound interface
+	Source    string // source ip/ip-range that is masqueraded (any for all)
+}
+
+//NAT : complete nat configuration
+type NAT struct {
+	Forwards    []PortForward
+	Masquerades []Masquerade
+}
+
+/***Methods***/
+
+//(*Store).NAT : return all port forwards and masquerades
+func (s *Store) NAT() (NAT, error) {
+	var n NAT
+	rows, err := s.q.Query("SELECT Interface,Protocol,Port,ToIP,ToPort FROM portforwards ORDER BY Interface,Protocol,Port")
+	if err != nil {
+		return n, err
+	}
+	for rows.Next() {
+		var f PortForward
+		if err = rows.Scan(&f.Interface, &f.Protocol, &f.Port, &f.ToIP, &f.ToPort); err != nil {
+			rows.Close()
+			return n, err
+		}
+		n.Forwards = append(n.Forwards, f)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return n, err
+	}
+	rows, err = s.q.Query("SELECT Interface,Source FROM masquerades ORDER BY Interface")
+	if err != nil {
+		return n, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m Masquerade
+		if err = rows.Scan(&m.Interface, &m.Source); err != nil {
+			return n, err
+		}
+		n.Masquerades = append(n.Masquerades, m)
+	}
+	return n, rows.Err()
+}
+
+//(*Store).changeNAT : run nat mutation and record the nat configuration before and after it
+func (s *Store) changeNAT(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.NAT()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.NAT()
+		if err != nil {
+			return err
+		}
+		return tx.record("nat", action, old, new)
+	})
+}
+
+//(*Store).writeNAT : replace the nat configuration without recording the change
+func (s *Store) writeNAT(n NAT) error {
+	if _, err := s.q.Exec("DELETE FROM portforwards;"); err != nil {
+		return err
+	}
+	if _, err := s.q.Exec("DELETE FROM masquerades;"); err != nil {
+		return err
+	}
+	for _, f := range n.Forwards {
+		if err := s.writePortForward(f); err != nil {
+			return err
+		}
+	}
+	for _, m := range n.Masquerades {
+		if err := s.writeMasquerade(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).writePortForward : insert or replace port forward without recording the change
+func (s *Store) writePortForward(f PortForward) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO portforwards (Interface,Protocol,Port,ToIP,ToPort) VALUES (?,?,?,?,?);",
+		f.Interface, f.Protocol, f.Port, f.ToIP, f.ToPort,
+	)
+	return err
+}
+
+//(*Store).writeMasquerade : insert or replace masquerade without recording the change
+func (s *Store) writeMasquerade(m Masquerade) error {
+	_, err := s.q.Exec("INSERT OR REPLACE INTO masquerades (Interface,Source) VALUES (?,?);", m.Interface, m.Source)
+	return err
+}
+
+//(*Store).AddPortForward : forward external port to internal host (replacing an existing forward of the port)
+func (s *Store) AddPortForward(f PortForward) error {
+	return s.changeNAT("forward", func(tx *Store) error {
+		return tx.writePortForward(f)
+	})
+}
+
+//(*Store).RemovePortForward : remove forward of the given external port
+func (s *Store) RemovePortForward(iface, protocol, port string) error {
+	return s.changeNAT("unforward", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM portforwards WHERE Interface=? AND Protocol=? AND Port=?;", iface, protocol, port)
+		return err
+	})
+}
+
+//(*Store).AddMasquerade : masquerade packets leaving the given interface (replacing an existing masquerade)
+func (s *Store) AddMasquerade(m Masquerade) error {
+	return s.changeNAT("masquerade", func(tx *Store) error {
+		return tx.writeMasquerade(m)
+	})
+}
+
+//(*Store).RemoveMasquerade : stop masquerading packets leaving the given interface
+func (s *Store) RemoveMasquerade(iface string) error {
+	return s.changeNAT("unmasquerade", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM masquerades WHERE Interface=?;", iface)
+		return err
+	})
+}
+
+//(*Store).SetNAT : replace the nat configuration
+func (s *Store) SetNAT(n NAT) error {
+	return s.changeNAT("set", func(tx *Store) error {
+		return tx.writeNAT(n)
+	})
+}
@@ -0,0 +1,80 @@
+package store
+
+import "fmt"
+
+/***Variables***/
+
+//Options : firewall rule defaults stored within the ruleopts table
+type Options struct {
+	Inbound  string
+	Outbound string
+	Forward  string
+	// outbound packets no rule decided wait for an answer when the outbound default is ask,
+	// unanswered prompts time out after AskTimeout seconds to AskVerdict (allow/deny)
+	AskTimeout int
+	AskVerdict string
+	// blacklisted sources are dropped or tarpitted (drop/tarpit), at most TarpitFlows
+	// connections are tarpitted at once
+	BlacklistAction string
+	TarpitFlows     int
+	// audit mode records would-be drops instead of dropping packets, audit entries older
+	// than AuditRetention seconds are pruned (0 keeps them forever)
+	Audit          bool
+	AuditRetention int
+}
+
+/***Methods***/
+
+//(*Store).Options : return the firewall rule defaults
+func (s *Store) Options() (Options, error) {
+	var o Options
+	err := s.q.QueryRow(
+		"SELECT Inbound, Outbound, Forward, AskTimeout, AskVerdict, BlacklistAction, TarpitFlows, Audit, AuditRetention FROM ruleopts LIMIT 1",
+	).Scan(&o.Inbound, &o.Outbound, &o.Forward, &o.AskTimeout, &o.AskVerdict, &o.BlacklistAction, &o.TarpitFlows, &o.Audit, &o.AuditRetention)
+	return o, err
+}
+
+//(*Store).SetOption : set a single rule default (Inbound/Outbound/Forward) to allow/deny (or ask)
+func (s *Store) SetOption(field, value string) error {
+	if field != "Inbound" && field != "Outbound" && field != "Forward" {
+		return fmt.Errorf("unknown rule option: %q", field)
+	}
+	return s.Transaction(func(tx *Store) error {
+		o, err := tx.Options()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case "Inbound":
+			o.Inbound = value
+		case "Outbound":
+			o.Outbound = value
+		default:
+			o.Forward = value
+		}
+		return tx.SetOptions(o)
+	})
+}
+
+//(*Store).SetOptions : set all rule defaults
+func (s *Store) SetOptions(o Options) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.Options()
+		if err != nil {
+			return err
+		}
+		if err = tx.writeOptions(o); err != nil {
+			return err
+		}
+		return tx.record("ruleopts", "set", old, o)
+	})
+}
+
+//(*Store).writeOptions : set all rule defaults without recording the change
+func (s *Store) writeOptions(o Options) error {
+	_, err := s.q.Exec(
+		"UPDATE ruleopts SET Inbound=?, Outbound=?, Forward=?, AskTimeout=?, AskVerdict=?, BlacklistAction=?, TarpitFlows=?, Audit=?, AuditRetention=?;",
+		o.Inbound, o.Outbound, o.Forward, o.AskTimeout, o.AskVerdict, o.BlacklistAction, o.TarpitFlows, o.Audit, o.AuditRetention,
+	)
+	return err
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+/***Variables***/
+
+//Pending : batch of provisional changes that is rolled back unless confirmed before the deadline
+type Pending struct {
+	Batch    int64
+	Deadline time.Time
+}
+
+/***Methods***/
+
+//(*Store).Provisional : mark the changes of the current transaction as provisional until the deadline
+func (s *Store) Provisional(deadline time.Time) (Pending, error) {
+	if !s.tx {
+		return Pending{}, fmt.Errorf("provisional changes require a transaction")
+	}
+	_, err := s.q.Exec("INSERT OR REPLACE INTO pending VALUES (?,?);", s.batch, deadline.Unix())
+	return Pending{Batch: s.batch, Deadline: deadline}, err
+}
+
+//(*Store).Pending : return all provisional batches waiting for confirmation
+func (s *Store) Pending() ([]Pending, error) {
+	rows, err := s.q.Query("SELECT Batch,Deadline FROM pending ORDER BY Batch")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pending []Pending
+	for rows.Next() {
+		var (
+			p        Pending
+			deadline int64
+		)
+		if err = rows.Scan(&p.Batch, &deadline); err != nil {
+			return nil, err
+		}
+		p.Deadline = time.Unix(deadline, 0)
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+//(*Store).Confirm : keep all provisional changes that have not expired and return how many were confirmed
+func (s *Store) Confirm(now time.Time) (int64, error) {
+	var confirmed int64
+	err := s.Transaction(func(tx *Store) error {
+		if _, err := tx.Expire(now); err != nil {
+			return err
+		}
+		res, err := tx.q.Exec("DELETE FROM pending;")
+		if err != nil {
+			return err
+		}
+		confirmed, err = res.RowsAffected()
+		return err
+	})
+	return confirmed, err
+}
+
+//(*Store).Expire : roll back the oldest expired provisional batch along with every change made after it
+func (s *Store) Expire(now time.Time) ([]Change, error) {
+	var undone []Change
+	err := s.Transaction(func(tx *Store) error {
+		var batch int64
+		err := tx.q.QueryRow("SELECT IFNULL(min(Batch), 0) FROM pending WHERE Deadline<=?", now.Unix()).Scan(&batch)
+		if err != nil || batch == 0 {
+			return err
+		}
+		undone, err = tx.undoFrom(batch)
+		return err
+	})
+	return undone, err
+}
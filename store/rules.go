@@ -0,0 +1,150 @@
+package store
+
+/***Variables***/
+
+//Rule : firewall rule stored within the rules table
+type Rule struct {
+	RuleNum  int
+	Zone     string
+	FromIP   string
+	FromPort string
+	ToIP     string
+	ToPort   string
+	Audit    bool
+	Profile  string // service/application profile providing the destination ports
+	NetZone  string // interface zone the rule belongs to (blank for the global rule chain)
+	InIface  string // interface the packet must be received on (blank for any)
+	OutIface string // interface the packet must be sent out of (blank for any)
+	SNI      string // tls server name (wildcard) the flow must request (blank for any)
+	Host     string // http host (wildcard) the flow must request (blank for any)
+	UID      string // numeric user id owning the local socket (blank for any)
+	GID      string // numeric group id of the process owning the local socket (blank for any)
+	Exe      string // executable path of the process owning the local socket (blank for any)
+	Action   string // allow/deny matching packets regardless of the default (blank to follow the default)
+	// iso country codes the source/destination address must be located in (blank for any)
+	SrcCountry string
+	DstCountry string
+}
+
+/***Methods***/
+
+//(*Store).Rules : return all rules ordered by rule-number
+func (s *Store) Rules() ([]Rule, error) {
+	rows, err := s.q.Query("SELECT RuleNum,Zone,FromIP,FromPort,ToIP,ToPort,Audit,Profile,NetZone,InIface,OutIface,SNI,Host,UID,GID,Exe,Action,SrcCountry,DstCountry FROM rules ORDER BY RuleNum")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		if err = rows.Scan(&r.RuleNum, &r.Zone, &r.FromIP, &r.FromPort, &r.ToIP, &r.ToPort, &r.Audit, &r.Profile, &r.NetZone, &r.InIface, &r.OutIface, &r.SNI, &r.Host, &r.UID, &r.GID, &r.Exe, &r.Action, &r.SrcCountry, &r.DstCountry); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+//(*Store).NextRuleNum : return the rule-number following the last rule
+func (s *Store) NextRuleNum() (int, error) {
+	var next int
+	err := s.q.QueryRow("SELECT IFNULL(max(RuleNum)+1, 0) FROM rules").Scan(&next)
+	return next, err
+}
+
+//(*Store).HasRule : check if a rule with identical values already exists
+func (s *Store) HasRule(r Rule) (bool, error) {
+	var exists int
+	err := s.q.QueryRow(
+		"SELECT IFNULL((SELECT 1 FROM rules WHERE Zone=? AND FromIP=? AND FromPort=? AND ToIP=? AND ToPort=? AND Profile=? AND NetZone=? AND InIface=? AND OutIface=? AND SNI=? AND Host=? AND UID=? AND GID=? AND Exe=? AND Action=? AND SrcCountry=? AND DstCountry=?), 0)",
+		r.Zone, r.FromIP, r.FromPort, r.ToIP, r.ToPort, r.Profile, r.NetZone, r.InIface, r.OutIface, r.SNI, r.Host, r.UID, r.GID, r.Exe, r.Action, r.SrcCountry, r.DstCountry,
+	).Scan(&exists)
+	return exists == 1, err
+}
+
+//(*Store).changeRules : run rule mutation and record the rule chain before and after it
+func (s *Store) changeRules(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.Rules()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.Rules()
+		if err != nil {
+			return err
+		}
+		return tx.record("rules", action, old, new)
+	})
+}
+
+//(*Store).writeRules : replace the rule chain without recording the change
+func (s *Store) writeRules(rules []Rule) error {
+	if _, err := s.q.Exec("DELETE FROM rules;"); err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if _, err := s.q.Exec(
+			"INSERT INTO rules (RuleNum,Zone,FromIP,FromPort,ToIP,ToPort,Audit,Profile,NetZone,InIface,OutIface,SNI,Host,UID,GID,Exe,Action,SrcCountry,DstCountry) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);",
+			r.RuleNum, r.Zone, r.FromIP, r.FromPort, r.ToIP, r.ToPort, r.Audit, r.Profile, r.NetZone, r.InIface, r.OutIface, r.SNI, r.Host, r.UID, r.GID, r.Exe, r.Action, r.SrcCountry, r.DstCountry,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).AppendRule : append rule to the end of the rule chain
+func (s *Store) AppendRule(r Rule) error {
+	return s.changeRules("append", func(tx *Store) error {
+		_, err := tx.q.Exec(
+			"INSERT INTO rules (RuleNum,Zone,FromIP,FromPort,ToIP,ToPort,Audit,Profile,NetZone,InIface,OutIface,SNI,Host,UID,GID,Exe,Action,SrcCountry,DstCountry) VALUES ((SELECT IFNULL(max(RuleNum)+1,0) FROM rules),?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);",
+			r.Zone, r.FromIP, r.FromPort, r.ToIP, r.ToPort, r.Audit, r.Profile, r.NetZone, r.InIface, r.OutIface, r.SNI, r.Host, r.UID, r.GID, r.Exe, r.Action, r.SrcCountry, r.DstCountry,
+		)
+		return err
+	})
+}
+
+//(*Store).InsertRule : insert rule at the given index and shift following rules up
+func (s *Store) InsertRule(index int, r Rule) error {
+	return s.changeRules("insert", func(tx *Store) error {
+		if _, err := tx.q.Exec("UPDATE rules SET RuleNum=RuleNum+1 WHERE RuleNum >= ?;", index); err != nil {
+			return err
+		}
+		_, err := tx.q.Exec(
+			"INSERT INTO rules (RuleNum,Zone,FromIP,FromPort,ToIP,ToPort,Audit,Profile,NetZone,InIface,OutIface,SNI,Host,UID,GID,Exe,Action,SrcCountry,DstCountry) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);",
+			index, r.Zone, r.FromIP, r.FromPort, r.ToIP, r.ToPort, r.Audit, r.Profile, r.NetZone, r.InIface, r.OutIface, r.SNI, r.Host, r.UID, r.GID, r.Exe, r.Action, r.SrcCountry, r.DstCountry,
+		)
+		return err
+	})
+}
+
+//(*Store).DeleteRule : delete rule at the given index and shift following rules down
+func (s *Store) DeleteRule(index int) error {
+	return s.changeRules("delete", func(tx *Store) error {
+		if _, err := tx.q.Exec("DELETE FROM rules WHERE RuleNum=?;", index); err != nil {
+			return err
+		}
+		_, err := tx.q.Exec("UPDATE rules SET RuleNum=RuleNum-1 WHERE RuleNum > ?;", index)
+		return err
+	})
+}
+
+//(*Store).SetRuleAudit : place rule at the given index within or out of audit mode
+func (s *Store) SetRuleAudit(index int, audit bool) error {
+	return s.changeRules("audit", func(tx *Store) error {
+		_, err := tx.q.Exec("UPDATE rules SET Audit=? WHERE RuleNum=?;", audit, index)
+		return err
+	})
+}
+
+//(*Store).FlushRules : remove all rules
+func (s *Store) FlushRules() error {
+	return s.changeRules("flush", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM rules;")
+		return err
+	})
+}
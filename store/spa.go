@@ -0,0 +1,102 @@
+package store
+
+import "database/sql"
+
+/***Variables***/
+
+//SPA : single packet authorization profile stored within the spa table
+type SPA struct {
+	Name     string
+	Port     int64  // udp port authorization packets are sent to
+	Key      string // base64 encoded hmac key shared with the clients
+	Access   string // ports clients may request (22/tcp,443/tcp)
+	Duration int64  // seconds a requested port stays open
+	MaxAge   int64  // seconds the timestamp of a packet may differ from the local time
+}
+
+/***Methods***/
+
+//(*Store).SPAs : return all single packet authorization profiles ordered by name
+func (s *Store) SPAs() ([]SPA, error) {
+	rows, err := s.q.Query("SELECT Name,Port,Key,Access,Duration,MaxAge FROM spa ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var spas []SPA
+	for rows.Next() {
+		var a SPA
+		if err = rows.Scan(&a.Name, &a.Port, &a.Key, &a.Access, &a.Duration, &a.MaxAge); err != nil {
+			return nil, err
+		}
+		spas = append(spas, a)
+	}
+	return spas, rows.Err()
+}
+
+//(*Store).SPA : return the single packet authorization profile of the given name (false if it does not exist)
+func (s *Store) SPA(name string) (SPA, bool, error) {
+	a := SPA{Name: name}
+	err := s.q.QueryRow(
+		"SELECT Port,Key,Access,Duration,MaxAge FROM spa WHERE Name=?", name,
+	).Scan(&a.Port, &a.Key, &a.Access, &a.Duration, &a.MaxAge)
+	if err == sql.ErrNoRows {
+		return a, false, nil
+	}
+	return a, err == nil, err
+}
+
+//(*Store).changeSPAs : run spa mutation and record the profiles before and after it
+func (s *Store) changeSPAs(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.SPAs()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.SPAs()
+		if err != nil {
+			return err
+		}
+		return tx.record("spa", action, old, new)
+	})
+}
+
+//(*Store).writeSPAs : replace the single packet authorization profiles without recording the change
+func (s *Store) writeSPAs(spas []SPA) error {
+	if _, err := s.q.Exec("DELETE FROM spa;"); err != nil {
+		return err
+	}
+	for _, a := range spas {
+		if err := s.writeSPA(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//(*Store).writeSPA : insert or replace single packet authorization profile without recording the change
+func (s *Store) writeSPA(a SPA) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO spa (Name,Port,Key,Access,Duration,MaxAge) VALUES (?,?,?,?,?,?);",
+		a.Name, a.Port, a.Key, a.Access, a.Duration, a.MaxAge,
+	)
+	return err
+}
+
+//(*Store).AddSPA : create single packet authorization profile (replacing an existing profile of the same name)
+func (s *Store) AddSPA(a SPA) error {
+	return s.changeSPAs("add", func(tx *Store) error {
+		return tx.writeSPA(a)
+	})
+}
+
+//(*Store).RemoveSPA : remove the single packet authorization profile of the given name
+func (s *Store) RemoveSPA(name string) error {
+	return s.changeSPAs("remove", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM spa WHERE Name=?;", name)
+		return err
+	})
+}
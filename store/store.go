@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"goaway2/schema"
+
+	_ "github.com/mattn/go-sqlite3" //mysql-driver
+)
+
+/***Variables***/
+
+//DefaultPath : location of the firewall database shared by the daemon and cli
+const DefaultPath = "/var/lib/goaway/database.db"
+
+//PathEnv : environment variable overriding the location of the firewall database
+const PathEnv = "GOAWAY_DB"
+
+//querier : shared methods of database and transaction used to run queries
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//Store : typed access to the firewall database shared by the daemon and cli
+type Store struct {
+	db   *sql.DB
+	q    querier
+	user string // user recorded within the change history
+	// set when bound to a transaction
+	tx    bool
+	batch int64
+}
+
+/***Functions***/
+
+//Open : open database at the given path (or ":memory:") and bring its layout up to date
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to launch SQLITE3: %s", err.Error())
+	}
+	// configure database connection
+	db.SetMaxOpenConns(1)
+	db.Exec("PRAGMA journal_mode=WAL;")
+	// bring database layout up to date
+	if err = schema.Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to migrate database: %s", err.Error())
+	}
+	return &Store{db: db, q: db, user: "unknown"}, nil
+}
+
+//Path : return the location of the firewall database (PathEnv if set, DefaultPath otherwise)
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+//OpenDefault : open the firewall database at Path() creating its directory if missing
+func OpenDefault() (*Store, error) {
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create database directory: %s", err.Error())
+	}
+	return Open(path)
+}
+
+/***Methods***/
+
+//(*Store).Close : close the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+//(*Store).SetUser : set the user recorded with every change made through the store
+func (s *Store) SetUser(user string) {
+	s.user = user
+}
+
+//(*Store).Transaction : run function with a store bound to a single transaction
+// the transaction is committed when the function returns nil and rolled back otherwise
+// and all changes made within it are recorded as a single batch in the history
+func (s *Store) Transaction(fn func(tx *Store) error) error {
+	if s.tx {
+		return fn(s)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	txs := &Store{db: s.db, q: tx, user: s.user, tx: true}
+	if err = tx.QueryRow("SELECT IFNULL(max(Batch)+1, 1) FROM history").Scan(&txs.batch); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = fn(txs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
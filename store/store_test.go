@@ -0,0 +1,489 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+/***Functions***/
+
+//openMemory : open store backed by an in-memory database
+func openMemory(t *testing.T) *Store {
+	st, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	return st
+}
+
+/***Unit-Tests***/
+
+func TestStoreRules(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	for _, port := range []string{"22", "80", "443"} {
+		if err := st.AppendRule(Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: port}); err != nil {
+			t.Fatalf("Unable to append rule: %s\n", err.Error())
+		}
+	}
+	if err := st.InsertRule(1, Rule{Zone: "any", FromIP: "10.0.0.1", FromPort: "any", ToIP: "any", ToPort: "any", Audit: true}); err != nil {
+		t.Fatalf("Unable to insert rule: %s\n", err.Error())
+	}
+	if err := st.DeleteRule(0); err != nil {
+		t.Fatalf("Unable to delete rule: %s\n", err.Error())
+	}
+	rules, err := st.Rules()
+	if err != nil {
+		t.Fatalf("Unable to collect rules: %s\n", err.Error())
+	}
+	if len(rules) != 3 || rules[0].FromIP != "10.0.0.1" || !rules[0].Audit || rules[2].ToPort != "443" || rules[2].RuleNum != 2 {
+		t.Fatalf("Unexpected rules: %+v\n", rules)
+	}
+	if next, _ := st.NextRuleNum(); next != 3 {
+		t.Fatalf("Unexpected next rule-number: %d\n", next)
+	}
+	if exists, _ := st.HasRule(rules[1]); !exists {
+		t.Fatalf("Unable to find existing rule: %+v\n", rules[1])
+	}
+}
+
+func TestStorePath(t *testing.T) {
+	defer os.Setenv(PathEnv, os.Getenv(PathEnv))
+	os.Unsetenv(PathEnv)
+	if path := Path(); path != DefaultPath {
+		t.Fatalf("Unexpected default path: %s\n", path)
+	}
+	// check the environment overrides the default path
+	os.Setenv(PathEnv, "/tmp/goaway.db")
+	if path := Path(); path != "/tmp/goaway.db" {
+		t.Fatalf("Unexpected path: %s\n", path)
+	}
+}
+
+func TestStoreOptions(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.SetOption("Inbound", "deny"); err != nil {
+		t.Fatalf("Unable to set option: %s\n", err.Error())
+	}
+	if err := st.SetOption("Forward", "allow"); err != nil {
+		t.Fatalf("Unable to set option: %s\n", err.Error())
+	}
+	if err := st.SetOption("Bogus", "deny"); err == nil {
+		t.Fatalf("Able to set unknown option\n")
+	}
+	opts, err := st.Options()
+	if err != nil || opts.Inbound != "deny" || opts.Outbound != "allow" || opts.Forward != "allow" || opts.AskTimeout != 30 || opts.AskVerdict != "deny" || opts.BlacklistAction != "drop" || opts.TarpitFlows != 1024 || opts.Audit || opts.AuditRetention != 2592000 {
+		t.Fatalf("Unexpected options: %+v (%v)\n", opts, err)
+	}
+}
+
+func TestStoreLists(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddEntry(Blacklist, Entry{IPAddress: "10.0.0.2", Reason: "test"}); err != nil {
+		t.Fatalf("Unable to add entry: %s\n", err.Error())
+	}
+	if exists, _ := st.HasEntry(Blacklist, "10.0.0.2"); !exists {
+		t.Fatalf("Missing blacklist entry\n")
+	}
+	if blocked, _ := st.Blacklisted("1.1.1.1", "10.0.0.2"); blocked != "10.0.0.2" {
+		t.Fatalf("Unexpected blacklisted address: %q\n", blocked)
+	}
+	if blocked, err := st.Blacklisted("1.1.1.1", "2.2.2.2", ""); blocked != "" || err != nil {
+		t.Fatalf("Unexpected blacklisted address: %q (%v)\n", blocked, err)
+	}
+	// check country entries are matched like addresses
+	st.AddEntry(Blacklist, Entry{IPAddress: "country:RU", Reason: "geo"})
+	if blocked, _ := st.Blacklisted("1.1.1.1", "2.2.2.2", "", "country:RU"); blocked != "country:RU" {
+		t.Fatalf("Unexpected blacklisted country: %q\n", blocked)
+	}
+	st.RemoveEntry(Blacklist, "country:RU")
+	if err := st.RemoveEntry(Blacklist, "10.0.0.2"); err != nil {
+		t.Fatalf("Unable to remove entry: %s\n", err.Error())
+	}
+	if entries, _ := st.Entries(Blacklist); len(entries) != 0 {
+		t.Fatalf("Unexpected entries: %+v\n", entries)
+	}
+	if err := st.AddEntry("greylist", Entry{IPAddress: "10.0.0.2"}); err == nil {
+		t.Fatalf("Able to add entry to unknown list\n")
+	}
+}
+
+func TestStoreListExpiry(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	expires := time.Now().Add(time.Hour).UTC().Format(DateLayout)
+	st.AddEntry(Blacklist, Entry{IPAddress: "10.0.0.2", Reason: "honeypot:23", Expires: expires})
+	st.AddEntry(Blacklist, Entry{IPAddress: "10.0.0.3", Reason: "honeypot:23", Expires: "2000-01-01 00:00:00"})
+	blocked, until, err := st.BlacklistedUntil("10.0.0.2")
+	if err != nil || blocked != "10.0.0.2" || until.Format(DateLayout) != expires {
+		t.Fatalf("Unexpected blacklisted address: %q until %s (%v)\n", blocked, until, err)
+	}
+	// check expired entries are ignored and replaced when the address is added again
+	if blocked, _ := st.Blacklisted("10.0.0.3"); blocked != "" {
+		t.Fatalf("Expired entry is still blacklisted: %q\n", blocked)
+	}
+	if exists, _ := st.HasEntry(Blacklist, "10.0.0.3"); exists {
+		t.Fatalf("Expired entry still exists\n")
+	}
+	if err = st.AddEntry(Blacklist, Entry{IPAddress: "10.0.0.3", Reason: "test"}); err != nil {
+		t.Fatalf("Unable to replace expired entry: %s\n", err.Error())
+	}
+	if entries, _ := st.Entries(Blacklist); len(entries) != 2 || entries[1].Reason != "test" || entries[1].Expires != "" {
+		t.Fatalf("Unexpected entries: %+v\n", entries)
+	}
+	if blocked, until, _ := st.BlacklistedUntil("10.0.0.3"); blocked != "10.0.0.3" || !until.IsZero() {
+		t.Fatalf("Unexpected blacklisted address: %q until %s\n", blocked, until)
+	}
+}
+
+func TestStoreTransaction(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	st.AddEntry(Whitelist, Entry{IPAddress: "10.0.0.3", Reason: "test"})
+	// check failed transaction is rolled back
+	err := st.Transaction(func(tx *Store) error {
+		if err := tx.FlushEntries(Whitelist); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatalf("Expected transaction error\n")
+	}
+	if entries, _ := st.Entries(Whitelist); len(entries) != 1 {
+		t.Fatalf("Transaction was not rolled back: %+v\n", entries)
+	}
+}
+
+func TestStoreUndo(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	st.SetUser("tester")
+	for _, port := range []string{"22", "80"} {
+		st.AppendRule(Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: port})
+	}
+	st.AddEntry(Blacklist, Entry{IPAddress: "10.0.0.4", Reason: "test"})
+	// flush rules and blacklist within a single batch
+	st.Transaction(func(tx *Store) error {
+		if err := tx.FlushRules(); err != nil {
+			return err
+		}
+		return tx.FlushEntries(Blacklist)
+	})
+	changes, err := st.History(10)
+	if err != nil {
+		t.Fatalf("Unable to collect history: %s\n", err.Error())
+	}
+	if len(changes) != 5 || changes[0].Batch != 4 || changes[1].Batch != 4 || changes[0].User != "tester" {
+		t.Fatalf("Unexpected history: %+v\n", changes)
+	}
+	// check undo restores both flushed tables
+	if _, err = st.Undo(1); err != nil {
+		t.Fatalf("Unable to undo: %s\n", err.Error())
+	}
+	if rules, _ := st.Rules(); len(rules) != 2 || rules[1].ToPort != "80" || rules[1].RuleNum != 1 {
+		t.Fatalf("Unexpected rules after undo: %+v\n", rules)
+	}
+	if exists, _ := st.HasEntry(Blacklist, "10.0.0.4"); !exists {
+		t.Fatalf("Missing blacklist entry after undo\n")
+	}
+	// check undo skips batches that were already undone
+	if _, err = st.Undo(2); err != nil {
+		t.Fatalf("Unable to undo: %s\n", err.Error())
+	}
+	if rules, _ := st.Rules(); len(rules) != 1 || rules[0].ToPort != "22" {
+		t.Fatalf("Unexpected rules after undo: %+v\n", rules)
+	}
+	if exists, _ := st.HasEntry(Blacklist, "10.0.0.4"); exists {
+		t.Fatalf("Unexpected blacklist entry after undo\n")
+	}
+}
+
+func TestStorePending(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	now := time.Now()
+	provisional := func(r Rule) {
+		err := st.Transaction(func(tx *Store) error {
+			if err := tx.AppendRule(r); err != nil {
+				return err
+			}
+			_, err := tx.Provisional(now.Add(time.Minute))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Unable to make provisional change: %s\n", err.Error())
+		}
+	}
+	if _, err := st.Provisional(now); err == nil {
+		t.Fatalf("Able to make provisional change outside a transaction\n")
+	}
+	// check confirmed changes are kept
+	provisional(Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22"})
+	if confirmed, err := st.Confirm(now); err != nil || confirmed != 1 {
+		t.Fatalf("Unexpected confirmation: %d (%v)\n", confirmed, err)
+	}
+	if changes, _ := st.Expire(now.Add(time.Hour)); len(changes) != 0 {
+		t.Fatalf("Confirmed change was rolled back: %+v\n", changes)
+	}
+	// check expired changes are rolled back along with later changes
+	provisional(Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "80"})
+	st.AddEntry(Whitelist, Entry{IPAddress: "10.0.0.5", Reason: "test"})
+	if changes, _ := st.Expire(now); len(changes) != 0 {
+		t.Fatalf("Change was rolled back before its deadline: %+v\n", changes)
+	}
+	if changes, err := st.Expire(now.Add(time.Hour)); err != nil || len(changes) != 2 {
+		t.Fatalf("Unexpected rollback: %+v (%v)\n", changes, err)
+	}
+	if rules, _ := st.Rules(); len(rules) != 1 || rules[0].ToPort != "22" {
+		t.Fatalf("Unexpected rules after rollback: %+v\n", rules)
+	}
+	if pending, _ := st.Pending(); len(pending) != 0 {
+		t.Fatalf("Unexpected pending changes: %+v\n", pending)
+	}
+}
+
+func TestStoreNetZones(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddNetZone("dmz", "deny", "deny"); err != nil {
+		t.Fatalf("Unable to add zone: %s\n", err.Error())
+	}
+	if err := st.BindInterface("eth1", "dmz"); err != nil {
+		t.Fatalf("Unable to bind interface: %s\n", err.Error())
+	}
+	// check rebinding moves the interface to the other zone
+	st.BindInterface("eth0", "dmz")
+	st.BindInterface("eth0", "public")
+	zones, err := st.NetZones()
+	if err != nil {
+		t.Fatalf("Unable to collect zones: %s\n", err.Error())
+	}
+	for _, z := range zones {
+		if (z.Name == "dmz" && (len(z.Interfaces) != 1 || z.Interfaces[0] != "eth1")) ||
+			(z.Name == "public" && (len(z.Interfaces) != 1 || z.Interfaces[0] != "eth0")) {
+			t.Fatalf("Unexpected zone: %+v\n", z)
+		}
+	}
+	// check zones with rules can not be removed
+	st.AppendRule(Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "22", NetZone: "dmz"})
+	if err = st.RemoveNetZone("dmz"); err == nil {
+		t.Fatalf("Able to remove zone with rules\n")
+	}
+	st.FlushRules()
+	if err = st.RemoveNetZone("dmz"); err != nil {
+		t.Fatalf("Unable to remove zone: %s\n", err.Error())
+	}
+	if exists, _ := st.HasNetZone("dmz"); exists {
+		t.Fatalf("Zone was not removed\n")
+	}
+	// check undo restores the zone along with its binding
+	st.Undo(1)
+	if zones, _ = st.NetZones(); len(zones) != 5 {
+		t.Fatalf("Unexpected zones after undo: %+v\n", zones)
+	}
+}
+
+func TestStoreNAT(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddPortForward(PortForward{Interface: "eth0", Protocol: "tcp", Port: "8080", ToIP: "10.0.1.5", ToPort: "80"}); err != nil {
+		t.Fatalf("Unable to add port forward: %s\n", err.Error())
+	}
+	if err := st.AddMasquerade(Masquerade{Interface: "eth0", Source: "any"}); err != nil {
+		t.Fatalf("Unable to add masquerade: %s\n", err.Error())
+	}
+	// check forwarding the same port again replaces the forward
+	st.AddPortForward(PortForward{Interface: "eth0", Protocol: "tcp", Port: "8080", ToIP: "10.0.1.6", ToPort: "80"})
+	n, err := st.NAT()
+	if err != nil {
+		t.Fatalf("Unable to collect nat: %s\n", err.Error())
+	}
+	if len(n.Forwards) != 1 || n.Forwards[0].ToIP != "10.0.1.6" || len(n.Masquerades) != 1 {
+		t.Fatalf("Unexpected nat: %+v\n", n)
+	}
+	// check removal can be undone
+	st.RemovePortForward("eth0", "tcp", "8080")
+	st.RemoveMasquerade("eth0")
+	if n, _ = st.NAT(); len(n.Forwards) != 0 || len(n.Masquerades) != 0 {
+		t.Fatalf("Unexpected nat after removal: %+v\n", n)
+	}
+	st.Undo(2)
+	if n, _ = st.NAT(); len(n.Forwards) != 1 || len(n.Masquerades) != 1 {
+		t.Fatalf("Unexpected nat after undo: %+v\n", n)
+	}
+}
+
+func TestStoreFeeds(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddFeed(Feed{Name: "drop", Source: "/var/lib/feeds/drop.txt", Interval: 3600}); err != nil {
+		t.Fatalf("Unable to add feed: %s\n", err.Error())
+	}
+	// check refresh results are stored without a history entry
+	if err := st.SetFeedStatus("drop", 42, nil); err != nil {
+		t.Fatalf("Unable to set feed status: %s\n", err.Error())
+	}
+	st.SetFeedStatus("drop", 0, errors.New("unreachable"))
+	f, ok, err := st.Feed("drop")
+	if err != nil || !ok || f.Entries != 42 || f.LastRefresh == "" || f.LastError != "unreachable" {
+		t.Fatalf("Unexpected feed: %+v (%v)\n", f, err)
+	}
+	if changes, _ := st.History(10); len(changes) != 1 || changes[0].Table != "feeds" {
+		t.Fatalf("Unexpected history: %+v\n", changes)
+	}
+	// check removal can be undone
+	st.RemoveFeed("drop")
+	if _, ok, _ = st.Feed("drop"); ok {
+		t.Fatalf("Feed still exists after removal\n")
+	}
+	st.Undo(1)
+	if feeds, _ := st.Feeds(); len(feeds) != 1 || feeds[0].Source != "/var/lib/feeds/drop.txt" {
+		t.Fatalf("Unexpected feeds after undo: %+v\n", feeds)
+	}
+}
+
+func TestStoreKnocks(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddKnock(Knock{Name: "ssh", Sequence: "7000/tcp,8000/udp", Window: 10, Target: "22/tcp", Duration: 300}); err != nil {
+		t.Fatalf("Unable to add knock profile: %s\n", err.Error())
+	}
+	k, ok, err := st.Knock("ssh")
+	if err != nil || !ok || k.Sequence != "7000/tcp,8000/udp" || k.Target != "22/tcp" || k.Duration != 300 {
+		t.Fatalf("Unexpected knock profile: %+v (%v)\n", k, err)
+	}
+	// check removal can be undone
+	st.RemoveKnock("ssh")
+	if _, ok, _ = st.Knock("ssh"); ok {
+		t.Fatalf("Knock profile still exists after removal\n")
+	}
+	st.Undo(1)
+	if knocks, _ := st.Knocks(); len(knocks) != 1 || knocks[0].Window != 10 {
+		t.Fatalf("Unexpected knock profiles after undo: %+v\n", knocks)
+	}
+}
+
+func TestStoreSPA(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddSPA(SPA{Name: "ssh", Port: 62201, Key: "c2VjcmV0LXNlY3JldC1zZWNyZXQ=", Access: "22/tcp", Duration: 300, MaxAge: 30}); err != nil {
+		t.Fatalf("Unable to add spa profile: %s\n", err.Error())
+	}
+	a, ok, err := st.SPA("ssh")
+	if err != nil || !ok || a.Port != 62201 || a.Access != "22/tcp" || a.MaxAge != 30 {
+		t.Fatalf("Unexpected spa profile: %+v (%v)\n", a, err)
+	}
+	// check removal can be undone
+	st.RemoveSPA("ssh")
+	if _, ok, _ = st.SPA("ssh"); ok {
+		t.Fatalf("Spa profile still exists after removal\n")
+	}
+	st.Undo(1)
+	if spas, _ := st.SPAs(); len(spas) != 1 || spas[0].Key != "c2VjcmV0LXNlY3JldC1zZWNyZXQ=" {
+		t.Fatalf("Unexpected spa profiles after undo: %+v\n", spas)
+	}
+}
+
+func TestStoreHoneypots(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	if err := st.AddHoneypot(Honeypot{Port: 23, Protocol: "tcp", Expiry: 3600}); err != nil {
+		t.Fatalf("Unable to add honeypot: %s\n", err.Error())
+	}
+	st.AddHoneypot(Honeypot{Port: 23, Protocol: "udp", Unverified: true})
+	h, ok, err := st.Honeypot(23, "tcp")
+	if err != nil || !ok || h.Expiry != 3600 || h.Unverified {
+		t.Fatalf("Unexpected honeypot: %+v (%v)\n", h, err)
+	}
+	if h, _, _ = st.Honeypot(23, "udp"); !h.Unverified {
+		t.Fatalf("Unexpected unverified honeypot: %+v\n", h)
+	}
+	// check honeypot sources are blacklisted once without recording the entry
+	for i, want := range []bool{true, false} {
+		if added, err := st.AddHoneypotEntry(Entry{IPAddress: "198.51.100.7", Reason: "honeypot:23"}); added != want || err != nil {
+			t.Fatalf("Unexpected honeypot entry #%d: %v (%v)\n", i, added, err)
+		}
+	}
+	if changes, _ := st.History(10); len(changes) != 2 {
+		t.Fatalf("Honeypot entry was recorded within the history: %+v\n", changes)
+	}
+	// check removal can be undone
+	st.RemoveHoneypot(23, "tcp")
+	if _, ok, _ = st.Honeypot(23, "tcp"); ok {
+		t.Fatalf("Honeypot still exists after removal\n")
+	}
+	st.Undo(1)
+	if honeypots, _ := st.Honeypots(); len(honeypots) != 2 || honeypots[0].Protocol != "tcp" || honeypots[0].Expiry != 3600 {
+		t.Fatalf("Unexpected honeypots after undo: %+v\n", honeypots)
+	}
+}
+
+func TestStoreAnswers(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	answer := Rule{ToIP: "93.184.216.34", ToPort: "443", Exe: "/usr/bin/curl", Action: "deny"}
+	for _, r := range []Rule{answer, answer, {ToIP: "192.0.2.1", ToPort: "53", UID: "1000", Action: "allow"}} {
+		if err := st.AddAnswer(r); err != nil {
+			t.Fatalf("Unable to add answer: %s\n", err.Error())
+		}
+	}
+	// check identical answers are added once and never recorded within the history
+	answers, err := st.Answers()
+	if err != nil || len(answers) != 2 || answers[0].Zone != "outbound" || answers[0].Exe != "/usr/bin/curl" || answers[1].UID != "1000" {
+		t.Fatalf("Unexpected answers: %+v (%v)\n", answers, err)
+	}
+	if changes, _ := st.History(10); len(changes) != 0 {
+		t.Fatalf("Answers were recorded within the history: %+v\n", changes)
+	}
+	if removed, err := st.RemoveAnswer(answers[0].RuleNum); !removed || err != nil {
+		t.Fatalf("Unable to remove answer: %v\n", err)
+	}
+	if removed, _ := st.RemoveAnswer(answers[0].RuleNum); removed {
+		t.Fatalf("Removed answer twice\n")
+	}
+	if answers, _ = st.Answers(); len(answers) != 1 || answers[0].ToPort != "53" {
+		t.Fatalf("Unexpected answers after removal: %+v\n", answers)
+	}
+}
+
+func TestStoreAuditPrune(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	for i := 0; i < 3; i++ {
+		if err := st.RecordAudit(AuditEntry{Protocol: "TCP", FromIP: "198.51.100.7", ToIP: "192.168.200.114", ToPort: 22, Reason: "rule"}); err != nil {
+			t.Fatalf("Unable to record audit entry: %s\n", err.Error())
+		}
+	}
+	// check entries recorded after the given time are kept
+	if n, err := st.PruneAuditLog(time.Now().Add(-time.Hour)); n != 0 || err != nil {
+		t.Fatalf("Pruned recent audit entries: %d (%v)\n", n, err)
+	}
+	if n, err := st.PruneAuditLog(time.Now().Add(time.Minute)); n != 3 || err != nil {
+		t.Fatalf("Unexpected number of pruned audit entries: %d (%v)\n", n, err)
+	}
+	if entries, _ := st.AuditLog(10); len(entries) != 0 {
+		t.Fatalf("Unexpected audit entries after pruning: %+v\n", entries)
+	}
+}
+
+func TestStoreListDeleted(t *testing.T) {
+	st := openMemory(t)
+	defer st.Close()
+	st.q.Exec("INSERT INTO whitelist VALUES ('10.0.0.1',datetime('now'),'old',1);")
+	st.q.Exec("INSERT INTO blacklist (IPAddress,EntryDate,LastSeen,Reason,LogicalDelete) VALUES ('10.0.0.2',datetime('now'),datetime('now'),'old',1);")
+	// check logically deleted entries are not contained and can be added again
+	for _, check := range []struct{ list, ip string }{{Whitelist, "10.0.0.1"}, {Blacklist, "10.0.0.2"}} {
+		if exists, err := st.HasEntry(check.list, check.ip); exists || err != nil {
+			t.Fatalf("Logically deleted %s entry %s is contained (%v)\n", check.list, check.ip, err)
+		}
+		st.AddEntry(check.list, Entry{IPAddress: check.ip, Reason: "new"})
+		if entries, _ := st.Entries(check.list); len(entries) != 1 || entries[0].Reason != "new" {
+			t.Fatalf("Unexpected %s entries: %+v\n", check.list, entries)
+		}
+	}
+}
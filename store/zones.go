@@ -0,0 +1,164 @@
+package store
+
+import "fmt"
+
+/***Variables***/
+
+//NetZone : named zone with its own defaults that network interfaces are bound to
+type NetZone struct {
+	Name       string
+	Inbound    string
+	Outbound   string
+	Interfaces []string
+}
+
+/***Methods***/
+
+//(*Store).NetZones : return all zones along with their bound interfaces ordered by name
+func (s *Store) NetZones() ([]NetZone, error) {
+	rows, err := s.q.Query("SELECT Name,Inbound,Outbound FROM zones ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	var zones []NetZone
+	for rows.Next() {
+		var z NetZone
+		if err = rows.Scan(&z.Name, &z.Inbound, &z.Outbound); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// collect bound interfaces
+	for n := range zones {
+		if zones[n].Interfaces, err = s.zoneInterfaces(zones[n].Name); err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
+
+//(*Store).zoneInterfaces : return interfaces bound to the given zone
+func (s *Store) zoneInterfaces(name string) ([]string, error) {
+	rows, err := s.q.Query("SELECT Interface FROM zoneifaces WHERE NetZone=? ORDER BY Interface", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ifaces []string
+	for rows.Next() {
+		var iface string
+		if err = rows.Scan(&iface); err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, iface)
+	}
+	return ifaces, rows.Err()
+}
+
+//(*Store).HasNetZone : check if zone with the given name exists
+func (s *Store) HasNetZone(name string) (bool, error) {
+	var exists int
+	err := s.q.QueryRow("SELECT IFNULL((SELECT 1 FROM zones WHERE Name=?), 0)", name).Scan(&exists)
+	return exists == 1, err
+}
+
+//(*Store).changeNetZones : run zone mutation and record all zones before and after it
+func (s *Store) changeNetZones(action string, fn func(tx *Store) error) error {
+	return s.Transaction(func(tx *Store) error {
+		old, err := tx.NetZones()
+		if err != nil {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+		new, err := tx.NetZones()
+		if err != nil {
+			return err
+		}
+		return tx.record("zones", action, old, new)
+	})
+}
+
+//(*Store).writeNetZones : replace all zones and interface bindings without recording the change
+func (s *Store) writeNetZones(zones []NetZone) error {
+	if _, err := s.q.Exec("DELETE FROM zones;"); err != nil {
+		return err
+	}
+	if _, err := s.q.Exec("DELETE FROM zoneifaces;"); err != nil {
+		return err
+	}
+	for _, z := range zones {
+		if _, err := s.q.Exec("INSERT INTO zones VALUES (?,?,?);", z.Name, z.Inbound, z.Outbound); err != nil {
+			return err
+		}
+		for _, iface := range z.Interfaces {
+			if _, err := s.q.Exec("INSERT INTO zoneifaces VALUES (?,?);", iface, z.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//(*Store).AddNetZone : create zone with the given defaults
+func (s *Store) AddNetZone(name, inbound, outbound string) error {
+	return s.changeNetZones("add", func(tx *Store) error {
+		_, err := tx.q.Exec("INSERT INTO zones VALUES (?,?,?);", name, inbound, outbound)
+		return err
+	})
+}
+
+//(*Store).RemoveNetZone : remove zone and its interface bindings (fails while rules belong to it)
+func (s *Store) RemoveNetZone(name string) error {
+	return s.changeNetZones("remove", func(tx *Store) error {
+		var rules int
+		if err := tx.q.QueryRow("SELECT count(*) FROM rules WHERE NetZone=?", name).Scan(&rules); err != nil {
+			return err
+		}
+		if rules > 0 {
+			return fmt.Errorf("zone %q still has %d rule(s)", name, rules)
+		}
+		if _, err := tx.q.Exec("DELETE FROM zoneifaces WHERE NetZone=?;", name); err != nil {
+			return err
+		}
+		_, err := tx.q.Exec("DELETE FROM zones WHERE Name=?;", name)
+		return err
+	})
+}
+
+//(*Store).SetNetZoneDefaults : set the defaults of the given zone
+func (s *Store) SetNetZoneDefaults(name, inbound, outbound string) error {
+	return s.changeNetZones("set", func(tx *Store) error {
+		_, err := tx.q.Exec("UPDATE zones SET Inbound=?, Outbound=? WHERE Name=?;", inbound, outbound, name)
+		return err
+	})
+}
+
+//(*Store).BindInterface : bind interface to the given zone (replacing any previous binding)
+func (s *Store) BindInterface(iface, name string) error {
+	return s.changeNetZones("bind", func(tx *Store) error {
+		_, err := tx.q.Exec("INSERT OR REPLACE INTO zoneifaces VALUES (?,?);", iface, name)
+		return err
+	})
+}
+
+//(*Store).UnbindInterface : remove the zone binding of the given interface
+func (s *Store) UnbindInterface(iface string) error {
+	return s.changeNetZones("unbind", func(tx *Store) error {
+		_, err := tx.q.Exec("DELETE FROM zoneifaces WHERE Interface=?;", iface)
+		return err
+	})
+}
+
+//(*Store).SetNetZones : replace all zones and interface bindings
+func (s *Store) SetNetZones(zones []NetZone) error {
+	return s.changeNetZones("set", func(tx *Store) error {
+		return tx.writeNetZones(zones)
+	})
+}
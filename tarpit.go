@@ -0,0 +1,151 @@
+package goaway2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/***Variables***/
+
+//tarpit defaults
+const (
+	DefaultTarpitFlows = 1024             // concurrent tarpitted flows when the option is unset
+	tarpitHold         = 10 * time.Minute // time a flow is counted after its last SYN
+	tarpitSweepTime    = time.Minute      // minimum time between sweeps of expired flows
+	tarpitTTL          = 64
+	tcpHeaderSize      = 20
+	ipv4HeaderSize     = 20
+)
+
+//TarpitMark : firewall mark of the SYN-ACKs sent by the tarpit (accept them before the OUTPUT queue)
+const TarpitMark = 0x6761
+
+//TarpitSender : sends the zero-window SYN-ACK answering a tarpitted SYN
+type TarpitSender interface {
+	// Send : transmit the ip packet to the destination address
+	Send(dst net.IP, packet []byte) error
+}
+
+//Tarpit : answers SYNs of tarpitted connections with a zero-window SYN-ACK and ignores the flows
+// afterwards, the peer keeps the connection open probing a window that never opens
+type Tarpit struct {
+	MaxFlows int          // concurrent tarpitted flows, SYNs of further flows are dropped
+	Sender   TarpitSender // sender of the SYN-ACKs (raw ip socket marked with TarpitMark if nil)
+
+	lock  sync.Mutex
+	flows map[string]time.Time // expiry of the tarpitted flows
+	swept time.Time
+}
+
+/***Functions***/
+
+//NewTarpit : create tarpit holding at most maxFlows flows (DefaultTarpitFlows if not positive)
+func NewTarpit(maxFlows int) *Tarpit {
+	if maxFlows <= 0 {
+		maxFlows = DefaultTarpitFlows
+	}
+	return &Tarpit{MaxFlows: maxFlows, flows: make(map[string]time.Time)}
+}
+
+//tarpitSynAck : build the ipv4 packet answering a SYN with a zero-window SYN-ACK
+func tarpitSynAck(pkt *PacketData, seq uint32) ([]byte, error) {
+	return tcpPacket(pkt.DstIP, pkt.SrcIP, pkt.DstPort, pkt.SrcPort, seq, pkt.Seq+1, 0x12) // SYN|ACK
+}
+
+//tcpPacket : build the ipv4 packet of a zero-window tcp segment without data
+func tcpPacket(srcIP, dstIP string, srcPort, dstPort int64, seq, ack uint32, flags byte) ([]byte, error) {
+	src, dst := net.ParseIP(srcIP).To4(), net.ParseIP(dstIP).To4()
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("invalid ipv4 flow: %s -> %s", srcIP, dstIP)
+	}
+	b := make([]byte, ipv4HeaderSize+tcpHeaderSize)
+	// ip header: version/ihl, length, don't fragment, ttl, protocol and addresses
+	ip := b[:ipv4HeaderSize]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(len(b)))
+	binary.BigEndian.PutUint16(ip[6:], 0x4000)
+	ip[8], ip[9] = tarpitTTL, 6
+	copy(ip[12:16], src)
+	copy(ip[16:20], dst)
+	binary.BigEndian.PutUint16(ip[10:], checksum(ip, 0))
+	// tcp header: ports, sequence/acknowledgment numbers and flags with a zero window
+	tcp := b[ipv4HeaderSize:]
+	binary.BigEndian.PutUint16(tcp[0:], uint16(srcPort))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(dstPort))
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12], tcp[13] = tcpHeaderSize/4<<4, flags
+	// pseudo header: addresses, protocol and tcp length
+	var pseudo uint32
+	for i := 0; i < 4; i += 2 {
+		pseudo += uint32(binary.BigEndian.Uint16(src[i:])) + uint32(binary.BigEndian.Uint16(dst[i:]))
+	}
+	pseudo += 6 + tcpHeaderSize
+	binary.BigEndian.PutUint16(tcp[16:], checksum(tcp, pseudo))
+	return b, nil
+}
+
+//checksum : internet checksum of data added to the given partial sum
+func checksum(data []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+/***Methods***/
+
+//(*Tarpit).Trap : answer the SYN of a tarpitted connection (false if the packet is no inbound/forwarded
+// SYN or the tarpit is full), other packets of tarpitted flows are left to be dropped
+func (t *Tarpit) Trap(pkt *PacketData, now time.Time) (bool, error) {
+	if t == nil || !pkt.Syn || !strings.EqualFold(pkt.Protocol, "tcp") || pkt.Direction() == "outbound" {
+		return false, nil
+	}
+	flow := fmt.Sprintf("%s:%d>%s:%d", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort)
+	t.lock.Lock()
+	if now.Sub(t.swept) >= tarpitSweepTime {
+		t.swept = now
+		for f, expires := range t.flows {
+			if !now.Before(expires) {
+				delete(t.flows, f)
+			}
+		}
+	}
+	// retransmitted SYNs of a tarpitted flow are answered again
+	if _, ok := t.flows[flow]; !ok && len(t.flows) >= t.MaxFlows {
+		t.lock.Unlock()
+		return false, nil
+	}
+	t.flows[flow] = now.Add(tarpitHold)
+	if t.Sender == nil {
+		t.Sender = &rawSender{mark: TarpitMark}
+	}
+	sender := t.Sender
+	t.lock.Unlock()
+	packet, err := tarpitSynAck(pkt, rand.Uint32())
+	if err != nil {
+		return false, err
+	}
+	if err = sender.Send(net.ParseIP(pkt.SrcIP), packet); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//(*Tarpit).Flows : return the number of currently tarpitted flows
+func (t *Tarpit) Flows() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.flows)
+}
@@ -0,0 +1,41 @@
+package goaway2
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+/***Variables***/
+
+//rawSender : tarpit/reject sender writing complete ip packets to a raw socket marked with its mark
+type rawSender struct {
+	mark int // firewall mark of the sent packets (accepted by iptables.sh)
+	once sync.Once
+	fd   int
+	err  error
+}
+
+/***Methods***/
+
+//(*rawSender).open : create the raw socket on first use
+func (s *rawSender) open() {
+	s.fd, s.err = syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.IPPROTO_RAW)
+	if s.err != nil {
+		return
+	}
+	if s.err = syscall.SetsockoptInt(s.fd, syscall.SOL_SOCKET, syscall.SO_MARK, s.mark); s.err != nil {
+		syscall.Close(s.fd)
+	}
+}
+
+//(*rawSender).Send : transmit the ip packet to the destination address
+func (s *rawSender) Send(dst net.IP, packet []byte) error {
+	s.once.Do(s.open)
+	if s.err != nil {
+		return s.err
+	}
+	addr := &syscall.SockaddrInet4{}
+	copy(addr.Addr[:], dst.To4())
+	return syscall.Sendto(s.fd, packet, 0, addr)
+}
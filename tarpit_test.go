@@ -0,0 +1,158 @@
+package goaway2
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"goaway2/profiles"
+	"goaway2/store"
+
+	netfilter "github.com/AkihiroSuda/go-netfilter-queue"
+)
+
+/***Variables***/
+
+//fakeSender : tarpit sender recording the sent packets
+type fakeSender struct {
+	lock    sync.Mutex
+	packets [][]byte
+}
+
+/***Functions***/
+
+//tarpitSyn : build inbound SYN of the given flow
+func tarpitSyn(src string, srcPort, dstPort int64) *PacketData {
+	return &PacketData{SrcIP: src, SrcPort: srcPort, DstIP: "192.168.200.114", DstPort: dstPort, Protocol: "TCP", Hook: HookInput, Syn: true, Seq: 1000}
+}
+
+/***Methods***/
+
+//(*fakeSender).Send : record the packet
+func (s *fakeSender) Send(dst net.IP, packet []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.packets = append(s.packets, packet)
+	return nil
+}
+
+//(*fakeSender).count : return number of sent packets
+func (s *fakeSender) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.packets)
+}
+
+/***Unit-Tests***/
+
+func TestTarpitSynAck(t *testing.T) {
+	pkt := tarpitSyn("198.51.100.7", 40000, 23)
+	packet, err := tarpitSynAck(pkt, 0xdeadbeef)
+	if err != nil {
+		t.Fatalf("Unable to build SYN-ACK: %s\n", err.Error())
+	}
+	ip, tcp := packet[:ipv4HeaderSize], packet[ipv4HeaderSize:]
+	if !net.IP(ip[12:16]).Equal(net.ParseIP("192.168.200.114")) || !net.IP(ip[16:20]).Equal(net.ParseIP("198.51.100.7")) || ip[9] != 6 {
+		t.Fatalf("Unexpected ip header: % x\n", ip)
+	}
+	if checksum(ip, 0) != 0 {
+		t.Fatalf("Invalid ip checksum: % x\n", ip)
+	}
+	if binary.BigEndian.Uint16(tcp[0:]) != 23 || binary.BigEndian.Uint16(tcp[2:]) != 40000 {
+		t.Fatalf("Unexpected ports: % x\n", tcp)
+	}
+	if binary.BigEndian.Uint32(tcp[4:]) != 0xdeadbeef || binary.BigEndian.Uint32(tcp[8:]) != 1001 {
+		t.Fatalf("Unexpected sequence numbers: % x\n", tcp)
+	}
+	if tcp[13] != 0x12 || binary.BigEndian.Uint16(tcp[14:]) != 0 {
+		t.Fatalf("Unexpected flags/window: % x\n", tcp)
+	}
+	// checksum over pseudo header and segment must be zero
+	var pseudo uint32
+	for _, addr := range [][]byte{ip[12:16], ip[16:20]} {
+		pseudo += uint32(binary.BigEndian.Uint16(addr)) + uint32(binary.BigEndian.Uint16(addr[2:]))
+	}
+	if checksum(tcp, pseudo+6+tcpHeaderSize) != 0 {
+		t.Fatalf("Invalid tcp checksum: % x\n", tcp)
+	}
+	if _, err = tarpitSynAck(&PacketData{SrcIP: "2001:db8::1", DstIP: "192.168.200.114"}, 0); err == nil {
+		t.Fatalf("Able to build SYN-ACK of an ipv6 flow\n")
+	}
+}
+
+func TestTarpitFlows(t *testing.T) {
+	sender := &fakeSender{}
+	tarpit := NewTarpit(2)
+	tarpit.Sender = sender
+	now := time.Now()
+	for _, check := range []struct {
+		pkt     *PacketData
+		trapped bool
+	}{
+		{tarpitSyn("198.51.100.7", 40000, 23), true},
+		{tarpitSyn("198.51.100.7", 40001, 23), true},
+		// the tarpit is full, retransmitted SYNs of tarpitted flows are still answered
+		{tarpitSyn("198.51.100.7", 40002, 23), false},
+		{tarpitSyn("198.51.100.7", 40000, 23), true},
+		// only inbound SYNs are answered
+		{&PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 23, Protocol: "TCP", Hook: HookInput}, false},
+		{&PacketData{SrcIP: "192.168.200.114", SrcPort: 40000, DstIP: "198.51.100.7", DstPort: 23, Protocol: "TCP", Hook: HookOutput, Syn: true}, false},
+		{&PacketData{SrcIP: "198.51.100.7", SrcPort: 40000, DstIP: "192.168.200.114", DstPort: 53, Protocol: "UDP", Hook: HookInput, Syn: true}, false},
+	} {
+		if trapped, err := tarpit.Trap(check.pkt, now); trapped != check.trapped || err != nil {
+			t.Fatalf("Unexpected tarpit of %+v: %v (%v)\n", check.pkt, trapped, err)
+		}
+	}
+	if sender.count() != 3 || tarpit.Flows() != 2 {
+		t.Fatalf("Unexpected number of SYN-ACKs/flows: %d/%d\n", sender.count(), tarpit.Flows())
+	}
+	// check flows expire
+	if trapped, _ := tarpit.Trap(tarpitSyn("198.51.100.7", 40002, 23), now.Add(tarpitHold)); !trapped || tarpit.Flows() != 1 {
+		t.Fatalf("Tarpitted flows did not expire: %d\n", tarpit.Flows())
+	}
+	var none *Tarpit
+	if trapped, _ := none.Trap(tarpitSyn("198.51.100.7", 40000, 23), now); trapped {
+		t.Fatalf("Trapped packet without tarpit\n")
+	}
+}
+
+func TestFirewallTarpit(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open store: %s\n", err.Error())
+	}
+	defer st.Close()
+	st.SetOptions(store.Options{Inbound: "allow", Outbound: "allow", Forward: "allow", BlacklistAction: "tarpit", TarpitFlows: 16})
+	st.AppendRule(store.Rule{Zone: "inbound", FromIP: "any", FromPort: "any", ToIP: "any", ToPort: "23", Action: "tarpit"})
+	st.AddEntry(store.Blacklist, store.Entry{IPAddress: "203.0.113.5", Reason: "scanner"})
+	fw := newFirewall(st, profiles.NewSet())
+	sender := &fakeSender{}
+	fw.tarpit.Sender = sender
+	l := log.New(ioutil.Discard, "", 0)
+	for _, check := range []struct {
+		pkt  *PacketData
+		sent int
+	}{
+		{tarpitSyn("198.51.100.7", 40000, 23), 1},
+		{tarpitSyn("198.51.100.7", 40000, 22), 1},
+		{tarpitSyn("203.0.113.5", 40000, 22), 2},
+		// cached blacklisted sources are tarpitted as well
+		{tarpitSyn("203.0.113.5", 40001, 22), 3},
+	} {
+		if v := fw.HandlePackets(l, NewRedBlackKV(), check.pkt); sender.count() != check.sent {
+			t.Fatalf("Unexpected SYN-ACKs after %s:%d -> %d: %d (verdict %v)\n", check.pkt.SrcIP, check.pkt.SrcPort, check.pkt.DstPort, sender.count(), v)
+		}
+	}
+	if d := fw.Decide(NewRedBlackKV(), tarpitSyn("198.51.100.7", 40000, 23)); d.Verdict != netfilter.NF_DROP || !d.Tarpit || d.Action != "tarpit" {
+		t.Fatalf("Unexpected decision of tarpit rule: %+v\n", d)
+	}
+	// audit mode records instead of tarpitting
+	fw.Audit = true
+	if v := fw.HandlePackets(l, NewRedBlackKV(), tarpitSyn("198.51.100.7", 40002, 23)); v != netfilter.NF_ACCEPT || sender.count() != 3 {
+		t.Fatalf("Tarpitted packet in audit mode: %v\n", v)
+	}
+}